@@ -3,18 +3,29 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/devphaseX/mingle.git/docs"
+	"github.com/devphaseX/mingle.git/internal/events"
+	"github.com/devphaseX/mingle.git/internal/jobs"
 	"github.com/devphaseX/mingle.git/internal/mailer"
+	"github.com/devphaseX/mingle.git/internal/outbox"
+	"github.com/devphaseX/mingle.git/internal/ranking"
 	"github.com/devphaseX/mingle.git/internal/ratelimiter"
+	"github.com/devphaseX/mingle.git/internal/search"
+	"github.com/devphaseX/mingle.git/internal/signer"
 	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/devphaseX/mingle.git/internal/store/cache"
+	"github.com/devphaseX/mingle.git/internal/timeline"
+	"github.com/devphaseX/mingle.git/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -23,25 +34,103 @@ import (
 )
 
 type application struct {
-	config       config
-	store        store.Storage
-	cacheStorage cache.Storage
-	logger       *zap.SugaredLogger
-	mailer       mailer.Client
-	tokenMaker   store.TokenMaker
-	rateLimiter  ratelimiter.RateLimiter
+	config         config
+	store          store.Storage
+	cacheStorage   cache.Storage
+	logger         *zap.SugaredLogger
+	mailer         mailer.Client
+	tokenMaker     store.TokenMaker
+	rateLimiter    *ratelimiter.Policies
+	trustedProxies []netip.Prefix
+	events         events.Broker
+	signer         *signer.Signer
+	jobs           *jobs.Pool
+	outbox         *outbox.Relay
+	webhooks       *webhooks.Client
+	search         search.Index
+	timeline       *timeline.Service
+	ranking        *ranking.Experiment
+	wg             sync.WaitGroup
+	shutdown       chan struct{}
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	frontendURL string
-	mail        mailConfig
-	auth        AuthConfig
-	redisCfg    redisCfg
-	rateLimiter ratelimiter.Config
+	addr           string
+	db             dbConfig
+	env            string
+	apiURL         string
+	frontendURL    string
+	mail           mailConfig
+	auth           AuthConfig
+	redisCfg       redisCfg
+	rateLimiter    ratelimiter.Config
+	trustedProxies string
+	tags           tagsConfig
+	events         eventsConfig
+	digest         digestConfig
+	jobs           jobs.Config
+	outbox         outboxConfig
+	webhooks       webhooksConfig
+	search         searchConfig
+	timeline       timelineConfig
+	ranking        rankingConfig
+	signingKey     string
+}
+
+type tagsConfig struct {
+	trendingWindow   time.Duration
+	trendingHalfLife time.Duration
+}
+
+type eventsConfig struct {
+	historySize       int
+	heartbeatInterval time.Duration
+}
+
+type digestConfig struct {
+	enabled        bool
+	interval       time.Duration
+	batchSize      int
+	maxItems       int
+	unsubscribeURL string
+}
+
+type outboxConfig struct {
+	enabled     bool
+	sinks       []string
+	relay       outbox.Config
+	redisStream string
+	redisMaxLen int
+	httpURL     string
+	httpTimeout time.Duration
+}
+
+type webhooksConfig struct {
+	enabled     bool
+	timeout     time.Duration
+	maxAttempts int
+	// allowPrivate lets webhooks target loopback and private networks, for
+	// local development only.
+	allowPrivate bool
+}
+
+type searchConfig struct {
+	// backend is "postgres", or "memory" to keep an in-memory index fed by
+	// the outbox. The memory backend is refused outside development.
+	backend string
+}
+
+type timelineConfig struct {
+	enabled bool
+	timeline.Config
+}
+
+type rankingConfig struct {
+	// variants splits users between rankers, such as "balanced:90,recent:10".
+	variants      string
+	halfLife      time.Duration
+	candidates    int
+	historyWindow time.Duration
 }
 
 type redisCfg struct {
@@ -49,6 +138,7 @@ type redisCfg struct {
 	pw      string
 	db      int
 	enabled bool
+	cache   cache.Config
 }
 
 type mailConfig struct {
@@ -99,15 +189,16 @@ func (app *application) mount() *chi.Mux {
 		MaxAge:           300,
 	}))
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(app.realIP)
+	r.Use(requestLogger())
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(app.timeoutMiddleware(60 * time.Second))
 	r.Use(app.RateLimiterMiddleware)
 
 	r.MethodNotAllowed(app.methodNotAllowedResponse)
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
+		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
 
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
@@ -122,6 +213,16 @@ func (app *application) mount() *chi.Mux {
 				r.Get("/", app.getPostByIdHandler)
 				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
 				r.Delete("/", app.checkPostOwnership("admin", app.removePostByIdHandler))
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.getPostCommentsHandler)
+					r.Post("/", app.createCommentHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentContextMiddleware)
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+					})
+				})
 			})
 		})
 
@@ -132,17 +233,120 @@ func (app *application) mount() *chi.Mux {
 				r.Use(app.userContextMiddleware)
 
 				r.Get("/", app.getUserByIdHandler)
+				r.Get("/posts", app.getUserPostsHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/follow-request/approve", app.approveFollowRequestHandler)
+				r.Put("/follow-request/reject", app.rejectFollowRequestHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Put("/unblock", app.unblockUserHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireRole("admin"))
+					r.Get("/suspension", app.getUserSuspensionHandler)
+					r.Put("/suspend", app.suspendUserHandler)
+					r.Put("/unsuspend", app.unsuspendUserHandler)
+				})
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/feed/for-you", app.getForYouFeedHandler)
+				r.Get("/sessions", app.getSessionsHandler)
+				r.Get("/rate-limits", app.getRateLimitsHandler)
+				r.Get("/follow-requests", app.getFollowRequestsHandler)
+				r.Patch("/privacy", app.updatePrivacyHandler)
+			})
+		})
+
+		r.With(app.StreamAuthMiddleware()).Get("/events", app.eventStreamHandler)
+
+		r.Route("/conversations", func(r chi.Router) {
+			r.With(app.StreamAuthMiddleware(), app.requireActivatedUser).Get("/ws", app.conversationSocketHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware())
+				r.Use(app.requireActivatedUser)
+
+				r.Get("/", app.getConversationsHandler)
+				r.Post("/", app.createConversationHandler)
+
+				r.Route("/{conversationID}", func(r chi.Router) {
+					r.Use(app.conversationContextMiddleware)
+
+					r.Get("/", app.getConversationHandler)
+					r.Get("/messages", app.getMessagesHandler)
+					r.Post("/messages", app.sendMessageHandler)
+					r.Put("/read", app.markConversationReadHandler)
+				})
+			})
+		})
+
+		r.Route("/notifications", func(r chi.Router) {
+			r.Get("/unsubscribe", app.unsubscribeHandler)
+			r.Post("/unsubscribe", app.unsubscribeHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware())
+				r.Get("/", app.getNotificationsHandler)
+				r.Put("/read", app.markAllNotificationsReadHandler)
+				r.Get("/preferences", app.getNotificationPreferencesHandler)
+				r.Patch("/preferences", app.updateNotificationPreferencesHandler)
+				r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+				r.Delete("/{notificationID}", app.deleteNotificationHandler)
+			})
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
+		r.With(app.AuthTokenMiddleware()).Get("/search", app.searchHandler)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+			r.Use(app.requireRole("admin"))
+
+			r.Get("/", app.getWebhooksHandler)
+			r.Post("/", app.createWebhookHandler)
+
+			r.Route("/{webhookID}", func(r chi.Router) {
+				r.Use(app.webhookContextMiddleware)
+
+				r.Get("/", app.getWebhookHandler)
+				r.Patch("/", app.updateWebhookHandler)
+				r.Delete("/", app.deleteWebhookHandler)
+				r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+				r.Post("/deliveries/{deliveryID}/redeliver", app.redeliverWebhookHandler)
+			})
+		})
+
+		r.Route("/reports", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+			r.Post("/", app.createReportHandler)
+		})
+
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+			r.Use(app.requireRole("moderator"))
+
+			r.Route("/reports", func(r chi.Router) {
+				r.Get("/", app.getReportsHandler)
+
+				r.Route("/{reportID}", func(r chi.Router) {
+					r.Use(app.reportContextMiddleware)
+
+					r.Get("/", app.getReportHandler)
+					r.Put("/claim", app.claimReportHandler)
+					r.Put("/resolve", app.resolveReportHandler)
+				})
 			})
 		})
 
 		r.Route("/auth", func(r chi.Router) {
+			r.Use(app.rateLimit(rateLimitPolicyAuth))
 			r.Post("/register", app.registerUserHandler)
 			r.Post("/sign-in", app.signInHandler)
 			r.Post("/refresh", app.refreshToken)
@@ -164,8 +368,28 @@ func (app *application) serve(mux http.Handler) error {
 		Handler:      mux,
 	}
 
+	// Event streams never go idle, so they are told to end when shutdown
+	// starts instead of holding it up until the deadline.
+	app.shutdown = make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(app.shutdown) })
+
 	shutdownError := make(chan error)
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	// Workers finish the job they are running once the context is
+	// cancelled, so queued work is not cut off by shutdown.
+	app.background(func() { app.jobs.Run(workersCtx) })
+
+	if app.outbox != nil {
+		app.background(func() { app.outbox.Run(workersCtx) })
+	}
+
+	if app.config.digest.enabled {
+		app.background(func() { app.runDigestScheduler(workersCtx) })
+	}
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -182,10 +406,13 @@ func (app *application) serve(mux http.Handler) error {
 
 		if err != nil {
 			shutdownError <- err
+			return
 		}
 
-		// app.logger.Infow("completing background tasks", "addr", srv.Addr)
-
+		app.logger.Infow("completing background tasks", "addr", srv.Addr)
+		stopWorkers()
+		app.wg.Wait()
+		shutdownError <- nil
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
@@ -196,6 +423,10 @@ func (app *application) serve(mux http.Handler) error {
 		return err
 	}
 
+	if err := <-shutdownError; err != nil {
+		return err
+	}
+
 	app.logger.Infow("server has stopped", "addr", app.config.addr, "env", app.config.env)
 
 	return nil
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -82,28 +81,18 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		ActivationURL: activationURL,
 	}
 
-	//send mail
-
-	go func() {
-		err := app.mailer.Send(
-			mailer.UserWelcomeTemplate,
-			vars.Username,
-			vars.Email,
-			vars,
-			app.config.env == "development",
-		)
-
-		if err != nil {
-			app.logger.Errorw("error sending welcome email", "error", err)
-
-			ctx := context.Background()
-			//rollback user creation if email fails (SAGA pattern)
-			if err := app.store.Users.Delete(ctx, user.ID); err != nil {
-				app.logger.Errorw("error deleting user", "error", err)
-			}
-			return
+	// The welcome email is queued rather than sent inline so it is retried
+	// when the mail server is down. If it cannot even be queued, the user is
+	// rolled back so they can register again.
+	err = app.enqueueEmail(r.Context(), mailer.UserWelcomeTemplate, vars.Username, vars.Email, vars, nil)
+	if err != nil {
+		if err := app.store.Users.Delete(r.Context(), user.ID); err != nil {
+			app.logger.Errorw("error deleting user", "error", err)
 		}
-	}()
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	if err := app.writeJSON(w, http.StatusCreated, nil, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -205,6 +194,7 @@ type signInForm struct {
 //	@Param			body	body		signInForm	true	"Sign-in request body"
 //	@Success		200		{object}	object{access_token=string,access_token_expires_in=int64,refresh_token=string,refresh_token_expires_in=int64}
 //	@Failure		400		{object}	object{error=string}
+//	@Failure		403		{object}	object{error=string}	"Account suspended"
 //	@Failure		404		{object}	object{error=string}
 //	@Failure		500		{object}	object{error=string}
 //	@Router			/sign-in [post]
@@ -244,6 +234,11 @@ func (app *application) signInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.IsSuspended() {
+		app.accountSuspendedResponse(w, r, user.Suspension)
+		return
+	}
+
 	sessionExpiry := app.config.auth.RefreshTokenTTL
 	if form.RememberMe {
 		sessionExpiry = app.config.auth.RememberMeTTL
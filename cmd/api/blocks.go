@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user. Follows and follow requests between the two users are removed, and neither can follow or message the other until unblocked.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		201	{object}	object{block=store.Block}
+//	@Failure		400	{object}	object{error=string}
+//	@Failure		409	{object}	object{error=string}
+//	@Failure		500	{object}	object{error=string}
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	authUser := getAuthUserFromCtx(r)
+
+	if user.ID == authUser.ID {
+		app.errorResponse(w, r, http.StatusBadRequest, "you cannot block yourself")
+		return
+	}
+
+	block := &store.Block{
+		UserID:    authUser.ID,
+		BlockedID: user.ID,
+	}
+
+	if err := app.store.Blocks.Block(r.Context(), block); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, "user is already blocked")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"block": block}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		404	{object}	object{error=string}
+//	@Failure		500	{object}	object{error=string}
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	authUser := getAuthUserFromCtx(r)
+
+	if err := app.store.Blocks.Unblock(r.Context(), authUser.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/events"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type commentContextKey string
+
+var (
+	commentCtxKey commentContextKey = "comment"
+)
+
+type commentForm struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type createCommentForm struct {
+	commentForm
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+// GetPostComments godoc
+//
+//	@Summary		Lists a post's comments
+//	@Description	Lists a post's comments a page at a time. Pass an empty cursor to page with cursors instead, then follow next_cursor and prev_cursor from the metadata; cursor pages do not shift as new comments arrive.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order: 'created_at' or '-created_at' (default: '-created_at')"
+//	@Param			cursor		query		string	false	"Cursor from a previous page's metadata"
+//	@Success		200			{object}	object{comments=[]store.Comment, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [get]
+func (app *application) getPostCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:          1,
+		PageSize:      20,
+		Sort:          "-created_at",
+		SortSafelist:  []string{"created_at", "-created_at"},
+		CursorSigner:  app.signer,
+		CursorPurpose: store.CommentsCursor,
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	comments, metadata, err := app.store.Comments.GetPostComments(r.Context(), post.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// CreateComment godoc
+//
+//	@Summary		Comments on a post
+//	@Description	Adds a comment to a post, or a reply when parent_id is set. @username mentions in the content are recorded.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Post ID"
+//	@Param			payload	body		createCommentForm	true	"Comment payload"
+//	@Success		201		{object}	object{comment=store.Comment}
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		422		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var form createCommentForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
+	ctx := r.Context()
+
+	var parent *store.Comment
+	if form.ParentID != nil {
+		var err error
+		parent, err = app.store.Comments.GetById(ctx, *form.ParentID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if parent == nil || parent.PostID != post.ID {
+			app.failedValidationResponse(w, r, map[string]string{"parent_id": "comment does not exist on this post"})
+			return
+		}
+	}
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		ParentID: form.ParentID,
+		UserID:   user.ID,
+		Content:  form.Content,
+		User:     *user,
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.notifyComment(ctx, post, parent, comment)
+	app.syncCommentMentions(ctx, comment)
+	app.publishEvent(ctx, events.CommentCreated, comment, commentActivityRecipients(post, parent, comment)...)
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"comment": comment}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Updates a comment
+//	@Description	Updates a comment's content. @username mentions are recorded again.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"Post ID"
+//	@Param			commentID	path		int			true	"Comment ID"
+//	@Param			payload		body		commentForm	true	"Comment payload"
+//	@Success		200			{object}	object{comment=store.Comment}
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var form commentForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+	comment.Content = form.Content
+
+	ctx := r.Context()
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.syncCommentMentions(ctx, comment)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"comment": comment}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyComment tells the post's author about a new comment and, for replies,
+// the author of the parent comment. A post author replied to on their own
+// post only gets the reply notification.
+func (app *application) notifyComment(ctx context.Context, post *store.Post, parent *store.Comment, comment *store.Comment) {
+	if parent != nil {
+		app.notify(ctx, &store.Notification{
+			UserID:    parent.UserID,
+			ActorID:   comment.UserID,
+			Type:      store.NotificationReply,
+			PostID:    &comment.PostID,
+			CommentID: &comment.ID,
+		})
+
+		if parent.UserID == post.UserID {
+			return
+		}
+	}
+
+	app.notify(ctx, &store.Notification{
+		UserID:    post.UserID,
+		ActorID:   comment.UserID,
+		Type:      store.NotificationComment,
+		PostID:    &comment.PostID,
+		CommentID: &comment.ID,
+	})
+}
+
+// commentActivityRecipients returns who sees a new comment arrive live: the
+// post's author and, for replies, the parent comment's author.
+func commentActivityRecipients(post *store.Post, parent *store.Comment, comment *store.Comment) []int64 {
+	recipients := []int64{}
+
+	if post.UserID != comment.UserID {
+		recipients = append(recipients, post.UserID)
+	}
+
+	if parent != nil && parent.UserID != comment.UserID && parent.UserID != post.UserID {
+		recipients = append(recipients, parent.UserID)
+	}
+
+	return recipients
+}
+
+func (app *application) checkCommentOwnership(role string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromCtx(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allow, err := app.checkRolePrecedence(r.Context(), user, role)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !allow {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := app.readIntID(r, "commentID")
+
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		post := getPostFromCtx(r)
+		comment, err := app.store.Comments.GetById(r.Context(), commentID)
+		if err != nil || comment.PostID != post.ID {
+			if err == nil || errors.Is(err, store.ErrNotFound) {
+				app.notFoundResponse(w, r)
+				return
+			}
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), commentCtxKey, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtxKey).(*store.Comment)
+
+	return comment
+}
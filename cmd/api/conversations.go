@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/events"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type conversationContextKey string
+
+var (
+	conversationCtxKey conversationContextKey = "conversation"
+)
+
+type createConversationForm struct {
+	MemberIDs []int64 `json:"member_ids" validate:"required,min=1,max=9,dive,gte=1"`
+	Title     string  `json:"title" validate:"max=100"`
+}
+
+type messageForm struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type markConversationReadForm struct {
+	MessageID int64 `json:"message_id" validate:"gte=0"`
+}
+
+// CreateConversation godoc
+//
+//	@Summary		Starts a conversation
+//	@Description	Starts a 1:1 conversation when a single member is given, returning the existing one if there is one, or a group conversation of up to 10 people otherwise.
+//	@Description	All members must be activated users that have not blocked, or been blocked by, the creator.
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		createConversationForm	true	"Conversation"
+//	@Success		200		{object}	object{conversation=store.Conversation}	"Existing 1:1 conversation"
+//	@Success		201		{object}	object{conversation=store.Conversation}
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		422		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [post]
+func (app *application) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var form createConversationForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	ctx := r.Context()
+
+	seen := map[int64]bool{user.ID: true}
+	otherIDs := []int64{}
+	for _, id := range form.MemberIDs {
+		if !seen[id] {
+			seen[id] = true
+			otherIDs = append(otherIDs, id)
+		}
+	}
+
+	if len(otherIDs) == 0 {
+		app.failedValidationResponse(w, r, map[string]string{"member_ids": "must include someone other than yourself"})
+		return
+	}
+
+	blocked, err := app.store.Blocks.BlockedAmong(ctx, user.ID, otherIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(blocked) > 0 {
+		app.blockedResponse(w, r)
+		return
+	}
+
+	conversation := &store.Conversation{
+		IsGroup:   len(otherIDs) > 1,
+		CreatedBy: &user.ID,
+	}
+
+	if conversation.IsGroup {
+		conversation.Title = form.Title
+	}
+
+	created, err := app.store.Conversations.Create(ctx, conversation, append([]int64{user.ID}, otherIDs...))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.failedValidationResponse(w, r, map[string]string{"member_ids": "all members must be existing, activated users"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	conversation, err = app.store.Conversations.GetForMember(ctx, conversation.ID, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	if err := app.writeJSON(w, status, envelope{"conversation": conversation}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetConversations godoc
+//
+//	@Summary		Lists conversations
+//	@Description	Lists the authenticated user's conversations, most recently active first, with unread counts
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'updated_at' or '-updated_at')"
+//	@Success		200			{object}	object{conversations=[]store.Conversation, unread_count=int, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-updated_at",
+		SortSafelist: []string{"updated_at", "-updated_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getAuthUserFromCtx(r)
+
+	conversations, metadata, err := app.store.Conversations.GetAllForUser(ctx, user.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	unread, err := app.store.Conversations.CountUnread(ctx, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"conversations": conversations,
+		"unread_count":  unread,
+		"metadata":      metadata,
+	}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetConversation godoc
+//
+//	@Summary		Fetches a conversation
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Conversation ID"
+//	@Success		200	{object}	object{conversation=store.Conversation}
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{id} [get]
+func (app *application) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"conversation": conversation}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetMessages godoc
+//
+//	@Summary		Fetches message history
+//	@Description	Fetches a conversation's messages, newest first by default
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Conversation ID"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 50)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
+//	@Success		200			{object}	object{messages=[]store.Message, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{id}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     50,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	conversation := getConversationFromCtx(r)
+	messages, metadata, err := app.store.Conversations.GetMessages(r.Context(), conversation.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"messages": messages, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SendMessage godoc
+//
+//	@Summary		Sends a message
+//	@Description	Sends a message to a conversation. Members receive it live over the WebSocket and event stream.
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Conversation ID"
+//	@Param			payload	body		messageForm	true	"Message"
+//	@Success		201		{object}	object{message=store.Message}
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{id}/messages [post]
+func (app *application) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var form messageForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	conversation := getConversationFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	message, err := app.sendMessage(r.Context(), user, conversation.ID, form.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrBlocked):
+			app.blockedResponse(w, r)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"message": message}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// MarkConversationRead godoc
+//
+//	@Summary		Marks a conversation as read
+//	@Description	Marks messages as read up to message_id, or up to the latest message when it is omitted
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int							true	"Conversation ID"
+//	@Param			payload	body		markConversationReadForm	false	"Read position"
+//	@Success		200		{object}	object{last_read_message_id=int}
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{id}/read [put]
+func (app *application) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	var form markConversationReadForm
+
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &form); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	conversation := getConversationFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	lastRead, err := app.markConversationRead(r.Context(), user.ID, conversation.ID, form.MessageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"last_read_message_id": lastRead}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// sendMessage saves a message from sender and delivers it live to every
+// member. It fails with store.ErrNotFound when the sender is not a member and
+// store.ErrBlocked when a block exists between the sender and another member.
+func (app *application) sendMessage(ctx context.Context, sender *store.User, conversationID int64, content string) (*store.Message, error) {
+	memberIDs, err := app.store.Conversations.GetMemberIDs(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	otherIDs := []int64{}
+	isMember := false
+	for _, id := range memberIDs {
+		if id == sender.ID {
+			isMember = true
+			continue
+		}
+		otherIDs = append(otherIDs, id)
+	}
+
+	if !isMember {
+		return nil, store.ErrNotFound
+	}
+
+	blocked, err := app.store.Blocks.BlockedAmong(ctx, sender.ID, otherIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocked) > 0 {
+		return nil, store.ErrBlocked
+	}
+
+	message := &store.Message{
+		ConversationID: conversationID,
+		SenderID:       sender.ID,
+		Content:        content,
+		Sender: &store.User{
+			ID:        sender.ID,
+			FirstName: sender.FirstName,
+			LastName:  sender.LastName,
+			Username:  sender.Username,
+		},
+	}
+
+	if err := app.store.Conversations.CreateMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	// The sender is included so their other devices stay in sync.
+	app.publishEvent(ctx, events.MessageCreated, message, memberIDs...)
+
+	return message, nil
+}
+
+// markConversationRead moves a member's read position and tells their other
+// connections about it.
+func (app *application) markConversationRead(ctx context.Context, userID int64, conversationID int64, messageID int64) (int64, error) {
+	lastRead, err := app.store.Conversations.MarkRead(ctx, conversationID, userID, messageID)
+	if err != nil {
+		return 0, err
+	}
+
+	app.publishEvent(ctx, events.ConversationRead, envelope{
+		"conversation_id":      conversationID,
+		"last_read_message_id": lastRead,
+	}, userID)
+
+	return lastRead, nil
+}
+
+func (app *application) conversationContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conversationID, err := app.readIntID(r, "conversationID")
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		user := getAuthUserFromCtx(r)
+		conversation, err := app.store.Conversations.GetForMember(r.Context(), conversationID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), conversationCtxKey, conversation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(r *http.Request) *store.Conversation {
+	conversation, _ := r.Context().Value(conversationCtxKey).(*store.Conversation)
+
+	return conversation
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/mailer"
+	"github.com/devphaseX/mingle.git/internal/signer"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// unsubscribeDigest is the unsubscribe scope that turns digest emails off.
+// Any other scope is a notification type.
+const unsubscribeDigest = "digest"
+
+const unsubscribePurpose = "unsubscribe"
+
+// unsubscribeTokenTTL keeps unsubscribe links in old emails working for a
+// while without making them valid forever.
+const unsubscribeTokenTTL = 90 * 24 * time.Hour
+
+type unsubscribeToken struct {
+	Purpose string `json:"p"`
+	UserID  int64  `json:"u"`
+	Scope   string `json:"s"`
+}
+
+var notificationLabels = map[string]string{
+	store.NotificationFollow:         "new followers",
+	store.NotificationFollowAccepted: "accepted follow requests",
+	store.NotificationComment:        "comments on your posts",
+	store.NotificationReply:          "replies to your comments",
+	store.NotificationMention:        "mentions",
+}
+
+type digestItem struct {
+	Text string
+	URL  string
+}
+
+type digestTypeUnsubscribe struct {
+	Label string
+	URL   string
+}
+
+type digestEmail struct {
+	Username         string
+	Frequency        string
+	Items            []digestItem
+	More             bool
+	UnsubscribeURL   string
+	TypeUnsubscribes []digestTypeUnsubscribe
+}
+
+func (app *application) unsubscribeURL(userID int64, scope string) (string, error) {
+	payload, err := json.Marshal(unsubscribeToken{
+		Purpose: unsubscribePurpose,
+		UserID:  userID,
+		Scope:   scope,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	token := app.signer.Sign(payload, unsubscribeTokenTTL)
+	return fmt.Sprintf("%s?token=%s", app.config.digest.unsubscribeURL, url.QueryEscape(token)), nil
+}
+
+// runDigestScheduler sends due digest emails every digest interval until ctx
+// is cancelled.
+func (app *application) runDigestScheduler(ctx context.Context) {
+	ticker := time.NewTicker(app.config.digest.interval)
+	defer ticker.Stop()
+
+	for {
+		app.sendDueDigests(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) sendDueDigests(ctx context.Context) {
+	now := time.Now()
+
+	for ctx.Err() == nil {
+		digests, err := app.store.NotificationPreferences.GetDueDigests(ctx, now, app.config.digest.batchSize)
+		if err != nil {
+			app.logger.Errorw("error fetching due digests", "error", err)
+			return
+		}
+
+		var claimed int
+		for _, digest := range digests {
+			if app.sendDigest(ctx, digest, now) {
+				claimed++
+			}
+		}
+
+		// Digests that were not claimed are still due and would come back in
+		// the next batch, so stop rather than spin on them.
+		if len(digests) < app.config.digest.batchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// sendDigest builds one digest, claims it and queues its email. It reports
+// whether the digest was sent. The digest is only claimed once its email is
+// built, and released again if the email cannot be queued, so a failure
+// leaves it due rather than losing it.
+func (app *application) sendDigest(ctx context.Context, digest *store.Digest, now time.Time) bool {
+	user := digest.User
+
+	maxItems := app.config.digest.maxItems
+	notifications, err := app.store.NotificationPreferences.GetDigestNotifications(ctx, user.ID, digest.Since, maxItems+1)
+	if err != nil {
+		app.logger.Errorw("error fetching digest notifications", "user_id", user.ID, "error", err)
+		return false
+	}
+
+	var data *digestEmail
+	if len(notifications) > 0 {
+		data, err = app.buildDigestEmail(digest, notifications, maxItems)
+		if err != nil {
+			app.logger.Errorw("error building digest", "user_id", user.ID, "error", err)
+			return false
+		}
+	}
+
+	claimed, err := app.store.NotificationPreferences.ClaimDigest(ctx, user.ID, digest.LastDigestAt, now)
+	if err != nil {
+		app.logger.Errorw("error claiming digest", "user_id", user.ID, "error", err)
+		return false
+	}
+
+	// An empty digest is claimed all the same, moving the next one on.
+	if !claimed || data == nil {
+		return claimed
+	}
+
+	headers := map[string]string{
+		"List-Unsubscribe":      fmt.Sprintf("<%s>", data.UnsubscribeURL),
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	err = app.enqueueEmail(ctx, mailer.NotificationDigestTemplate, data.Username, user.Email, data, headers)
+	if err != nil {
+		app.logger.Errorw("error queueing digest email", "user_id", user.ID, "error", err)
+
+		if err := app.store.NotificationPreferences.ReleaseDigest(ctx, user.ID, digest.LastDigestAt, now); err != nil {
+			app.logger.Errorw("error releasing digest", "user_id", user.ID, "error", err)
+		}
+		return false
+	}
+
+	return true
+}
+
+func (app *application) buildDigestEmail(digest *store.Digest, notifications []*store.Notification, maxItems int) (*digestEmail, error) {
+	data := &digestEmail{
+		Username:  fmt.Sprintf("%s %s", digest.User.FirstName, digest.User.LastName),
+		Frequency: digest.Frequency,
+	}
+
+	if len(notifications) > maxItems {
+		notifications = notifications[:maxItems]
+		data.More = true
+	}
+
+	var types []string
+	for _, notification := range notifications {
+		data.Items = append(data.Items, app.digestItem(notification))
+
+		if !slices.Contains(types, notification.Type) {
+			types = append(types, notification.Type)
+		}
+	}
+
+	var err error
+	data.UnsubscribeURL, err = app.unsubscribeURL(digest.User.ID, unsubscribeDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range types {
+		link, err := app.unsubscribeURL(digest.User.ID, t)
+		if err != nil {
+			return nil, err
+		}
+
+		data.TypeUnsubscribes = append(data.TypeUnsubscribes, digestTypeUnsubscribe{
+			Label: notificationLabels[t],
+			URL:   link,
+		})
+	}
+
+	return data, nil
+}
+
+func (app *application) digestItem(notification *store.Notification) digestItem {
+	actor := "@" + notification.Actor.Username
+
+	var item digestItem
+	switch notification.Type {
+	case store.NotificationFollow:
+		item.Text = actor + " started following you"
+		item.URL = fmt.Sprintf("%s/users/%d", app.config.frontendURL, notification.ActorID)
+	case store.NotificationFollowAccepted:
+		item.Text = actor + " accepted your follow request"
+		item.URL = fmt.Sprintf("%s/users/%d", app.config.frontendURL, notification.ActorID)
+	case store.NotificationComment:
+		item.Text = actor + " commented on your post"
+	case store.NotificationReply:
+		item.Text = actor + " replied to your comment"
+	case store.NotificationMention:
+		item.Text = actor + " mentioned you"
+	default:
+		item.Text = actor + " sent you a notification"
+	}
+
+	if notification.PostID != nil {
+		item.URL = fmt.Sprintf("%s/posts/%d", app.config.frontendURL, *notification.PostID)
+	}
+
+	if notification.Count > 1 {
+		item.Text += fmt.Sprintf(" (%d times)", notification.Count)
+	}
+
+	return item
+}
+
+// Unsubscribe godoc
+//
+//	@Summary		Unsubscribes from notification emails
+//	@Description	Applies an unsubscribe link from a digest email. A digest link turns digest emails off and a notification type link stops emailing that type while keeping it in the app.
+//	@Description	Accepts POST as well as GET for one-click unsubscribe (RFC 8058).
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	query		string	true	"Signed unsubscribe token"
+//	@Success		200		{object}	object{message=string}
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/notifications/unsubscribe [get]
+//	@Router			/notifications/unsubscribe [post]
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	payload, err := app.signer.Verify(r.URL.Query().Get("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, signer.ErrInvalidSignature):
+			app.badRequestResponse(w, r, errors.New("invalid or malformed unsubscribe token"))
+		case errors.Is(err, signer.ErrExpired):
+			app.badRequestResponse(w, r, errors.New("unsubscribe link has expired"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var token unsubscribeToken
+	if err := json.Unmarshal(payload, &token); err != nil || token.Purpose != unsubscribePurpose {
+		app.badRequestResponse(w, r, errors.New("invalid or malformed unsubscribe token"))
+		return
+	}
+
+	if token.Scope != unsubscribeDigest && !slices.Contains(store.NotificationTypes, token.Scope) {
+		app.badRequestResponse(w, r, errors.New("invalid or malformed unsubscribe token"))
+		return
+	}
+
+	if err := app.unsubscribe(r, token.UserID, token.Scope); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	message := "you will no longer receive digest emails"
+	if token.Scope != unsubscribeDigest {
+		message = fmt.Sprintf("you will no longer be emailed about %s", notificationLabels[token.Scope])
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
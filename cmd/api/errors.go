@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/devphaseX/mingle.git/internal/validator"
 )
 
@@ -74,11 +77,11 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
-func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
-	app.logger.Warnf("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
-	w.Header().Set("Retry-After", retryAfter)
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
 
-	message := fmt.Sprintf("rate limit exceeded, retry after: %s", retryAfter)
+	message := fmt.Sprintf("rate limit exceeded, retry after: %ds", seconds(retryAfter))
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
 
@@ -104,12 +107,33 @@ func (app *application) authenticationBasicRequiredResponse(w http.ResponseWrite
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
-// func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
-// 	message := "your user account must be activated to access this resource"
-// 	app.errorResponse(w, r, http.StatusForbidden, message)
-// }
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
 
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) accountSuspendedResponse(w http.ResponseWriter, r *http.Request, suspension *store.Suspension) {
+	app.logger.Warnw("suspended account rejected", "method", r.Method, "path", r.URL.Path, "user_id", suspension.UserID)
+
+	message := fmt.Sprintf("your account has been suspended: %s", suspension.Reason)
+	if suspension.EndsAt != nil {
+		message = fmt.Sprintf("your account has been suspended until %s: %s", suspension.EndsAt.Format(time.RFC3339), suspension.Reason)
+	}
+
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) blockedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this action is not available between users who have blocked each other"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) privateAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this account is private; follow it to see its posts"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
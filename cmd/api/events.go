@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/events"
+)
+
+// publishEvent pushes an event to each user's stream. Failures are logged
+// since clients that miss an event still see the change on their next fetch.
+func (app *application) publishEvent(ctx context.Context, eventType string, data any, userIDs ...int64) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		app.logger.Errorw("error encoding event", "type", eventType, "error", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		event := &events.Event{
+			Type:   eventType,
+			UserID: userID,
+			Data:   payload,
+		}
+
+		if err := app.events.Publish(ctx, event); err != nil {
+			app.logger.Errorw("error publishing event", "type", eventType, "user_id", userID, "error", err)
+		}
+	}
+}
+
+// EventStream godoc
+//
+//	@Summary		Streams real-time events
+//	@Description	Server-Sent Events stream of new feed posts, notifications and comment activity for the authenticated user.
+//	@Description	Browsers' EventSource cannot set headers, so the access token may also be passed as the access_token query parameter.
+//	@Description	Reconnecting clients resume after the event in the Last-Event-ID header (or last_event_id query parameter).
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			access_token	query		string	false	"Access token, when the Authorization header cannot be set"
+//	@Param			last_event_id	query		string	false	"Resume after this event ID"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events [get]
+func (app *application) eventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	ctx := r.Context()
+	stream, err := app.events.Subscribe(ctx, user.ID, lastEventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The server's write timeout would otherwise cut the stream off.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := w.Header()
+	headers.Set("Content-Type", "text/event-stream")
+	headers.Set("Cache-Control", "no-cache")
+	headers.Set("Connection", "keep-alive")
+	headers.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+
+	if err := rc.Flush(); err != nil {
+		app.logger.Errorw("event stream cannot be flushed", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.events.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.shutdown:
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
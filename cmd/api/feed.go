@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/devphaseX/mingle.git/internal/ranking"
 	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/devphaseX/mingle.git/internal/timeline"
 )
 
+var feedSortSafelist = []string{"created_at", "-created_at"}
+
+// newFeedQueryFilter returns the pagination defaults and filters shared by
+// every post timeline.
+func newFeedQueryFilter() *store.PaginateQueryFilter {
+	return &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "created_at",
+		SortSafelist: feedSortSafelist,
+		Filters:      &store.GetUserFeedFilter{},
+	}
+}
+
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed with pagination and filtering
+//	@Description	Fetches the user feed with pagination and filtering. It is served from the user's materialised timeline when one is built, and from the database otherwise.
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
@@ -18,8 +37,11 @@ import (
 //	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
 //	@Param			search		query		string	false	"Search term"
 //	@Param			tags		query		string	false	"Comma-separated list of tags to filter by"
-//	@Param			since		query		string	false	"Filter posts created after this timestamp (RFC3339 format)"
-//	@Param			until		query		string	false	"Filter posts created before this timestamp (RFC3339 format)"
+//	@Param			since		query		string	false	"Only posts created at or after this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			until		query		string	false	"Only posts created at or before this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			author		query		string	false	"Comma-separated list of author usernames"
+//	@Param			has_comments	query	bool	false	"Only posts with (true) or without (false) comments"
+//	@Param			cursor		query		string	false	"Cursor from a previous page's metadata; pass it empty to start paging with cursors"
 //	@Success		200			{object}	object{posts=[]store.PostWithMetadata, metadata=store.Metadata}
 //	@Failure		400			{object}	error
 //	@Failure		500			{object}	error
@@ -27,15 +49,10 @@ import (
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	filter := &store.GetUserFeedFilter{}
-
-	fq := &store.PaginateQueryFilter{
-		Page:         1,
-		PageSize:     20,
-		Sort:         "created_at",
-		SortSafelist: []string{"created_at", "-created_at"},
-		Filters:      filter,
-	}
+	fq := newFeedQueryFilter()
+	// Cursors keep the feed steady while new posts arrive during scrolling.
+	fq.CursorSigner = app.signer
+	fq.CursorPurpose = store.FeedCursor
 
 	if err := fq.Parse(r); err != nil {
 		app.badRequestResponse(w, r, err)
@@ -43,7 +60,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	user := getAuthUserFromCtx(r)
-	posts, metadata, err := app.store.Posts.GetUserFeed(ctx, user.ID, *fq)
+	posts, metadata, err := app.getFeed(ctx, user.ID, *fq)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -55,3 +72,122 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 }
+
+// getForYouFeed godoc
+//
+//	@Summary		Fetches the ranked "For You" feed
+//	@Description	Ranks the newest posts in the user feed by recency, comment activity, how often the user interacts with the author and how well the tags match the user's recent activity. Users are split between ranking strategies; the one used is returned as ranker.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			search		query		string	false	"Search term"
+//	@Param			tags		query		string	false	"Comma-separated list of tags to filter by"
+//	@Param			since		query		string	false	"Only posts created at or after this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			until		query		string	false	"Only posts created at or before this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			author		query		string	false	"Comma-separated list of author usernames"
+//	@Param			has_comments	query	bool	false	"Only posts with (true) or without (false) comments"
+//	@Success		200			{object}	object{posts=[]ranking.RankedPost, ranker=string, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed/for-you [get]
+func (app *application) getForYouFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	fq := newFeedQueryFilter()
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Rank the newest candidates, then page through the ranked list.
+	candidatesQuery := *fq
+	candidatesQuery.Page = 1
+	candidatesQuery.PageSize = app.config.ranking.candidates
+	candidatesQuery.Sort = "-created_at"
+	// The first cursor page, which the timeline can serve however full it
+	// is.
+	candidatesQuery.CursorSigner = app.signer
+	candidatesQuery.CursorPurpose = store.FeedCursor
+	candidatesQuery.Cursor = &store.Cursor{}
+
+	user := getAuthUserFromCtx(r)
+	candidates, _, err := app.getFeed(ctx, user.ID, candidatesQuery)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	history, err := app.store.Posts.GetViewerHistory(ctx, user.ID, now.Add(-app.config.ranking.historyWindow))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ranker := app.ranking.Pick(user.ID)
+	ranked := ranker.Rank(candidates, ranking.NewViewer(history), now)
+
+	start := min(fq.Offset(), len(ranked))
+	end := min(start+fq.Limit(), len(ranked))
+	metadata := store.CalculateMetadata(len(ranked), fq.Page, fq.PageSize)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"posts": ranked[start:end], "ranker": ranker.Name(), "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getFeed reads the user's feed from their materialised timeline. When there
+// is no timeline service, the timeline is cold or unreadable, or it cannot
+// answer the query, it reads the feed from the database instead and rebuilds
+// a cold timeline in the background for next time.
+func (app *application) getFeed(ctx context.Context, userID int64, fq store.PaginateQueryFilter) ([]*store.PostWithMetadata, store.Metadata, error) {
+	if app.timeline == nil {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	view, err := app.timeline.Read(ctx, userID)
+	switch {
+	case errors.Is(err, timeline.ErrCold):
+		app.background(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if err := app.timeline.Rebuild(ctx, userID); err != nil {
+				app.logger.Errorw("error rebuilding timeline", "user_id", userID, "error", err)
+			}
+		})
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+
+	case err != nil:
+		app.logger.Warnw("error reading timeline, falling back to the database", "user_id", userID, "error", err)
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	if !view.Complete && !newestFirstPage(fq) {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	posts, metadata, err := app.store.Posts.GetTimeline(ctx, userID, view.PostIDs, view.LargeAccounts, fq)
+	if err != nil {
+		return nil, store.Metadata{}, err
+	}
+
+	// A short page from a full timeline may be missing matches older than
+	// the timeline reaches.
+	if !view.Complete && len(posts) < fq.PageSize {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	return posts, metadata, nil
+}
+
+// newestFirstPage reports whether fq asks for the first page of the newest
+// posts in cursor mode, the only query a full timeline, which holds just the
+// newest posts, answers the same way as the database. Offset pages also
+// report a total, which the timeline cannot know.
+func newestFirstPage(fq store.PaginateQueryFilter) bool {
+	return fq.Sort == "-created_at" && fq.Cursor != nil && fq.Cursor.ID == ""
+}
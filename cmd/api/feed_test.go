@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/signer"
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/google/uuid"
+)
+
+func TestNewestFirstPage(t *testing.T) {
+	tests := []struct {
+		name string
+		fq   store.PaginateQueryFilter
+		want bool
+	}{
+		{"first cursor page, newest first", store.PaginateQueryFilter{Sort: "-created_at", Cursor: &store.Cursor{}}, true},
+		{"later cursor page", store.PaginateQueryFilter{Sort: "-created_at", Cursor: &store.Cursor{CreatedAt: time.Now(), ID: "7"}}, false},
+		{"oldest first", store.PaginateQueryFilter{Sort: "created_at", Cursor: &store.Cursor{}}, false},
+		{"offset page", store.PaginateQueryFilter{Sort: "-created_at", Page: 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newestFirstPage(tt.fq); got != tt.want {
+				t.Errorf("newestFirstPage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedCursors(t *testing.T) {
+	app := newTestApplication(t)
+
+	tokenSigner, err := signer.New("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.signer = tokenSigner
+
+	if err := app.store.Users.Create(context.Background(), &store.User{ID: 1, Username: "reader", IsActive: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newTestAccessToken(t, app, 1)
+	mux := app.mount()
+
+	sign := func(t *testing.T, cursor store.Cursor) string {
+		t.Helper()
+
+		payload, err := json.Marshal(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokenSigner.Sign(payload, time.Hour)
+	}
+
+	tests := []struct {
+		name   string
+		cursor store.Cursor
+		want   int
+	}{
+		{"feed cursor", store.Cursor{CreatedAt: time.Now(), ID: "42", Sort: "-created_at", Purpose: store.FeedCursor}, http.StatusOK},
+		{"sessions cursor", store.Cursor{CreatedAt: time.Now(), ID: uuid.NewString(), Sort: "-created_at", Purpose: store.SessionsCursor}, http.StatusBadRequest},
+		{"comments cursor", store.Cursor{CreatedAt: time.Now(), ID: "42", Sort: "-created_at", Purpose: store.CommentsCursor}, http.StatusBadRequest},
+		{"feed cursor with a session id", store.Cursor{CreatedAt: time.Now(), ID: uuid.NewString(), Sort: "-created_at", Purpose: store.FeedCursor}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?cursor="+url.QueryEscape(sign(t, tt.cursor)), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
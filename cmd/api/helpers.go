@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -16,3 +17,21 @@ func (app *application) readIntID(r *http.Request, param string) (int64, error)
 
 	return id, nil
 }
+
+// background runs fn in a goroutine that the server waits for on shutdown.
+// Panics are recovered and logged.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", fmt.Sprint(err))
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devphaseX/mingle.git/internal/jobs"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type emailJob struct {
+	Template string            `json:"template"`
+	Username string            `json:"username"`
+	Email    string            `json:"email"`
+	Data     json.RawMessage   `json:"data"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Sandbox  bool              `json:"sandbox"`
+}
+
+func (app *application) registerJobHandlers() {
+	app.jobs.Register(jobs.SendEmail, app.sendEmailJob)
+	app.jobs.Register(jobs.DeliverWebhook, app.deliverWebhookJob)
+}
+
+// enqueue queues a job of jobType with payload encoded as JSON and wakes a
+// worker to run it.
+func (app *application) enqueue(ctx context.Context, jobType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := app.store.Jobs.Enqueue(ctx, &store.Job{Type: jobType, Payload: data}); err != nil {
+		return err
+	}
+
+	app.jobs.Wake()
+	return nil
+}
+
+// enqueueEmail queues templateFile to be sent to email. data is stored as
+// JSON, so templates only see its exported fields by name.
+func (app *application) enqueueEmail(ctx context.Context, templateFile, username, email string, data any, headers map[string]string) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return app.enqueue(ctx, jobs.SendEmail, emailJob{
+		Template: templateFile,
+		Username: username,
+		Email:    email,
+		Data:     encoded,
+		Headers:  headers,
+		Sandbox:  app.config.env == "development",
+	})
+}
+
+func (app *application) sendEmailJob(ctx context.Context, payload json.RawMessage) error {
+	var job emailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding email job: %w", err))
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(job.Data, &data); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding email data: %w", err))
+	}
+
+	return app.mailer.SendWithHeaders(job.Template, job.Username, job.Email, data, job.Headers, job.Sandbox)
+}
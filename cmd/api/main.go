@@ -1,15 +1,25 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/devphaseX/mingle.git/internal/db"
 	"github.com/devphaseX/mingle.git/internal/env"
+	"github.com/devphaseX/mingle.git/internal/events"
+	"github.com/devphaseX/mingle.git/internal/jobs"
 	"github.com/devphaseX/mingle.git/internal/mailer"
+	"github.com/devphaseX/mingle.git/internal/outbox"
+	"github.com/devphaseX/mingle.git/internal/ranking"
 	"github.com/devphaseX/mingle.git/internal/ratelimiter"
+	"github.com/devphaseX/mingle.git/internal/search"
+	"github.com/devphaseX/mingle.git/internal/signer"
 	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/devphaseX/mingle.git/internal/store/cache"
+	"github.com/devphaseX/mingle.git/internal/timeline"
+	"github.com/devphaseX/mingle.git/internal/webhooks"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
@@ -78,11 +88,89 @@ func main() {
 			pw:      env.GetString("REDIS_PW", ""),
 			db:      env.GetInt("REDIS_DB", 0),
 			enabled: env.GetBool("REDIS_ENABLED", true),
+			cache: cache.Config{
+				UserTTL:     env.GetDuration("CACHE_USER_TTL", time.Minute),
+				PostTTL:     env.GetDuration("CACHE_POST_TTL", time.Minute*5),
+				CommentsTTL: env.GetDuration("CACHE_COMMENTS_TTL", time.Minute),
+
+				LocalSize:    env.GetInt("CACHE_LOCAL_SIZE", 10000),
+				LocalUserTTL: env.GetDuration("CACHE_LOCAL_USER_TTL", 0),
+
+				Breaker: cache.BreakerConfig{
+					Threshold: env.GetInt("CACHE_BREAKER_THRESHOLD", 5),
+					Cooldown:  env.GetDuration("CACHE_BREAKER_COOLDOWN", time.Second*30),
+				},
+			},
 		},
 		rateLimiter: ratelimiter.Config{
-			RequestsPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
-			TimeFrame:            time.Second * 5,
-			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
+			Enabled: env.GetBool("RATE_LIMITER_ENABLED", true),
+			Policies: env.GetString("RATE_LIMITER_POLICIES", fmt.Sprintf(
+				"default=%s:%d/5s,read=token_bucket:100/5s,auth=sliding_window:5/1m",
+				env.GetString("RATE_LIMITER_STRATEGY", ratelimiter.StrategyFixedWindow),
+				env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
+			)),
+		},
+		trustedProxies: env.GetString("TRUSTED_PROXIES", ""),
+		events: eventsConfig{
+			historySize:       env.GetInt("EVENTS_HISTORY_SIZE", 100),
+			heartbeatInterval: env.GetDuration("EVENTS_HEARTBEAT_INTERVAL", time.Second*15),
+		},
+		digest: digestConfig{
+			enabled:        env.GetBool("DIGEST_ENABLED", true),
+			interval:       env.GetDuration("DIGEST_INTERVAL", time.Minute*15),
+			batchSize:      env.GetInt("DIGEST_BATCH_SIZE", 100),
+			maxItems:       env.GetInt("DIGEST_MAX_ITEMS", 20),
+			unsubscribeURL: env.GetString("DIGEST_UNSUBSCRIBE_URL", "http://localhost:8080/v1/notifications/unsubscribe"),
+		},
+		jobs: jobs.Config{
+			Workers:      env.GetInt("JOBS_WORKERS", 4),
+			PollInterval: env.GetDuration("JOBS_POLL_INTERVAL", time.Second*5),
+			JobTimeout:   env.GetDuration("JOBS_TIMEOUT", time.Minute),
+			BaseBackoff:  env.GetDuration("JOBS_BASE_BACKOFF", time.Second*10),
+			MaxBackoff:   env.GetDuration("JOBS_MAX_BACKOFF", time.Hour),
+		},
+		outbox: outboxConfig{
+			enabled: env.GetBool("OUTBOX_ENABLED", true),
+			sinks:   strings.Split(env.GetString("OUTBOX_SINKS", "log"), ","),
+			relay: outbox.Config{
+				BatchSize:    env.GetInt("OUTBOX_BATCH_SIZE", 100),
+				PollInterval: env.GetDuration("OUTBOX_POLL_INTERVAL", time.Second*2),
+				Lease:        env.GetDuration("OUTBOX_LEASE", time.Minute),
+				MaxAttempts:  env.GetInt("OUTBOX_MAX_ATTEMPTS", 20),
+				Retention:    env.GetDuration("OUTBOX_RETENTION", time.Hour*24*7),
+			},
+			redisStream: env.GetString("OUTBOX_REDIS_STREAM", "outbox"),
+			redisMaxLen: env.GetInt("OUTBOX_REDIS_MAX_LEN", 10000),
+			httpURL:     env.GetString("OUTBOX_HTTP_URL", ""),
+			httpTimeout: env.GetDuration("OUTBOX_HTTP_TIMEOUT", time.Second*10),
+		},
+		webhooks: webhooksConfig{
+			enabled:      env.GetBool("WEBHOOKS_ENABLED", true),
+			timeout:      env.GetDuration("WEBHOOKS_TIMEOUT", time.Second*10),
+			maxAttempts:  env.GetInt("WEBHOOKS_MAX_ATTEMPTS", 8),
+			allowPrivate: env.GetBool("WEBHOOKS_ALLOW_PRIVATE_ADDRESSES", false),
+		},
+		search: searchConfig{
+			backend: env.GetString("SEARCH_BACKEND", "postgres"),
+		},
+		timeline: timelineConfig{
+			enabled: env.GetBool("TIMELINE_ENABLED", true),
+			Config: timeline.Config{
+				Size:        env.GetInt("TIMELINE_SIZE", 800),
+				TTL:         env.GetDuration("TIMELINE_TTL", time.Hour*24*7),
+				FanoutLimit: env.GetInt("TIMELINE_FANOUT_LIMIT", 10000),
+			},
+		},
+		ranking: rankingConfig{
+			variants:      env.GetString("RANKING_VARIANTS", "balanced:100"),
+			halfLife:      env.GetDuration("RANKING_HALF_LIFE", time.Hour*12),
+			candidates:    env.GetInt("RANKING_CANDIDATES", 300),
+			historyWindow: env.GetDuration("RANKING_HISTORY_WINDOW", time.Hour*24*30),
+		},
+		signingKey: env.GetString("SIGNING_SECRET_KEY", ""),
+		tags: tagsConfig{
+			trendingWindow:   env.GetDuration("TRENDING_TAGS_WINDOW", time.Hour*24),
+			trendingHalfLife: env.GetDuration("TRENDING_TAGS_HALF_LIFE", time.Hour*6),
 		},
 	}
 
@@ -110,9 +198,30 @@ func main() {
 		logger.Info("redis cache connection established")
 	}
 
-	dbStore := store.NewPostgressStorage(db)
-	cacheStorage := cache.NewRedisStorage(rdb)
-	rateLimiter := ratelimiter.NewFixedWindowLimiter(cfg.rateLimiter.RequestsPerTimeFrame, cfg.rateLimiter.TimeFrame)
+	var broker events.Broker = events.NewHub(cfg.events.historySize)
+	if cfg.redisCfg.enabled {
+		redisBroker := events.NewRedisBroker(rdb, cfg.events.historySize, logger)
+		defer redisBroker.Close()
+		broker = redisBroker
+	}
+
+	cacheStorage := cache.NewLocalStorage(cfg.redisCfg.cache)
+	if rdb != nil {
+		cacheStorage = cache.NewRedisStorage(rdb, cfg.redisCfg.cache, logger)
+	}
+	dbStore := cache.Invalidating(store.NewPostgressStorage(db), cacheStorage, logger)
+
+	rateLimitPolicies, err := ratelimiter.ParsePolicies(cfg.rateLimiter.Policies)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	rateLimiter := ratelimiter.NewPolicies(rateLimitPolicies, rdb)
+
+	trustedProxies, err := parseTrustedProxies(cfg.trustedProxies)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	mailer := mailer.NewMailTrapClient(
 		cfg.mail.mailTrap.fromEmail,
 		cfg.mail.mailTrap.smtpAddr,
@@ -123,22 +232,112 @@ func main() {
 		logger,
 	)
 
+	rankingExperiment, err := ranking.ParseExperiment(cfg.ranking.variants, ranking.Rankers(cfg.ranking.halfLife))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	tokenMaker, err := store.NewTokenStore(cfg.auth.AccessSecretKey, cfg.auth.RefreshSecretKey)
 
 	if err != nil {
 		logger.Panicf("setting up token maker error:  %w", err)
 	}
 
+	tokenSigner, err := signer.New(cfg.signingKey)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	app := &application{
-		config:       cfg,
-		store:        dbStore,
-		logger:       logger,
-		mailer:       mailer,
-		tokenMaker:   tokenMaker,
-		cacheStorage: cacheStorage,
-		rateLimiter:  rateLimiter,
+		config:         cfg,
+		store:          dbStore,
+		logger:         logger,
+		mailer:         mailer,
+		tokenMaker:     tokenMaker,
+		cacheStorage:   cacheStorage,
+		rateLimiter:    rateLimiter,
+		trustedProxies: trustedProxies,
+		events:         broker,
+		signer:         tokenSigner,
+		jobs:           jobs.NewPool(dbStore.Jobs, cfg.jobs, logger),
+		webhooks:       webhooks.NewClient(cfg.webhooks.timeout, cfg.webhooks.allowPrivate),
+		ranking:        rankingExperiment,
+	}
+
+	app.registerJobHandlers()
+
+	switch cfg.search.backend {
+	case "postgres":
+		app.search = search.NewPostgres(dbStore.Search)
+	case "memory":
+		// The in-memory index ignores blocks, suspensions and follower-only
+		// posts, forgets everything on restart and only sees the outbox
+		// batches its own replica claims.
+		if cfg.env != "development" {
+			logger.Fatalf("memory search backend is only available in development, not %q", cfg.env)
+		}
+		if !cfg.outbox.enabled {
+			logger.Fatal("memory search backend requires the outbox to be enabled")
+		}
+		app.search = search.NewMemory()
+	default:
+		logger.Fatalf("unknown search backend %q", cfg.search.backend)
+	}
+
+	// Timelines are kept in Redis and fed by the outbox. Without either the
+	// feed is read straight from the database.
+	if cfg.timeline.enabled && rdb != nil && cfg.outbox.enabled {
+		app.timeline = timeline.NewService(rdb, dbStore.Followers, dbStore.Posts, cfg.timeline.Config)
+	}
+
+	if cfg.outbox.enabled {
+		sinks, err := newOutboxSinks(cfg, rdb, logger)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		// Webhook deliveries are fed by the outbox, so they need it enabled.
+		if cfg.webhooks.enabled {
+			sinks = append(sinks, webhooks.NewSink(dbStore.Webhooks, jobs.DeliverWebhook, cfg.webhooks.maxAttempts, app.jobs.Wake))
+		}
+
+		if app.timeline != nil {
+			sinks = append(sinks, timeline.NewSink(app.timeline))
+		}
+
+		if cfg.search.backend == "memory" {
+			sinks = append(sinks, search.NewSink(app.search))
+		}
+
+		app.outbox = outbox.NewRelay(dbStore.Outbox, sinks, cfg.outbox.relay, logger)
 	}
 
 	mux := app.mount()
 	log.Fatal(app.serve(mux))
 }
+
+func newOutboxSinks(cfg config, rdb *redis.Client, logger *zap.SugaredLogger) ([]outbox.Sink, error) {
+	var sinks []outbox.Sink
+
+	for _, name := range cfg.outbox.sinks {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "log":
+			sinks = append(sinks, outbox.NewLogSink(logger))
+		case "redis":
+			if rdb == nil {
+				return nil, fmt.Errorf("outbox redis sink requires redis to be enabled")
+			}
+			sinks = append(sinks, outbox.NewRedisStreamSink(rdb, cfg.outbox.redisStream, cfg.outbox.redisMaxLen))
+		case "http":
+			if cfg.outbox.httpURL == "" {
+				return nil, fmt.Errorf("outbox http sink requires OUTBOX_HTTP_URL")
+			}
+			sinks = append(sinks, outbox.NewHTTPSink(cfg.outbox.httpURL, cfg.outbox.httpTimeout))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+
+	return sinks, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+
+	"github.com/devphaseX/mingle.git/internal/extract"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// withHashtags merges the #hashtags found in a post's title and content into
+// its tags so the feed's tag filter picks them up. Tags are stored lowercased.
+func withHashtags(post *store.Post) {
+	post.Tags = extract.MergeTags(post.Tags, extract.Hashtags(post.Title, post.Context))
+}
+
+// mentionedUserIDs resolves the @username mentions in text to user IDs,
+// leaving out the author mentioning themselves.
+func (app *application) mentionedUserIDs(ctx context.Context, authorID int64, text ...string) ([]int64, error) {
+	ids, err := app.store.Users.GetIDsByUsernames(ctx, extract.Mentions(text...))
+	if err != nil {
+		return nil, err
+	}
+
+	mentioned := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id != authorID {
+			mentioned = append(mentioned, id)
+		}
+	}
+
+	return mentioned, nil
+}
+
+// syncPostMentions records the users mentioned in a post and notifies those
+// mentioned for the first time. Failures are logged rather than failing the
+// request since the post itself has been saved.
+func (app *application) syncPostMentions(ctx context.Context, post *store.Post) {
+	userIDs, err := app.mentionedUserIDs(ctx, post.UserID, post.Title, post.Context)
+	if err != nil {
+		app.logger.Errorw("error resolving post mentions", "post_id", post.ID, "error", err)
+		return
+	}
+
+	added, err := app.store.Mentions.SetPostMentions(ctx, post.ID, userIDs)
+	if err != nil {
+		app.logger.Errorw("error saving post mentions", "post_id", post.ID, "error", err)
+		return
+	}
+
+	for _, userID := range added {
+		app.notify(ctx, &store.Notification{
+			UserID:  userID,
+			ActorID: post.UserID,
+			Type:    store.NotificationMention,
+			PostID:  &post.ID,
+		})
+	}
+}
+
+// syncCommentMentions records the users mentioned in a comment and notifies
+// those mentioned for the first time. Failures are logged rather than failing
+// the request since the comment has been saved.
+func (app *application) syncCommentMentions(ctx context.Context, comment *store.Comment) {
+	userIDs, err := app.mentionedUserIDs(ctx, comment.UserID, comment.Content)
+	if err != nil {
+		app.logger.Errorw("error resolving comment mentions", "comment_id", comment.ID, "error", err)
+		return
+	}
+
+	added, err := app.store.Mentions.SetCommentMentions(ctx, comment.ID, userIDs)
+	if err != nil {
+		app.logger.Errorw("error saving comment mentions", "comment_id", comment.ID, "error", err)
+		return
+	}
+
+	for _, userID := range added {
+		app.notify(ctx, &store.Notification{
+			UserID:    userID,
+			ActorID:   comment.UserID,
+			Type:      store.NotificationMention,
+			PostID:    &comment.PostID,
+			CommentID: &comment.ID,
+		})
+	}
+}
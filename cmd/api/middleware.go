@@ -5,10 +5,18 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/devphaseX/mingle.git/internal/ratelimiter"
 	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type authContext string
@@ -55,12 +63,95 @@ func (app *application) AuthTokenMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
+			if user.IsSuspended() {
+				app.accountSuspendedResponse(w, r, user.Suspension)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), authKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// StreamAuthMiddleware authenticates like AuthTokenMiddleware but also
+// accepts the access token in the access_token query parameter, since
+// browsers cannot set headers on EventSource or WebSocket connections.
+func (app *application) StreamAuthMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authenticate := app.AuthTokenMiddleware()(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			authenticate.ServeHTTP(w, r)
+		})
+	}
+}
+
+// streamRoutes are the event stream and WebSocket endpoints, which stay open
+// for as long as the client is connected.
+var streamRoutes = map[string]bool{
+	"/v1/events":           true,
+	"/v1/conversations/ws": true,
+}
+
+// timeoutMiddleware applies chi's request timeout to everything except the
+// stream routes. They are matched by path, not by headers the client
+// controls, so no other route can opt out of the timeout.
+func (app *application) timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+
+	return func(next http.Handler) http.Handler {
+		timed := withTimeout(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if streamRoutes[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestLogger is chi's request logger with the access_token query
+// parameter, which stream clients authenticate with, redacted from the
+// logged URL.
+func requestLogger() func(http.Handler) http.Handler {
+	return middleware.RequestLogger(redactingLogFormatter{
+		LogFormatter: &middleware.DefaultLogFormatter{
+			Logger:  log.New(os.Stdout, "", log.LstdFlags),
+			NoColor: runtime.GOOS == "windows",
+		},
+	})
+}
+
+type redactingLogFormatter struct {
+	middleware.LogFormatter
+}
+
+func (f redactingLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	query := r.URL.Query()
+	if !query.Has("access_token") {
+		return f.LogFormatter.NewLogEntry(r)
+	}
+
+	query.Set("access_token", "REDACTED")
+	redacted := *r.URL
+	redacted.RawQuery = query.Encode()
+
+	logged := *r
+	logged.URL = &redacted
+	logged.RequestURI = redacted.RequestURI()
+
+	return f.LogFormatter.NewLogEntry(&logged)
+}
+
 func (app *application) checkPostOwnership(role string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getAuthUserFromCtx(r)
@@ -87,6 +178,43 @@ func (app *application) checkPostOwnership(role string, next http.HandlerFunc) h
 	})
 }
 
+// requireRole only lets through users whose role is at least as privileged as
+// the given role.
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getAuthUserFromCtx(r)
+
+			allow, err := app.checkRolePrecedence(r.Context(), user, role)
+
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if !allow {
+				app.notPermittedResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireActivatedUser only lets through users who have activated their
+// account.
+func (app *application) requireActivatedUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := getAuthUserFromCtx(r); !user.IsActive {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 
@@ -145,53 +273,159 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 }
 
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
-	var user *store.User
-	var err error
+	return app.cacheStorage.Users.Get(ctx, userID, func(ctx context.Context) (*store.User, error) {
+		return app.store.Users.GetById(ctx, userID)
+	})
+}
 
-	// Check if Redis caching is enabled
-	if app.config.redisCfg.enabled {
-		// Attempt to get the user from the cache
-		user, err = app.cacheStorage.Users.Get(ctx, userID)
-		if err != nil {
-			fmt.Println("failed to fetch from redis")
-			// Log the error, but continue to try the database
-			app.logger.Errorf("Error fetching user from cache: %v", err)
-			return nil, err
-		}
-		if user != nil {
-			// If the user is found in the cache, return it
-			app.logger.Infow("cache hit", "key", "user", "id", userID)
-			return user, nil
+const (
+	rateLimitPolicyRead = "read"
+	rateLimitPolicyAuth = "auth"
+)
+
+// RateLimiterMiddleware applies the read policy to safe methods and the
+// default policy to everything else. Routes that need a stricter limit add
+// their own with rateLimit.
+func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
+	read := app.rateLimit(rateLimitPolicyRead)(next)
+	write := app.rateLimit(ratelimiter.PolicyDefault)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			read.ServeHTTP(w, r)
+		default:
+			write.ServeHTTP(w, r)
 		}
+	})
+}
+
+// rateLimit limits requests under the named policy.
+func (app *application) rateLimit(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.config.rateLimiter.Enabled {
+				applied, res := app.rateLimiter.Allow(policy, app.rateLimitKey(r))
+				setRateLimitHeaders(w, applied, res)
+
+				if !res.Allowed {
+					app.rateLimitExceededResponse(w, r, res.RetryAfter)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	}
+}
 
-	// If the user is not found in the cache or caching is disabled, fetch from the database
-	user, err = app.store.Users.GetById(ctx, userID)
-	if err != nil {
-		// If there's an error fetching from the database, return the error
-		return nil, fmt.Errorf("error fetching user from database: %w", err)
+// rateLimitKey identifies the client a request counts against: the user in
+// a valid access token, otherwise the client IP. The limiter runs before
+// authentication, so the token is only verified, not looked up.
+func (app *application) rateLimitKey(r *http.Request) string {
+	token := r.URL.Query().Get("access_token")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token, _ = strings.CutPrefix(auth, "Bearer ")
 	}
 
-	app.logger.Infof("fetching user %v from the database", userID)
-	err = app.cacheStorage.Users.Set(ctx, user)
+	if token != "" {
+		if payload, err := app.tokenMaker.ValidateAccessToken(token); err == nil && payload.Valid() == nil {
+			return fmt.Sprintf("user:%d", payload.UserID)
+		}
+	}
 
+	// RemoteAddr carries the client's port unless realIP replaced it with a
+	// forwarded address.
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return nil, err
+		ip = r.RemoteAddr
 	}
 
-	// If the user is found in the database, return it
-	return user, nil
+	return "ip:" + ip
 }
 
-func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
+// realIP replaces r.RemoteAddr with the client address forwarded in
+// X-Forwarded-For or X-Real-IP, but only for requests from a trusted proxy.
+// From anyone else those headers are whatever the client chose to send, so
+// the socket peer is kept and rotating them cannot dodge per-IP limits.
+func (app *application) realIP(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
-				app.rateLimitExceededResponse(w, r, retryAfter.String())
-				return
-			}
+		if ip, ok := app.forwardedIP(r); ok {
+			r.RemoteAddr = ip.String()
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (app *application) forwardedIP(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !app.trustedProxy(peer.Addr().Unmap()) {
+		return netip.Addr{}, false
+	}
+
+	// Each proxy appends the address it got the request from, so the client
+	// is the rightmost hop that is not one of our proxies. Hops left of it
+	// were sent by the client and are not to be trusted.
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return netip.Addr{}, false
+			}
+
+			if addr = addr.Unmap(); !app.trustedProxy(addr) {
+				return addr, true
+			}
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
+
+func (app *application) trustedProxy(addr netip.Addr) bool {
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTrustedProxies parses a comma-separated list of proxy addresses and
+// CIDR ranges, such as "10.0.0.0/8,127.0.0.1".
+func parseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
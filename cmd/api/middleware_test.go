@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/ratelimiter"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestAuthRateLimitIgnoresSpoofedForwardedHeaders(t *testing.T) {
+	app := newTestApplication(t)
+
+	policies, err := ratelimiter.ParsePolicies("default=fixed_window:100/1m,read=fixed_window:100/1m,auth=sliding_window:5/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.config.rateLimiter.Enabled = true
+	app.rateLimiter = ratelimiter.NewPolicies(policies, nil)
+
+	mux := app.mount()
+
+	signIn := func(remoteAddr string, headers map[string]string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/sign-in", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should key untrusted peers on the socket address", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			headers := map[string]string{
+				"X-Forwarded-For": fmt.Sprintf("198.51.100.%d", i),
+				"X-Real-IP":       fmt.Sprintf("198.51.100.%d", i),
+			}
+			if code := signIn("203.0.113.7:4000", headers); code == http.StatusTooManyRequests {
+				t.Fatalf("request %d was limited early", i)
+			}
+		}
+
+		code := signIn("203.0.113.7:4000", map[string]string{"X-Forwarded-For": "198.51.100.99"})
+		checkResponseCode(t, http.StatusTooManyRequests, code)
+	})
+
+	t.Run("should key trusted proxies on the forwarded client", func(t *testing.T) {
+		app.trustedProxies, err = parseTrustedProxies("10.0.0.0/8")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The client's own X-Forwarded-For entries sit left of the one our
+		// proxy appends, so rotating them changes nothing.
+		for i := 0; i < 5; i++ {
+			headers := map[string]string{"X-Forwarded-For": fmt.Sprintf("198.51.100.%d, 192.0.2.10", i)}
+			if code := signIn("10.0.0.2:4000", headers); code == http.StatusTooManyRequests {
+				t.Fatalf("request %d was limited early", i)
+			}
+		}
+
+		code := signIn("10.0.0.2:4000", map[string]string{"X-Forwarded-For": "198.51.100.99, 192.0.2.10"})
+		checkResponseCode(t, http.StatusTooManyRequests, code)
+
+		// Another client behind the same proxy has its own limit.
+		code = signIn("10.0.0.2:4000", map[string]string{"X-Forwarded-For": "192.0.2.11"})
+		if code == http.StatusTooManyRequests {
+			t.Error("a different forwarded client was limited")
+		}
+	})
+}
+
+func TestTimeoutMiddlewareExemptsOnlyStreamRoutes(t *testing.T) {
+	app := newTestApplication(t)
+
+	var hasDeadline bool
+	handler := app.timeoutMiddleware(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	}))
+
+	tests := []struct {
+		path     string
+		accept   string
+		deadline bool
+	}{
+		{"/v1/events", "text/event-stream", false},
+		{"/v1/conversations/ws", "", false},
+		{"/v1/posts/1", "", true},
+		// Asking for an event stream does not lift the timeout elsewhere.
+		{"/v1/posts/1", "text/event-stream", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if hasDeadline != tt.deadline {
+			t.Errorf("%s with Accept %q: deadline = %v, want %v", tt.path, tt.accept, hasDeadline, tt.deadline)
+		}
+	}
+}
+
+func TestRequestLoggerRedactsAccessToken(t *testing.T) {
+	var buf bytes.Buffer
+	logger := middleware.RequestLogger(redactingLogFormatter{
+		LogFormatter: &middleware.DefaultLogFormatter{Logger: log.New(&buf, "", 0), NoColor: true},
+	})
+
+	var seen string
+	handler := logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.URL.Query().Get("access_token")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/events?access_token=secret-token&since=5", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Errorf("access token logged: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "since=5") {
+		t.Errorf("other query parameters should still be logged: %s", buf.String())
+	}
+	if seen != "secret-token" {
+		t.Errorf("handler saw access_token %q, want the original", seen)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type reportContextKey string
+
+var (
+	reportCtxKey reportContextKey = "report"
+)
+
+// GetReports godoc
+//
+//	@Summary		Lists the moderation queue
+//	@Description	Lists reports with pagination, optionally filtered by status and target type
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
+//	@Param			status		query		string	false	"Report status (open, claimed or resolved)"
+//	@Param			target_type	query		string	false	"Reported target type (post, comment or user)"
+//	@Success		200			{object}	object{reports=[]store.Report, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports [get]
+func (app *application) getReportsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+		Filters:      &store.GetReportsFilter{},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reports, metadata, err := app.store.Reports.GetAll(r.Context(), *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"reports": reports, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetReport godoc
+//
+//	@Summary		Fetches a report
+//	@Description	Fetches a report with its recorded moderation actions
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{object}	object{report=store.Report}
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{id} [get]
+func (app *application) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	report := getReportFromCtx(r)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ClaimReport godoc
+//
+//	@Summary		Claims a report
+//	@Description	Assigns an open report to the authenticated moderator
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{object}	object{report=store.Report}
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{id}/claim [put]
+func (app *application) claimReportHandler(w http.ResponseWriter, r *http.Request) {
+	report := getReportFromCtx(r)
+	moderator := getAuthUserFromCtx(r)
+
+	if err := app.store.Reports.Claim(r.Context(), report, moderator.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrReportClaimed), errors.Is(err, store.ErrReportResolved):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type resolveReportForm struct {
+	Action       string     `json:"action" validate:"required,oneof=dismiss hide_content warn_user suspend_user"`
+	Note         string     `json:"note" validate:"max=1000"`
+	SuspendUntil *time.Time `json:"suspend_until" validate:"omitempty"`
+}
+
+// ResolveReport godoc
+//
+//	@Summary		Resolves a report
+//	@Description	Resolves a report with a moderation action (dismiss, hide_content, warn_user or suspend_user) and records the resolution.
+//	@Description	suspend_user suspends the content owner, indefinitely unless suspend_until is given.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Report ID"
+//	@Param			payload	body		resolveReportForm	true	"Resolution"
+//	@Success		200		{object}	object{report=store.Report}
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{id}/resolve [put]
+func (app *application) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	var form resolveReportForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	report := getReportFromCtx(r)
+	moderator := getAuthUserFromCtx(r)
+
+	action := &store.ModerationAction{
+		ModeratorID: moderator.ID,
+		Action:      form.Action,
+		Note:        form.Note,
+	}
+
+	if form.Action == store.ModerationActionSuspendUser {
+		if form.SuspendUntil != nil && !form.SuspendUntil.After(time.Now()) {
+			app.failedValidationResponse(w, r, map[string]string{"suspend_until": "suspend_until must be in the future"})
+			return
+		}
+
+		reason := form.Note
+		if reason == "" {
+			reason = report.Reason
+		}
+
+		action.Suspension = &store.Suspension{
+			Reason: reason,
+			EndsAt: form.SuspendUntil,
+		}
+	}
+
+	if err := app.store.Reports.Resolve(r.Context(), report, action); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, store.ErrReportClaimed), errors.Is(err, store.ErrReportResolved):
+			app.conflictResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrModerationActionNotApplicable):
+			app.failedValidationResponse(w, r, map[string]string{"action": err.Error()})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) reportContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reportID, err := app.readIntID(r, "reportID")
+
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		report, err := app.store.Reports.GetById(r.Context(), reportID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), reportCtxKey, report)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getReportFromCtx(r *http.Request) *store.Report {
+	report, _ := r.Context().Value(reportCtxKey).(*store.Report)
+
+	return report
+}
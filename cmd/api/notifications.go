@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/events"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// notify records a notification for notification.UserID and pushes it to
+// their event stream. Users are never notified about their own actions, by
+// users they have a block with, or about posts they cannot see.
+// Failures are logged rather than failing the request that triggered the
+// notification.
+func (app *application) notify(ctx context.Context, notification *store.Notification) {
+	if notification.UserID == notification.ActorID {
+		return
+	}
+
+	if err := app.store.Notifications.Create(ctx, notification); err != nil {
+		if errors.Is(err, store.ErrNotificationSkipped) {
+			return
+		}
+
+		app.logger.Errorw("error creating notification",
+			"type", notification.Type, "user_id", notification.UserID, "error", err)
+		return
+	}
+
+	app.publishEvent(ctx, events.NotificationCreated, notification, notification.UserID)
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Lists notifications
+//	@Description	Lists the authenticated user's notifications, most recently updated first, along with the unread count
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'updated_at' or '-updated_at')"
+//	@Param			unread		query		bool	false	"Only list unread notifications"
+//	@Success		200			{object}	object{notifications=[]store.Notification, unread_count=int, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-updated_at",
+		SortSafelist: []string{"updated_at", "-updated_at"},
+		Filters:      &store.GetNotificationsFilter{},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getAuthUserFromCtx(r)
+
+	notifications, metadata, err := app.store.Notifications.GetByUserID(ctx, user.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	unread, err := app.store.Notifications.CountUnread(ctx, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"notifications": notifications,
+		"unread_count":  unread,
+		"metadata":      metadata,
+	}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// MarkNotificationRead godoc
+//
+//	@Summary		Marks a notification as read
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Notification ID"
+//	@Success		200	{object}	object{notification=store.Notification}
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{id}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := app.readIntID(r, "notificationID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	notification, err := app.store.Notifications.MarkRead(r.Context(), user.ID, notificationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"notification": notification}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// MarkAllNotificationsRead godoc
+//
+//	@Summary		Marks all notifications as read
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	object{updated=int}
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	updated, err := app.store.Notifications.MarkAllRead(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"updated": updated}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DeleteNotification godoc
+//
+//	@Summary		Deletes a notification
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"Notification ID"
+//	@Success		204
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{id} [delete]
+func (app *application) deleteNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := app.readIntID(r, "notificationID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	if err := app.store.Notifications.Delete(r.Context(), user.ID, notificationID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 
+	"github.com/devphaseX/mingle.git/internal/events"
 	"github.com/devphaseX/mingle.git/internal/store"
 )
 
@@ -24,7 +26,7 @@ type createPostForm struct {
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post. #hashtags in the title or content are added to the tags and @username mentions are recorded.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -58,6 +60,7 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		Tags:    form.Tags,
 		UserID:  user.ID,
 	}
+	withHashtags(post)
 
 	ctx := context.Background()
 	err = app.store.Posts.Create(ctx, post)
@@ -67,6 +70,9 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.syncPostMentions(ctx, post)
+	app.publishNewPost(post)
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"post": post}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -89,14 +95,20 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Router			/posts/{id} [get]
 func (app *application) getPostByIdHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	comments, err := app.cacheStorage.PostComments.Get(r.Context(), post.ID, func(ctx context.Context) ([]*store.Comment, error) {
+		return app.store.Comments.GetByPostID(ctx, post.ID)
+	})
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	post.Comments = comments
+	// The cached list keeps comments by suspended authors, so that it holds
+	// when a suspension runs out; they are left out here instead.
+	post.Comments = slices.DeleteFunc(comments, func(comment *store.Comment) bool {
+		return comment.User.IsSuspended()
+	})
 	err = app.writeJSON(w, http.StatusOK, envelope{"post": post}, nil)
 
 	if err != nil {
@@ -141,8 +153,9 @@ func (app *application) removePostByIdHandler(w http.ResponseWriter, r *http.Req
 }
 
 type updatePostForm struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=100"`
+	Title   *string   `json:"title" validate:"omitempty,max=100"`
+	Content *string   `json:"content" validate:"omitempty,max=100"`
+	Tags    *[]string `json:"tags"`
 }
 
 // UpdatePost godoc
@@ -184,6 +197,11 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Context = *form.Content
 	}
 
+	if form.Tags != nil {
+		post.Tags = *form.Tags
+	}
+	withHashtags(post)
+
 	ctx := context.Background()
 
 	err = app.store.Posts.UpdateByUser(ctx, post)
@@ -198,6 +216,9 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+
+	app.syncPostMentions(ctx, post)
+
 	app.writeJSON(w, http.StatusOK, envelope{"post": post}, nil)
 }
 
@@ -210,7 +231,8 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		post, err := app.store.Posts.GetById(r.Context(), postID)
+		user := getAuthUserFromCtx(r)
+		post, err := app.getVisiblePost(r.Context(), postID, user)
 		if err != nil {
 			if errors.Is(err, store.ErrNotFound) {
 				app.notFoundResponse(w, r)
@@ -233,3 +255,71 @@ func getPostFromCtx(r *http.Request) *store.Post {
 
 	return post
 }
+
+// publishNewPost pushes a new post to the live feeds of its author and their
+// followers. Fan-out runs in the background so large follower counts do not
+// slow down the request.
+func (app *application) publishNewPost(post *store.Post) {
+	app.background(func() {
+		ctx := context.Background()
+
+		followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+		if err != nil {
+			app.logger.Errorw("error loading followers for post fan-out", "post_id", post.ID, "error", err)
+			return
+		}
+
+		app.publishEvent(ctx, events.PostCreated, post, append(followerIDs, post.UserID)...)
+	})
+}
+
+// getVisiblePost returns a post through the cache, applying the same
+// visibility rules as PostStore.GetVisibleById: posts by suspended authors are
+// gone, and hidden posts and posts by private accounts the viewer does not
+// follow are visible only to their author. Moderators can also see hidden
+// posts, so they can review the reports against them.
+func (app *application) getVisiblePost(ctx context.Context, postID int64, viewer *store.User) (*store.Post, error) {
+	post, err := app.cacheStorage.Posts.Get(ctx, postID, func(ctx context.Context) (*store.Post, error) {
+		return app.store.Posts.GetById(ctx, postID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := app.getUser(ctx, post.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if author.IsSuspended() {
+		return nil, store.ErrNotFound
+	}
+
+	if post.UserID == viewer.ID {
+		return post, nil
+	}
+
+	if post.HiddenAt != nil {
+		moderator, err := app.checkRolePrecedence(ctx, viewer, "moderator")
+		if err != nil {
+			return nil, err
+		}
+
+		if !moderator {
+			return nil, store.ErrNotFound
+		}
+	}
+
+	if author.IsPrivate {
+		following, err := app.store.Followers.IsFollowing(ctx, post.UserID, viewer.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !following {
+			return nil, store.ErrNotFound
+		}
+	}
+
+	return post, nil
+}
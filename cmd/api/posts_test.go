@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/devphaseX/mingle.git/internal/store/cache"
+)
+
+func TestUpdatePost(t *testing.T) {
+	app := newTestApplication(t)
+
+	// A real cache, so a stale entry would be served back.
+	mockStore := store.NewMockStore()
+	app.cacheStorage = cache.NewLocalStorage(cache.Config{UserTTL: time.Minute, PostTTL: time.Minute, CommentsTTL: time.Minute, LocalSize: 100})
+	app.store = cache.Invalidating(mockStore, app.cacheStorage, app.logger)
+
+	ctx := context.Background()
+	if err := mockStore.Users.Create(ctx, &store.User{ID: 1, Username: "author", IsActive: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mockStore.Posts.Create(ctx, &store.Post{ID: 1, UserID: 1, Title: "first", Context: "old content"}); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newTestAccessToken(t, app, 1)
+	mux := app.mount()
+
+	getPost := func(t *testing.T) store.Post {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Post store.Post `json:"post"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Post
+	}
+
+	patchPost := func(t *testing.T, content string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPatch, "/v1/posts/1", strings.NewReader(`{"content":"`+content+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	}
+
+	t.Run("should serve the updated post after a patch", func(t *testing.T) {
+		if got := getPost(t).Context; got != "old content" {
+			t.Fatalf("content = %q, want %q", got, "old content")
+		}
+
+		patchPost(t, "new content")
+
+		post := getPost(t)
+		if post.Context != "new content" {
+			t.Errorf("content = %q, want %q", post.Context, "new content")
+		}
+		if post.Version != 1 {
+			t.Errorf("version = %d, want 1", post.Version)
+		}
+
+		// The cached version must have moved on too, or this fails the
+		// optimistic lock.
+		patchPost(t, "newer content")
+	})
+}
+
+func TestGetHiddenPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx := context.Background()
+	users := []*store.User{
+		{ID: 1, Username: "author", IsActive: true, Role: store.Role{Name: "user", Level: 1}},
+		{ID: 2, Username: "reader", IsActive: true, Role: store.Role{Name: "user", Level: 1}},
+		{ID: 3, Username: "moderator", IsActive: true, Role: store.Role{Name: "moderator", Level: 2}},
+	}
+	for _, user := range users {
+		if err := app.store.Users.Create(ctx, user, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hiddenAt := time.Now()
+	if err := app.store.Posts.Create(ctx, &store.Post{ID: 1, UserID: 1, Title: "hidden", HiddenAt: &hiddenAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	newTestAccessToken(t, app, 1)
+	mux := app.mount()
+
+	tests := []struct {
+		name   string
+		userID int64
+		want   int
+	}{
+		{"author", 1, http.StatusOK},
+		{"another user", 2, http.StatusNotFound},
+		{"moderator", 3, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := app.tokenMaker.GenerateAccessToken(tt.userID, "test-session", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestGetPostHidesCommentsBySuspendedAuthors(t *testing.T) {
+	app := newTestApplication(t)
+
+	// A real cache, so the second read is served the cached comment list.
+	mockStore := store.NewMockStore()
+	app.cacheStorage = cache.NewLocalStorage(cache.Config{UserTTL: time.Minute, PostTTL: time.Minute, CommentsTTL: time.Minute, LocalSize: 100})
+	app.store = cache.Invalidating(mockStore, app.cacheStorage, app.logger)
+
+	ctx := context.Background()
+	if err := mockStore.Users.Create(ctx, &store.User{ID: 1, Username: "author", IsActive: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mockStore.Posts.Create(ctx, &store.Post{ID: 1, UserID: 1, Title: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	endsAt := time.Now().Add(50 * time.Millisecond)
+	commenter := store.User{ID: 2, Username: "commenter", Suspension: &store.Suspension{UserID: 2, EndsAt: &endsAt}}
+	if err := mockStore.Comments.Create(ctx, &store.Comment{PostID: 1, UserID: 2, Content: "hello", User: commenter}); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newTestAccessToken(t, app, 1)
+	mux := app.mount()
+
+	countComments := func(t *testing.T) int {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Post store.Post `json:"post"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return len(body.Post.Comments)
+	}
+
+	if got := countComments(t); got != 0 {
+		t.Fatalf("got %d comments while the author is suspended, want 0", got)
+	}
+
+	time.Sleep(time.Until(endsAt))
+
+	if got := countComments(t); got != 1 {
+		t.Errorf("got %d comments once the suspension ran out, want 1", got)
+	}
+}
+
+// newTestAccessToken signs an access token for userID with a throwaway key
+// and switches app over to the matching token maker.
+func newTestAccessToken(t *testing.T, app *application, userID int64) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	secret := base64.StdEncoding.EncodeToString(key)
+
+	tokenMaker, err := store.NewTokenStore(secret, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.tokenMaker = tokenMaker
+
+	token, err := tokenMaker.GenerateAccessToken(userID, "test-session", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type updatePreferencesForm struct {
+	Channels        map[string]string `json:"channels" validate:"omitempty,dive,keys,oneof=follow follow_accepted comment reply mention,endkeys,oneof=in_app email off"`
+	DigestFrequency *string           `json:"digest_frequency" validate:"omitempty,oneof=daily weekly off"`
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Fetches notification preferences
+//	@Description	Fetches the channel for each notification type (in_app, email or off) and the digest email frequency (daily, weekly or off)
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	object{preferences=store.NotificationPreferences}
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	preferences, err := app.store.NotificationPreferences.Get(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"preferences": preferences}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateNotificationPreferences godoc
+//
+//	@Summary		Updates notification preferences
+//	@Description	Sets the channel for the given notification types and, optionally, the digest frequency. Types left out keep their current channel.
+//	@Description	in_app only shows notifications in the app, email also includes them in digest emails and off stops them altogether.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		updatePreferencesForm	true	"Preferences"
+//	@Success		200		{object}	object{preferences=store.NotificationPreferences}
+//	@Failure		400		{object}	error
+//	@Failure		422		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [patch]
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var form updatePreferencesForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.NotificationPreferences.Update(ctx, user.ID, form.Channels, form.DigestFrequency); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	preferences, err := app.store.NotificationPreferences.Get(ctx, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"preferences": preferences}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// unsubscribe applies an unsubscribe link: the digest scope turns digest
+// emails off and a notification type scope keeps that type in the app only.
+func (app *application) unsubscribe(r *http.Request, userID int64, scope string) error {
+	if scope == unsubscribeDigest {
+		off := store.DigestOff
+		return app.store.NotificationPreferences.Update(r.Context(), userID, nil, &off)
+	}
+
+	channels := map[string]string{scope: store.NotificationChannelInApp}
+	return app.store.NotificationPreferences.Update(r.Context(), userID, channels, nil)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/ratelimiter"
+)
+
+type rateLimitQuota struct {
+	Policy    string `json:"policy"`
+	Strategy  string `json:"strategy"`
+	Limit     int    `json:"limit"`
+	Window    int    `json:"window_seconds"`
+	Remaining int    `json:"remaining"`
+	Reset     int    `json:"reset_seconds"`
+}
+
+// GetRateLimits godoc
+//
+//	@Summary		Fetches the caller's rate limit quotas
+//	@Description	Reports the caller's standing under every rate limit policy without counting against them, other than the read policy for this request itself.
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	object{enabled=bool, rate_limits=[]rateLimitQuota}
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/rate-limits [get]
+func (app *application) getRateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	quotas := []rateLimitQuota{}
+
+	if app.config.rateLimiter.Enabled {
+		for _, q := range app.rateLimiter.Quotas(app.rateLimitKey(r)) {
+			quotas = append(quotas, rateLimitQuota{
+				Policy:    q.Policy.Name,
+				Strategy:  q.Policy.Strategy,
+				Limit:     q.Limit,
+				Window:    seconds(q.Policy.Window),
+				Remaining: q.Remaining,
+				Reset:     seconds(q.Reset),
+			})
+		}
+	}
+
+	data := envelope{"enabled": app.config.rateLimiter.Enabled, "rate_limits": quotas}
+	if err := app.writeJSON(w, http.StatusOK, data, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setRateLimitHeaders reports a quota in the RateLimit-* headers of the IETF
+// httpapi draft. When several policies apply to a request, the one closest
+// to running out is reported.
+func setRateLimitHeaders(w http.ResponseWriter, policy ratelimiter.Policy, res ratelimiter.Result) {
+	h := w.Header()
+
+	if current := h.Get("RateLimit-Remaining"); current != "" {
+		if n, err := strconv.Atoi(current); err == nil && n < res.Remaining {
+			return
+		}
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, seconds(policy.Window)))
+}
+
+// seconds rounds d up to whole seconds, so clients waiting that long are
+// never early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
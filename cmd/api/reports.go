@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type createReportForm struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetID   int64  `json:"target_id" validate:"required,gte=1"`
+	Reason     string `json:"reason" validate:"required,min=1,max=1000"`
+}
+
+// CreateReport godoc
+//
+//	@Summary		Reports content
+//	@Description	Reports a post, comment or user to the moderation queue
+//	@Tags			reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		createReportForm		true	"Report payload"
+//	@Success		201		{object}	object{report=store.Report}
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/reports [post]
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var form createReportForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	report := &store.Report{
+		ReporterID: user.ID,
+		TargetType: form.TargetType,
+		TargetID:   form.TargetID,
+		Reason:     form.Reason,
+	}
+
+	if err := app.store.Reports.Create(r.Context(), report); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, "you have already reported this content")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/devphaseX/mingle.git/internal/search"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// Search godoc
+//
+//	@Summary		Searches posts, comments, users and tags
+//	@Description	Runs a full-text search, falling back to fuzzy matching for near misses, and returns one page of results per type ranked by relevance. Titles, names and snippets are HTML-escaped with matches wrapped in <mark> tags. Content the viewer cannot see is left out.
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q			query		string	true	"Search query; supports quoted phrases, OR and -exclusions"
+//	@Param			type		query		string	false	"Comma-separated result types: posts, comments, users, tags (default: all)"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of results per type and page (default: 20)"
+//	@Success		200			{object}	object{query=string,results=object}
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+	filter := &store.SearchFilter{}
+
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-rank",
+		SortSafelist: []string{"-rank"},
+		Filters:      filter,
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	types := store.SearchTypes
+	if len(filter.Types) > 0 {
+		types = filter.Types
+	}
+
+	var (
+		ctx     = r.Context()
+		results = make(map[string]envelope, len(types))
+	)
+
+	for _, searchType := range slices.Compact(slices.Sorted(slices.Values(types))) {
+		var (
+			items    any
+			metadata store.Metadata
+			err      error
+		)
+
+		switch searchType {
+		case store.SearchPosts, store.SearchUsers:
+			var hits []search.Hit
+			hits, metadata, err = app.search.Search(ctx, search.Query{
+				Type:     searchType,
+				Text:     filter.Query,
+				ViewerID: user.ID,
+				Paginate: *fq,
+			})
+			items = search.Sources(hits)
+		case store.SearchComments:
+			items, metadata, err = app.store.Search.SearchComments(ctx, user.ID, filter.Query, *fq)
+		case store.SearchTags:
+			items, metadata, err = app.store.Search.SearchTags(ctx, filter.Query, *fq)
+		}
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		results[searchType] = envelope{"items": items, "metadata": metadata}
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"query": filter.Query, "results": results}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type suspendUserForm struct {
+	Reason string     `json:"reason" validate:"required,min=1,max=1000"`
+	Until  *time.Time `json:"until" validate:"omitempty"`
+}
+
+// SuspendUser godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Suspends a user with a reason and an optional end time (RFC3339). The user's sessions are revoked and their content is hidden while suspended.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int								true	"User ID"
+//	@Param			payload	body		suspendUserForm					true	"Suspension"
+//	@Success		201		{object}	object{suspension=store.Suspension}
+//	@Failure		400		{object}	object{error=string}
+//	@Failure		403		{object}	object{error=string}
+//	@Failure		404		{object}	object{error=string}
+//	@Failure		500		{object}	object{error=string}
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/suspend [put]
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	var form suspendUserForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	if form.Until != nil && !form.Until.After(time.Now()) {
+		app.failedValidationResponse(w, r, map[string]string{"until": "until must be in the future"})
+		return
+	}
+
+	user := getUserFromCtx(r)
+	admin := getAuthUserFromCtx(r)
+
+	if user.ID == admin.ID {
+		app.errorResponse(w, r, http.StatusBadRequest, "you cannot suspend your own account")
+		return
+	}
+
+	suspension := &store.Suspension{
+		UserID:      user.ID,
+		SuspendedBy: &admin.ID,
+		Reason:      form.Reason,
+		EndsAt:      form.Until,
+	}
+
+	if err := app.store.Suspensions.Suspend(r.Context(), suspension); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"suspension": suspension}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UnsuspendUser godoc
+//
+//	@Summary		Lifts a user's suspension
+//	@Description	Lifts the suspension in force for a user before its end time
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID"
+//	@Success		204	"Suspension lifted"
+//	@Failure		403	{object}	object{error=string}
+//	@Failure		404	{object}	object{error=string}	"User is not suspended"
+//	@Failure		500	{object}	object{error=string}
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/unsuspend [put]
+func (app *application) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	admin := getAuthUserFromCtx(r)
+
+	if err := app.store.Suspensions.Lift(r.Context(), user.ID, admin.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetUserSuspension godoc
+//
+//	@Summary		Fetches a user's suspension
+//	@Description	Fetches the suspension in force for a user, including its reason and the suspending admin
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int										true	"User ID"
+//	@Success		200	{object}	object{suspension=store.Suspension}
+//	@Failure		403	{object}	object{error=string}
+//	@Failure		404	{object}	object{error=string}	"User is not suspended"
+//	@Failure		500	{object}	object{error=string}
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/suspension [get]
+func (app *application) getUserSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if !user.IsSuspended() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"suspension": user.Suspension}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
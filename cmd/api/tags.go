@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxTrendingWindow = time.Hour * 24 * 7
+
+// GetTrendingTags godoc
+//
+//	@Summary		Lists trending tags
+//	@Description	Ranks tags on recent public posts, with newer posts weighing more than older ones
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"How far back to look, as a duration (default: 24h, max: 168h)"
+//	@Param			limit	query		int		false	"Number of tags to return (default: 10, max: 50)"
+//	@Success		200		{object}	object{tags=[]store.TrendingTag}
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		qs     = r.URL.Query()
+		window = app.config.tags.trendingWindow
+		limit  = 10
+		errs   validator.ValidationErrors
+	)
+
+	if v := qs.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		switch {
+		case err != nil:
+			errs.AddFieldError("window", "must be a duration such as 24h")
+		case d <= 0 || d > maxTrendingWindow:
+			errs.AddFieldError("window", "must be between 0 and 168h")
+		default:
+			window = d
+		}
+	}
+
+	if v := qs.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			errs.AddFieldError("limit", "must be an integer")
+		case n < 1 || n > 50:
+			errs.AddFieldError("limit", "must be between 1 and 50")
+		default:
+			limit = n
+		}
+	}
+
+	if errs.Error() != "" {
+		app.badRequestResponse(w, r, &errs)
+		return
+	}
+
+	tags, err := app.store.Tags.GetTrending(r.Context(), window, app.config.tags.trendingHalfLife, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches a tag timeline
+//	@Description	Fetches public posts with the given tag, with the same pagination and filtering as the user feed
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag			path		string	true	"Tag"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at', default: '-created_at')"
+//	@Param			search		query		string	false	"Search term"
+//	@Param			tags		query		string	false	"Comma-separated list of additional tags to filter by"
+//	@Param			since		query		string	false	"Only posts created at or after this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			until		query		string	false	"Only posts created at or before this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			author		query		string	false	"Comma-separated list of author usernames"
+//	@Param			has_comments	query	bool	false	"Only posts with (true) or without (false) comments"
+//	@Success		200			{object}	object{posts=[]store.PostWithMetadata, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	// Tags are stored lowercased, so Go and go share a page.
+	tag := strings.ToLower(chi.URLParam(r, "tag"))
+
+	fq := newFeedQueryFilter()
+	fq.Sort = "-created_at"
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, metadata, err := app.store.Tags.GetPosts(r.Context(), tag, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"posts": posts, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/devphaseX/mingle.git/internal/events"
 	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/devphaseX/mingle.git/internal/store/cache"
 	"go.uber.org/zap"
@@ -21,9 +22,10 @@ func newTestApplication(t *testing.T) *application {
 	testAuth := &store.TestTokenStore{}
 	return &application{
 		logger:       logger,
-		store:        mockStore,
+		store:        cache.Invalidating(mockStore, mockCacheStore, logger),
 		cacheStorage: mockCacheStore,
 		tokenMaker:   testAuth,
+		events:       events.NewHub(0),
 	}
 }
 
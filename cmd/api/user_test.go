@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/devphaseX/mingle.git/internal/store"
 )
 
 func TestGetUser(t *testing.T) {
@@ -38,3 +42,35 @@ func TestGetUser(t *testing.T) {
 	// 	checkResponseCode(t, http.StatusOK, rr.Code)
 	// })
 }
+
+func TestGetUserHidesSuspension(t *testing.T) {
+	app := newTestApplication(t)
+	mockStore := store.NewMockStore()
+	app.store = mockStore
+
+	ctx := context.Background()
+	if err := mockStore.Users.Create(ctx, &store.User{ID: 1, Username: "viewer", IsActive: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	adminID := int64(3)
+	suspended := &store.User{ID: 2, Username: "suspended", Suspension: &store.Suspension{UserID: 2, SuspendedBy: &adminID, Reason: "spam"}}
+	if err := mockStore.Users.Create(ctx, suspended, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newTestAccessToken(t, app, 1)
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/users/2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusOK, rr.Code)
+
+	if body := rr.Body.String(); strings.Contains(body, "suspension") || strings.Contains(body, "spam") {
+		t.Errorf("public profile leaks the suspension: %s", body)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -38,15 +39,93 @@ func (app *application) getUserByIdHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// GetUserPosts godoc
+//
+//	@Summary		Fetches a user's posts
+//	@Description	Lists one user's posts, newest first, with the same pagination and filters as the feed. Private accounts' posts are only shown to their followers.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"User ID"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at', default: '-created_at')"
+//	@Param			cursor		query		string	false	"Cursor from a previous page's metadata; pass it empty to start paging with cursors"
+//	@Param			search		query		string	false	"Search term"
+//	@Param			tags		query		string	false	"Comma-separated list of tags to filter by"
+//	@Param			since		query		string	false	"Only posts created at or after this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			until		query		string	false	"Only posts created at or before this time (RFC 3339, or YYYY-MM-DD HH:MM:SS in UTC)"
+//	@Param			has_comments	query	bool	false	"Only posts with (true) or without (false) comments"
+//	@Success		200			{object}	object{posts=[]store.PostWithMetadata, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	author := getUserFromCtx(r)
+	viewer := getAuthUserFromCtx(r)
+	ctx := r.Context()
+
+	fq := newFeedQueryFilter()
+	fq.Sort = "-created_at"
+	fq.CursorSigner = app.signer
+	fq.CursorPurpose = store.UserPostsCursor
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if author.ID != viewer.ID {
+		blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, author.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if blocked {
+			app.blockedResponse(w, r)
+			return
+		}
+
+		if author.IsPrivate {
+			following, err := app.store.Followers.IsFollowing(ctx, author.ID, viewer.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if !following {
+				app.privateAccountResponse(w, r)
+				return
+			}
+		}
+	}
+
+	posts, metadata, err := app.store.Posts.GetUserPosts(ctx, author.ID, viewer.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"posts": posts, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // FollowUser godoc
 //
 //	@Summary		Follow a user
-//	@Description	Follow a user by their ID. The follower ID is hardcoded to 1 for this example.
+//	@Description	Follow a user by their ID. Following a private account creates a pending follow request instead.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int										true	"User ID of the user to follow"
 //	@Success		201	{object}	object{follower=store.Follower}			"Successfully followed the user"
+//	@Success		202	{object}	object{follow_request=store.FollowRequest}	"Follow request sent to a private account"
+//	@Failure		403	{object}	object{error=object{message=string}}	"Forbidden - The users have blocked each other"
 //	@Failure		409	{object}	object{error=object{message=string}}	"Conflict - Already following this user"
 //	@Failure		500	{object}	object{error=object{message=string}}	"Internal server error"
 //	@Security		ApiKeyAuth
@@ -57,12 +136,28 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := context.Background()
 
+	blocked, err := app.store.Blocks.IsBlocked(ctx, authUser.ID, followedUser.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.blockedResponse(w, r)
+		return
+	}
+
+	if followedUser.IsPrivate && followedUser.ID != authUser.ID {
+		app.requestFollow(w, r, followedUser, authUser)
+		return
+	}
+
 	follower := &store.Follower{
 		UserID:     followedUser.ID,
 		FollowerID: authUser.ID,
 	}
 
-	err := app.store.Followers.FollowUser(ctx, follower)
+	err = app.store.Followers.FollowUser(ctx, follower)
 
 	if err != nil {
 		switch {
@@ -75,6 +170,12 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.notify(ctx, &store.Notification{
+		UserID:  followedUser.ID,
+		ActorID: authUser.ID,
+		Type:    store.NotificationFollow,
+	})
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"follower": follower}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -84,7 +185,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser godoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by their ID. The follower ID is hardcoded to 1 for this example.
+//	@Description	Unfollow a user by their ID, or withdraw a pending follow request.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -102,6 +203,11 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 
 	err := app.store.Followers.UnFollowUser(ctx, unfollowedUser.ID, authUser.ID)
 
+	if errors.Is(err, store.ErrNotFound) {
+		// not following yet, withdraw a pending follow request if there is one
+		err = app.store.Followers.RejectFollowRequest(ctx, unfollowedUser.ID, authUser.ID)
+	}
+
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -119,6 +225,248 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, followedUser, authUser *store.User) {
+	ctx := r.Context()
+
+	following, err := app.store.Followers.IsFollowing(ctx, followedUser.ID, authUser.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if following {
+		app.errorResponse(w, r, http.StatusConflict, "following this user already")
+		return
+	}
+
+	request := &store.FollowRequest{
+		UserID:      followedUser.ID,
+		RequesterID: authUser.ID,
+	}
+
+	if err := app.store.Followers.RequestFollow(ctx, request); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.errorResponse(w, r, http.StatusConflict, "follow request already sent")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"follow_request": request}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		Lists pending follow requests
+//	@Description	Lists follow requests waiting for the authenticated user's approval
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
+//	@Success		200			{object}	object{follow_requests=[]store.FollowRequest, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	requests, metadata, err := app.store.Followers.GetFollowRequests(r.Context(), user.ID, *fq)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"follow_requests": requests, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetSessions godoc
+//
+//	@Summary		Lists sign-in sessions
+//	@Description	Lists the authenticated user's sessions, newest first. Pass an empty cursor to page with cursors instead, then follow next_cursor and prev_cursor from the metadata.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
+//	@Param			cursor		query		string	false	"Cursor from a previous page's metadata"
+//	@Success		200			{object}	object{sessions=[]store.Session, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:          1,
+		PageSize:      20,
+		Sort:          "-created_at",
+		SortSafelist:  []string{"created_at", "-created_at"},
+		CursorSigner:  app.signer,
+		CursorPurpose: store.SessionsCursor,
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	sessions, metadata, err := app.store.Sessions.GetSessionsByUserID(r.Context(), strconv.FormatInt(user.ID, 10), false, *fq)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approve a follow request
+//	@Description	Approves a pending follow request sent by the user with the given ID and notifies them
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int								true	"User ID of the requester"
+//	@Success		201	{object}	object{follower=store.Follower}	"Follow request approved"
+//	@Failure		404	{object}	object{error=string}			"No pending follow request"
+//	@Failure		500	{object}	object{error=string}			"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/follow-request/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requester := getUserFromCtx(r)
+	authUser := getAuthUserFromCtx(r)
+
+	follower, err := app.store.Followers.AcceptFollowRequest(r.Context(), authUser.ID, requester.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notify(r.Context(), &store.Notification{
+		UserID:  requester.ID,
+		ActorID: authUser.ID,
+		Type:    store.NotificationFollowAccepted,
+	})
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"follower": follower}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Reject a follow request
+//	@Description	Rejects a pending follow request sent by the user with the given ID
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"User ID of the requester"
+//	@Success		204	"Follow request rejected"
+//	@Failure		404	{object}	object{error=string}	"No pending follow request"
+//	@Failure		500	{object}	object{error=string}	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/follow-request/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requester := getUserFromCtx(r)
+	authUser := getAuthUserFromCtx(r)
+
+	err := app.store.Followers.RejectFollowRequest(r.Context(), authUser.ID, requester.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type updatePrivacyForm struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// UpdatePrivacy godoc
+//
+//	@Summary		Updates account privacy
+//	@Description	Makes the authenticated user's account private or public. Going public accepts all pending follow requests.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		updatePrivacyForm		true	"Privacy setting"
+//	@Success		200		{object}	object{user=store.User}	"Updated user"
+//	@Failure		400		{object}	object{error=string}	"Bad request"
+//	@Failure		500		{object}	object{error=string}	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/privacy [patch]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var form updatePrivacyForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Users.SetPrivacy(r.Context(), user.ID, *form.IsPrivate); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.IsPrivate = *form.IsPrivate
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userId, err := app.readIntID(r, "userID")
@@ -170,7 +518,7 @@ func getUserFromCtx(r *http.Request) *store.User {
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
-	err := app.store.Users.Activate(r.Context(), token)
+	_, err := app.store.Users.Activate(r.Context(), token)
 
 	if err != nil {
 		switch {
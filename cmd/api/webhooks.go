@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/devphaseX/mingle.git/internal/jobs"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type webhookContextKey string
+
+var (
+	webhookCtxKey webhookContextKey = "webhook"
+)
+
+type createWebhookForm struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=user.created user.activated user.followed post.created post.updated post.deleted comment.created"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=128"`
+}
+
+type updateWebhookForm struct {
+	URL      *string  `json:"url" validate:"omitempty,url,max=2048"`
+	Events   []string `json:"events" validate:"omitempty,min=1,dive,oneof=user.created user.activated user.followed post.created post.updated post.deleted comment.created"`
+	Secret   *string  `json:"secret" validate:"omitempty,min=16,max=128"`
+	IsActive *bool    `json:"is_active"`
+}
+
+type webhookJob struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// deliverWebhookJob sends one webhook delivery. Failed attempts are returned
+// as errors so the job queue retries them with backoff.
+func (app *application) deliverWebhookJob(ctx context.Context, payload json.RawMessage) error {
+	var job webhookJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding webhook job: %w", err))
+	}
+
+	delivery, webhook, err := app.store.Webhooks.GetDelivery(ctx, job.DeliveryID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// The webhook was deleted along with its deliveries.
+			return nil
+		}
+		return err
+	}
+
+	if !webhook.IsActive || delivery.Status == store.WebhookDeliverySucceeded {
+		return nil
+	}
+
+	attempt, err := app.webhooks.Deliver(ctx, webhook, delivery)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	if err := app.store.Webhooks.RecordAttempt(ctx, delivery.ID, attempt); err != nil {
+		app.logger.Errorw("error recording webhook attempt", "delivery_id", delivery.ID, "error", err)
+	}
+
+	if !attempt.Succeeded {
+		return errors.New(*attempt.Error)
+	}
+
+	return nil
+}
+
+// CreateWebhook godoc
+//
+//	@Summary		Creates a webhook
+//	@Description	Subscribes a URL to one or more event types. Each delivery is a POST signed with the webhook secret in the X-Mingle-Signature header as t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+//	@Description	A secret is generated when none is given. It is only returned in this response.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		createWebhookForm	true	"Webhook"
+//	@Success		201		{object}	object{webhook=store.Webhook, secret=string}
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		422		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks [post]
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var form createWebhookForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	if err := app.webhooks.CheckURL(r.Context(), form.URL); err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"url": err.Error()})
+		return
+	}
+
+	secret := form.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateWebhookSecret(); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	user := getAuthUserFromCtx(r)
+	webhook := &store.Webhook{
+		URL:       form.URL,
+		Events:    form.Events,
+		Secret:    secret,
+		IsActive:  true,
+		CreatedBy: &user.ID,
+	}
+
+	if err := app.store.Webhooks.Create(r.Context(), webhook); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook, "secret": secret}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetWebhooks godoc
+//
+//	@Summary		Lists webhooks
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	object{webhooks=[]store.Webhook}
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks [get]
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.store.Webhooks.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetWebhook godoc
+//
+//	@Summary		Fetches a webhook
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Webhook ID"
+//	@Success		200	{object}	object{webhook=store.Webhook}
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{id} [get]
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook := getWebhookFromCtx(r)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateWebhook godoc
+//
+//	@Summary		Updates a webhook
+//	@Description	Changes a webhook's URL, events, secret or active state. Inactive webhooks receive no new deliveries and pending ones are skipped.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Webhook ID"
+//	@Param			payload	body		updateWebhookForm	true	"Webhook changes"
+//	@Success		200		{object}	object{webhook=store.Webhook}
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		422		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{id} [patch]
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var form updateWebhookForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(form); err != nil {
+		app.failedValidationResponse(w, r, err.FieldErrors())
+		return
+	}
+
+	webhook := getWebhookFromCtx(r)
+
+	if form.URL != nil {
+		if err := app.webhooks.CheckURL(r.Context(), *form.URL); err != nil {
+			app.failedValidationResponse(w, r, map[string]string{"url": err.Error()})
+			return
+		}
+		webhook.URL = *form.URL
+	}
+
+	if form.Events != nil {
+		webhook.Events = form.Events
+	}
+
+	if form.Secret != nil {
+		webhook.Secret = *form.Secret
+	}
+
+	if form.IsActive != nil {
+		webhook.IsActive = *form.IsActive
+	}
+
+	if err := app.store.Webhooks.Update(r.Context(), webhook); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Deletes a webhook
+//	@Description	Deletes a webhook and its delivery log
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	int	true	"Webhook ID"
+//	@Success		204
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{id} [delete]
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook := getWebhookFromCtx(r)
+
+	if err := app.store.Webhooks.Delete(r.Context(), webhook.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		Lists a webhook's deliveries
+//	@Description	Lists deliveries with the outcome of their latest attempt, newest first by default
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Webhook ID"
+//	@Param			page		query		int		false	"Page number (default: 1)"
+//	@Param			page_size	query		int		false	"Number of items per page (default: 20)"
+//	@Param			sort		query		string	false	"Sort order (e.g., 'created_at' or '-created_at')"
+//	@Param			status		query		string	false	"Delivery status (pending, succeeded or failed)"
+//	@Success		200			{object}	object{deliveries=[]store.WebhookDelivery, metadata=store.Metadata}
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{id}/deliveries [get]
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+		Filters:      &store.GetWebhookDeliveriesFilter{},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := getWebhookFromCtx(r)
+
+	deliveries, metadata, err := app.store.Webhooks.GetDeliveries(r.Context(), webhook.ID, *fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// RedeliverWebhook godoc
+//
+//	@Summary		Redelivers a webhook delivery
+//	@Description	Queues a delivery to be sent again, whatever the outcome of earlier attempts
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int	true	"Webhook ID"
+//	@Param			deliveryID	path		int	true	"Delivery ID"
+//	@Success		202			{object}	object{delivery=store.WebhookDelivery}
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{id}/deliveries/{deliveryID}/redeliver [post]
+func (app *application) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := app.readIntID(r, "deliveryID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := getWebhookFromCtx(r)
+
+	delivery, err := app.store.Webhooks.Redeliver(r.Context(), webhook.ID, deliveryID, jobs.DeliverWebhook, app.config.webhooks.maxAttempts)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.jobs.Wake()
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"delivery": delivery}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) webhookContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhookID, err := app.readIntID(r, "webhookID")
+
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		webhook, err := app.store.Webhooks.GetById(r.Context(), webhookID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), webhookCtxKey, webhook)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWebhookFromCtx(r *http.Request) *store.Webhook {
+	webhook, _ := r.Context().Value(webhookCtxKey).(*store.Webhook)
+
+	return webhook
+}
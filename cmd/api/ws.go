@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketWriteWait      = 10 * time.Second
+	socketPongWait       = 60 * time.Second
+	socketPingInterval   = (socketPongWait * 9) / 10
+	socketMaxMessageSize = 8 * 1024
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Sockets are authenticated with an access token rather than cookies, so
+	// a cross-site page cannot open one on a user's behalf.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// socketFrame is sent to the client, either an event from the user's stream
+// or a reply to one of their commands.
+type socketFrame struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// socketCommand is sent by the client. Type is "message.send" or
+// "conversation.read". ClientID is echoed back in the reply so the client can
+// match it to the command.
+type socketCommand struct {
+	Type           string `json:"type"`
+	ClientID       string `json:"client_id"`
+	ConversationID int64  `json:"conversation_id"`
+	Content        string `json:"content"`
+	MessageID      int64  `json:"message_id"`
+}
+
+// ConversationSocket godoc
+//
+//	@Summary		Opens a messaging WebSocket
+//	@Description	Upgrades to a WebSocket that delivers the user's events, including new messages, as JSON frames {id, type, data}.
+//	@Description	Clients send {"type": "message.send", "conversation_id", "content", "client_id"} or {"type": "conversation.read", "conversation_id", "message_id"}.
+//	@Description	The access token may be passed as the access_token query parameter. Reconnecting clients pass last_event_id to resume.
+//	@Tags			conversations
+//	@Param			access_token	query	string	false	"Access token, when the Authorization header cannot be set"
+//	@Param			last_event_id	query	string	false	"Resume after this event ID"
+//	@Success		101
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/ws [get]
+func (app *application) conversationSocketHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		app.logger.Warnw("websocket upgrade failed", "user_id", user.ID, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stream, err := app.events.Subscribe(ctx, user.ID, r.URL.Query().Get("last_event_id"))
+	if err != nil {
+		app.logger.Errorw("error subscribing websocket", "user_id", user.ID, "error", err)
+		app.closeSocket(conn, websocket.CloseInternalServerErr, "unable to subscribe")
+		return
+	}
+
+	replies := make(chan socketFrame, 16)
+	go app.readSocket(ctx, cancel, conn, user, replies)
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		var frame socketFrame
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.shutdown:
+			app.closeSocket(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+			continue
+		case event, ok := <-stream:
+			if !ok {
+				app.closeSocket(conn, websocket.CloseTryAgainLater, "fell behind, reconnect with last_event_id")
+				return
+			}
+			frame = socketFrame{ID: event.ID, Type: event.Type, Data: event.Data}
+		case frame = <-replies:
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		if err := conn.WriteJSON(frame); err != nil {
+			return
+		}
+	}
+}
+
+// readSocket handles commands from the client until the connection fails,
+// then cancels the connection's context.
+func (app *application) readSocket(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, user *store.User, replies chan<- socketFrame) {
+	defer cancel()
+
+	conn.SetReadLimit(socketMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		var cmd socketCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				app.logger.Infow("websocket read failed", "user_id", user.ID, "error", err)
+			}
+			return
+		}
+
+		reply := app.handleSocketCommand(ctx, user, cmd)
+
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (app *application) handleSocketCommand(ctx context.Context, user *store.User, cmd socketCommand) socketFrame {
+	fail := func(message string) socketFrame {
+		return socketFrame{Type: "error", Data: envelope{"client_id": cmd.ClientID, "error": message}}
+	}
+
+	switch cmd.Type {
+	case "message.send":
+		if err := Validate.Struct(messageForm{Content: cmd.Content}); err != nil {
+			return socketFrame{Type: "error", Data: envelope{"client_id": cmd.ClientID, "error": err.FieldErrors()}}
+		}
+
+		message, err := app.sendMessage(ctx, user, cmd.ConversationID, cmd.Content)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrBlocked):
+				return fail("this action is not available between users who have blocked each other")
+			case errors.Is(err, store.ErrNotFound):
+				return fail("conversation not found")
+			default:
+				app.logger.Errorw("error sending message", "user_id", user.ID, "error", err)
+				return fail("the server encountered a problem and could not process your request")
+			}
+		}
+
+		return socketFrame{Type: "message.sent", Data: envelope{"client_id": cmd.ClientID, "message": message}}
+
+	case "conversation.read":
+		lastRead, err := app.markConversationRead(ctx, user.ID, cmd.ConversationID, cmd.MessageID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return fail("conversation not found")
+			default:
+				app.logger.Errorw("error marking conversation read", "user_id", user.ID, "error", err)
+				return fail("the server encountered a problem and could not process your request")
+			}
+		}
+
+		return socketFrame{Type: "conversation.marked_read", Data: envelope{
+			"client_id":            cmd.ClientID,
+			"conversation_id":      cmd.ConversationID,
+			"last_read_message_id": lastRead,
+		}}
+
+	default:
+		return fail("unknown command type")
+	}
+}
+
+func (app *application) closeSocket(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(socketWriteWait))
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS moderation_actions;
+
+DROP TABLE IF EXISTS reports;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS hidden_at;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS hidden_at;
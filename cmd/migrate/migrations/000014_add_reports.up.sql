@@ -0,0 +1,58 @@
+ALTER TABLE posts
+ADD COLUMN hidden_at timestamp(0)
+with
+    time zone;
+
+ALTER TABLE comments
+ADD COLUMN hidden_at timestamp(0)
+with
+    time zone;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id bigserial PRIMARY KEY,
+    reporter_id bigint NOT NULL,
+    target_type varchar(20) NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+    target_id bigint NOT NULL,
+    reason text NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by bigint,
+    claimed_at timestamp(0)
+    with
+        time zone,
+        resolved_at timestamp(0)
+    with
+        time zone,
+        created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (claimed_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- A user can only have one unresolved report per target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_unresolved_target ON reports (reporter_id, target_type, target_id)
+WHERE
+    status <> 'resolved';
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, created_at);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id bigserial PRIMARY KEY,
+    report_id bigint NOT NULL,
+    moderator_id bigint NOT NULL,
+    subject_user_id bigint,
+    action varchar(20) NOT NULL CHECK (
+        action IN ('dismiss', 'hide_content', 'warn_user', 'suspend_user')
+    ),
+    note text NOT NULL DEFAULT '',
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE CASCADE,
+        FOREIGN KEY (moderator_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (subject_user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_report_id ON moderation_actions (report_id);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_subject_user_id ON moderation_actions (subject_user_id);
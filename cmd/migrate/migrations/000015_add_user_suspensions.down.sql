@@ -0,0 +1,4 @@
+ALTER TABLE moderation_actions
+DROP COLUMN IF EXISTS suspension_id;
+
+DROP TABLE IF EXISTS user_suspensions;
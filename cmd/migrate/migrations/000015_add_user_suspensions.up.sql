@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    suspended_by bigint,
+    reason text NOT NULL,
+    starts_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        ends_at timestamp(0)
+    with
+        time zone,
+        lifted_at timestamp(0)
+    with
+        time zone,
+        lifted_by bigint,
+        created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (suspended_by) REFERENCES users (id) ON DELETE SET NULL,
+        FOREIGN KEY (lifted_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user_id ON user_suspensions (user_id)
+WHERE
+    lifted_at IS NULL;
+
+ALTER TABLE moderation_actions
+ADD COLUMN suspension_id bigint REFERENCES user_suspensions (id) ON DELETE SET NULL;
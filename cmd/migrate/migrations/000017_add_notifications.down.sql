@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,38 @@
+ALTER TABLE comments
+ADD COLUMN parent_id bigint REFERENCES comments (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    actor_id bigint NOT NULL,
+    type varchar(20) NOT NULL CHECK (
+        type IN ('follow', 'follow_accepted', 'comment', 'reply', 'mention')
+    ),
+    post_id bigint,
+    comment_id bigint,
+    count int NOT NULL DEFAULT 1,
+    read_at timestamp(0)
+    with
+        time zone,
+        created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        updated_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+        FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+-- Repeated events from the same actor on the same post collapse into one notification
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedupe ON notifications (user_id, type, actor_id, post_id) NULLS NOT DISTINCT;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, updated_at);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id)
+WHERE
+    read_at IS NULL;
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    user_id bigint NOT NULL,
+    blocked_id bigint NOT NULL,
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (user_id, blocked_id),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id bigserial PRIMARY KEY,
+    is_group BOOLEAN NOT NULL DEFAULT FALSE,
+    title varchar(100) NOT NULL DEFAULT '',
+    -- "<lower user id>:<higher user id>" for 1:1 conversations so each pair has only one
+    direct_key varchar(50) UNIQUE,
+    created_by bigint,
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        updated_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    last_read_message_id bigint NOT NULL DEFAULT 0,
+    joined_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (conversation_id, user_id),
+        FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id bigserial PRIMARY KEY,
+    conversation_id bigint NOT NULL,
+    sender_id bigint NOT NULL,
+    content text NOT NULL,
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+        FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id, id);
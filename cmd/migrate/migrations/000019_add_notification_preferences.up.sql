@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id bigint NOT NULL,
+    type varchar(20) NOT NULL CHECK (
+        type IN ('follow', 'follow_accepted', 'comment', 'reply', 'mention')
+    ),
+    channel varchar(10) NOT NULL CHECK (channel IN ('in_app', 'email', 'off')),
+    updated_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (user_id, type),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id bigint PRIMARY KEY,
+    digest_frequency varchar(10) NOT NULL DEFAULT 'weekly' CHECK (digest_frequency IN ('daily', 'weekly', 'off')),
+    last_digest_at timestamp(0)
+    with
+        time zone,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id bigserial PRIMARY KEY,
+    type varchar(50) NOT NULL,
+    payload jsonb NOT NULL DEFAULT '{}',
+    status varchar(10) NOT NULL DEFAULT 'pending' CHECK (
+        status IN ('pending', 'running', 'completed', 'dead')
+    ),
+    attempts int NOT NULL DEFAULT 0,
+    max_attempts int NOT NULL DEFAULT 5,
+    last_error text,
+    run_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        locked_at timestamp(0)
+    with
+        time zone,
+        created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        updated_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs (run_at, id)
+WHERE
+    status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs (locked_at)
+WHERE
+    status = 'running';
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    aggregate_type varchar(50) NOT NULL,
+    aggregate_id bigint NOT NULL,
+    event_type varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        published_at timestamp(0)
+    with
+        time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id)
+WHERE
+    published_at IS NULL;
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    events text[] NOT NULL,
+    secret text NOT NULL,
+    is_active boolean NOT NULL DEFAULT TRUE,
+    created_by bigint,
+    created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        updated_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_events ON webhooks USING gin (events);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    webhook_id bigint NOT NULL,
+    event_id bigint NOT NULL,
+    event_type varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(10) NOT NULL DEFAULT 'pending' CHECK (
+        status IN ('pending', 'succeeded', 'failed')
+    ),
+    attempts int NOT NULL DEFAULT 0,
+    response_status int,
+    response_body text,
+    error text,
+    duration_ms int,
+    last_attempt_at timestamp(0)
+    with
+        time zone,
+        created_at timestamp(0)
+    with
+        time zone NOT NULL DEFAULT NOW (),
+        UNIQUE (webhook_id, event_id),
+        FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
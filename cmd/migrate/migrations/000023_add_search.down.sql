@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_users_full_name_trgm;
+
+DROP INDEX IF EXISTS idx_users_username_trgm;
+
+DROP INDEX IF EXISTS idx_users_search_vector;
+
+DROP INDEX IF EXISTS idx_comments_search_vector;
+
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,4 @@
+ALTER TABLE outbox
+ADD COLUMN IF NOT EXISTS claimed_until timestamp(0)
+with
+    time zone;
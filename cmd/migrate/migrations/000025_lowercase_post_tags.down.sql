@@ -0,0 +1,2 @@
+-- The original case of each tag is not kept, so this cannot be undone.
+SELECT 1;
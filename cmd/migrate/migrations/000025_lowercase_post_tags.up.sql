@@ -0,0 +1,15 @@
+-- Tags are stored lowercased and without duplicates, keeping the position
+-- of each tag's first occurrence.
+UPDATE posts
+SET
+    tags = (
+        SELECT COALESCE(jsonb_agg(tag ORDER BY first_position), '[]'::jsonb)
+        FROM (
+                SELECT lower(t) AS tag, min(position) AS first_position
+                FROM jsonb_array_elements_text(posts.tags) WITH ORDINALITY AS e (t, position)
+                GROUP BY lower(t)
+            ) AS deduped
+    )
+WHERE
+    jsonb_typeof(tags) = 'array'
+    AND tags::text <> lower(tags::text);
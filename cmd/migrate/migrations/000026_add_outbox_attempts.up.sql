@@ -0,0 +1,17 @@
+ALTER TABLE outbox
+ADD COLUMN IF NOT EXISTS attempts int NOT NULL DEFAULT 0;
+
+ALTER TABLE outbox
+ADD COLUMN IF NOT EXISTS last_error text;
+
+ALTER TABLE outbox
+ADD COLUMN IF NOT EXISTS dead_at timestamp(0)
+with
+    time zone;
+
+DROP INDEX IF EXISTS idx_outbox_unpublished;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id)
+WHERE
+    published_at IS NULL
+    AND dead_at IS NULL;
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	PostCreated         = "post.created"
+	CommentCreated      = "comment.created"
+	NotificationCreated = "notification.created"
+	MessageCreated      = "message.created"
+	ConversationRead    = "conversation.read"
+)
+
+// Event is a message pushed to a single user's event stream.
+type Event struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	UserID int64           `json:"user_id"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Broker fans events out to the subscribers of each user's stream.
+type Broker interface {
+	// Publish assigns the event an ID and delivers it to the user's
+	// subscribers.
+	Publish(ctx context.Context, event *Event) error
+	// Subscribe returns the user's events, starting after lastEventID when it
+	// is set and still retained. The channel is closed once ctx is done, or
+	// early when the subscriber falls too far behind, in which case the client
+	// is expected to reconnect with the ID of the last event it received.
+	Subscribe(ctx context.Context, userID int64, lastEventID string) (<-chan *Event, error)
+}
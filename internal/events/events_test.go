@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// receive waits for the next event on ch.
+func receive(t *testing.T, ch <-chan *Event) *Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed, want an event")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+// expectNone checks that nothing more arrives on ch.
+func expectNone(t *testing.T, ch <-chan *Event) {
+	t.Helper()
+
+	select {
+	case event, ok := <-ch:
+		if ok {
+			t.Fatalf("got event %s, want none", event.ID)
+		}
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// expectClosed waits for ch to be closed.
+func expectClosed(t *testing.T, ch <-chan *Event) {
+	t.Helper()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for the channel to close")
+		}
+	}
+}
+
+func publish(t *testing.T, b Broker, userID int64, eventType string) *Event {
+	t.Helper()
+
+	event := &Event{Type: eventType, UserID: userID, Data: json.RawMessage(`{}`)}
+	if err := b.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestHubDeliversToTheUsersSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := NewHub(10)
+	alice, _ := h.Subscribe(ctx, 1, "")
+	bob, _ := h.Subscribe(ctx, 2, "")
+
+	sent := publish(t, h, 1, PostCreated)
+
+	if got := receive(t, alice); got.ID != sent.ID || got.Type != PostCreated {
+		t.Errorf("got %s %s, want %s %s", got.ID, got.Type, sent.ID, PostCreated)
+	}
+	expectNone(t, bob)
+}
+
+func TestHubResumesAfterLastEventID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := NewHub(2)
+	publish(t, h, 1, PostCreated)
+	second := publish(t, h, 1, CommentCreated)
+	third := publish(t, h, 1, NotificationCreated)
+	fourth := publish(t, h, 1, MessageCreated)
+
+	ch, _ := h.Subscribe(ctx, 1, second.ID)
+	if got := receive(t, ch); got.ID != third.ID {
+		t.Errorf("first replayed event = %s, want %s", got.ID, third.ID)
+	}
+	if got := receive(t, ch); got.ID != fourth.ID {
+		t.Errorf("second replayed event = %s, want %s", got.ID, fourth.ID)
+	}
+	expectNone(t, ch)
+
+	// Only historySize events are kept, so older ones cannot be replayed.
+	ch, _ = h.Subscribe(ctx, 1, "0")
+	if got := receive(t, ch); got.ID != third.ID {
+		t.Errorf("oldest retained event = %s, want %s", got.ID, third.ID)
+	}
+}
+
+func TestHubClosesSubscriptions(t *testing.T) {
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		h := NewHub(0)
+		ch, _ := h.Subscribe(ctx, 1, "")
+
+		cancel()
+		expectClosed(t, ch)
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		h := NewHub(0)
+		ch, _ := h.Subscribe(ctx, 1, "")
+		for i := 0; i <= subscriberBuffer; i++ {
+			publish(t, h, 1, PostCreated)
+		}
+
+		expectClosed(t, ch)
+	})
+}
+
+func newTestRedisBroker(t *testing.T) *RedisBroker {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	b := NewRedisBroker(rdb, 100, zap.NewNop().Sugar())
+	t.Cleanup(func() { b.Close() })
+
+	return b
+}
+
+func TestRedisBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := newTestRedisBroker(t)
+	first := publish(t, b, 1, PostCreated)
+	second := publish(t, b, 1, CommentCreated)
+
+	ch, err := b.Subscribe(ctx, 1, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The history after first is replayed, then live events follow.
+	if got := receive(t, ch); got.ID != second.ID || got.Type != CommentCreated {
+		t.Errorf("replayed %s %s, want %s %s", got.ID, got.Type, second.ID, CommentCreated)
+	}
+
+	third := publish(t, b, 1, NotificationCreated)
+	if got := receive(t, ch); got.ID != third.ID || got.Type != NotificationCreated {
+		t.Errorf("got %s %s, want %s %s", got.ID, got.Type, third.ID, NotificationCreated)
+	}
+	expectNone(t, ch)
+}
+
+func TestStreamIDAfter(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2-0", "1-0", true},
+		{"1-1", "1-0", true},
+		{"1-0", "1-0", false},
+		{"1-0", "1-1", false},
+		{"10-0", "9-5", true},
+		{"1-0", "not-an-id", true},
+	}
+
+	for _, tt := range tests {
+		if got := streamIDAfter(tt.a, tt.b); got != tt.want {
+			t.Errorf("streamIDAfter(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// subscriberBuffer is how many live events a subscriber can fall behind
+// before it is disconnected.
+const subscriberBuffer = 64
+
+type subscription struct {
+	ch     chan *Event
+	closed bool
+}
+
+// Hub is an in-process Broker. It keeps the last historySize events of each
+// user so that reconnecting clients can resume from Last-Event-ID.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*subscription]struct{}
+	history     map[int64][]*Event
+	historySize int
+	seq         uint64
+}
+
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		subscribers: make(map[int64]map[*subscription]struct{}),
+		history:     make(map[int64][]*Event),
+		historySize: historySize,
+	}
+}
+
+func (h *Hub) Publish(ctx context.Context, event *Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	event.ID = strconv.FormatUint(h.seq, 10)
+
+	if h.historySize > 0 {
+		history := append(h.history[event.UserID], event)
+		if len(history) > h.historySize {
+			history = history[len(history)-h.historySize:]
+		}
+		h.history[event.UserID] = history
+	}
+
+	h.broadcastLocked(event)
+	return nil
+}
+
+func (h *Hub) Subscribe(ctx context.Context, userID int64, lastEventID string) (<-chan *Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []*Event
+	if lastEventID != "" {
+		if last, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+			for _, event := range h.history[userID] {
+				if seq, _ := strconv.ParseUint(event.ID, 10, 64); seq > last {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+
+	return h.subscribeLocked(ctx, userID, replay), nil
+}
+
+// broadcast delivers an event that already has an ID to local subscribers.
+func (h *Hub) broadcast(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.broadcastLocked(event)
+}
+
+func (h *Hub) broadcastLocked(event *Event) {
+	for sub := range h.subscribers[event.UserID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Too slow to keep up, drop it and let the client resume.
+			h.removeLocked(event.UserID, sub)
+		}
+	}
+}
+
+// subscribe registers a local subscriber that first receives replay.
+func (h *Hub) subscribe(ctx context.Context, userID int64, replay []*Event) <-chan *Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribeLocked(ctx, userID, replay)
+}
+
+func (h *Hub) subscribeLocked(ctx context.Context, userID int64, replay []*Event) <-chan *Event {
+	sub := &subscription{ch: make(chan *Event, len(replay)+subscriberBuffer)}
+	for _, event := range replay {
+		sub.ch <- event
+	}
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		h.mu.Lock()
+		h.removeLocked(userID, sub)
+		h.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+func (h *Hub) removeLocked(userID int64, sub *subscription) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.ch)
+
+	delete(h.subscribers[userID], sub)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+	}
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const redisChannelPrefix = "events:user:"
+
+// RedisBroker fans events out across API instances. Each user's recent events
+// are kept in a capped Redis stream, whose entry IDs double as the SSE event
+// IDs, and live delivery goes through a single pub/sub subscription per
+// instance that feeds a local Hub.
+type RedisBroker struct {
+	rdb         *redis.Client
+	hub         *Hub
+	pubsub      *redis.PubSub
+	historySize int64
+	logger      *zap.SugaredLogger
+}
+
+func NewRedisBroker(rdb *redis.Client, historySize int, logger *zap.SugaredLogger) *RedisBroker {
+	b := &RedisBroker{
+		rdb:         rdb,
+		hub:         NewHub(0),
+		pubsub:      rdb.PSubscribe(context.Background(), redisChannelPrefix+"*"),
+		historySize: int64(historySize),
+		logger:      logger,
+	}
+
+	go b.listen()
+
+	return b
+}
+
+func redisUserKey(userID int64) string {
+	return fmt.Sprintf("%s%d", redisChannelPrefix, userID)
+}
+
+func (b *RedisBroker) listen() {
+	for msg := range b.pubsub.Channel() {
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			b.logger.Errorw("error decoding event", "channel", msg.Channel, "error", err)
+			continue
+		}
+
+		b.hub.broadcast(&event)
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, event *Event) error {
+	key := redisUserKey(event.UserID)
+
+	id, err := b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: b.historySize,
+		Approx: true,
+		Values: map[string]any{"type": event.Type, "data": string(event.Data)},
+	}).Result()
+
+	if err != nil {
+		return err
+	}
+
+	event.ID = id
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return b.rdb.Publish(ctx, key, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, userID int64, lastEventID string) (<-chan *Event, error) {
+	// Register for live events before reading the history so nothing
+	// published in between is missed. Live events already replayed are
+	// skipped below.
+	live := b.hub.subscribe(ctx, userID, nil)
+
+	var replay []*Event
+	if lastEventID != "" {
+		if _, _, ok := parseStreamID(lastEventID); ok {
+			messages, err := b.rdb.XRange(ctx, redisUserKey(userID), "("+lastEventID, "+").Result()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, msg := range messages {
+				replay = append(replay, streamEvent(userID, msg))
+			}
+		}
+	}
+
+	out := make(chan *Event, len(replay)+subscriberBuffer)
+	for _, event := range replay {
+		out <- event
+	}
+
+	last := lastEventID
+	if len(replay) > 0 {
+		last = replay[len(replay)-1].ID
+	}
+
+	go func() {
+		defer close(out)
+
+		for event := range live {
+			if last != "" && !streamIDAfter(event.ID, last) {
+				continue
+			}
+
+			select {
+			case out <- event:
+			default:
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *RedisBroker) Close() error {
+	return b.pubsub.Close()
+}
+
+func streamEvent(userID int64, msg redis.XMessage) *Event {
+	eventType, _ := msg.Values["type"].(string)
+	data, _ := msg.Values["data"].(string)
+
+	return &Event{
+		ID:     msg.ID,
+		Type:   eventType,
+		UserID: userID,
+		Data:   json.RawMessage(data),
+	}
+}
+
+func parseStreamID(id string) (ms, seq uint64, ok bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	seq, err = strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return ms, seq, true
+}
+
+// streamIDAfter reports whether stream entry ID a comes after b.
+func streamIDAfter(a, b string) bool {
+	aMs, aSeq, aOk := parseStreamID(a)
+	bMs, bSeq, bOk := parseStreamID(b)
+	if !aOk || !bOk {
+		return true
+	}
+
+	return aMs > bMs || (aMs == bMs && aSeq > bSeq)
+}
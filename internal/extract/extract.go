@@ -0,0 +1,78 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// a mention or hashtag must start the text or follow a character that
+	// cannot be part of a word, so emails and URL fragments are skipped
+	mentionRx = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_]{1,255})`)
+	hashtagRx = regexp.MustCompile(`(?:^|[^\w#&/])#([\p{L}\p{N}_]{1,100})`)
+)
+
+// Mentions returns the usernames mentioned as @username in the given text, in
+// order of first appearance and without duplicates.
+func Mentions(text ...string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, t := range text {
+		for _, match := range mentionRx.FindAllStringSubmatch(t, -1) {
+			username := match[1]
+			if seen[username] {
+				continue
+			}
+
+			seen[username] = true
+			mentions = append(mentions, username)
+		}
+	}
+
+	return mentions
+}
+
+// Hashtags returns the lowercased #hashtags in the given text, in order of
+// first appearance and without duplicates. Purely numeric tags such as "#1"
+// are ignored.
+func Hashtags(text ...string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for _, t := range text {
+		for _, match := range hashtagRx.FindAllStringSubmatch(t, -1) {
+			tag := strings.ToLower(match[1])
+			if seen[tag] || !strings.ContainsFunc(tag, unicode.IsLetter) {
+				continue
+			}
+
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// MergeTags lowercases tags and appends the tags in extra that are missing
+// from them, so tags differing only in case are stored once.
+func MergeTags(tags []string, extra []string) []string {
+	merged := make([]string, 0, len(tags)+len(extra))
+	seen := make(map[string]bool)
+
+	for _, list := range [][]string{tags, extra} {
+		for _, tag := range list {
+			tag = strings.ToLower(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+
+	return merged
+}
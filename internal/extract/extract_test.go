@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text []string
+		want []string
+	}{
+		{"none", []string{"no one here"}, nil},
+		{"start of text", []string{"@alice hi"}, []string{"alice"}},
+		{"after punctuation", []string{"hi (@alice), @bob!"}, []string{"alice", "bob"}},
+		{"duplicates across texts", []string{"@alice", "and @alice again, @bob"}, []string{"alice", "bob"}},
+		{"case is kept", []string{"@Alice"}, []string{"Alice"}},
+		{"email", []string{"mail alice@example.com"}, nil},
+		{"double at", []string{"@@alice"}, nil},
+		{"bare at", []string{"meet @ noon"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.text...); !slices.Equal(got, tt.want) {
+				t.Errorf("Mentions(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text []string
+		want []string
+	}{
+		{"none", []string{"nothing to see"}, nil},
+		{"lowercased", []string{"#Go and #RUST"}, []string{"go", "rust"}},
+		{"duplicates across texts", []string{"#go", "#Go #golang"}, []string{"go", "golang"}},
+		{"unicode letters", []string{"#café"}, []string{"café"}},
+		{"numeric", []string{"issue #1 and #42"}, nil},
+		{"digits with letters", []string{"#web3"}, []string{"web3"}},
+		{"url fragment", []string{"see https://example.com/#section"}, nil},
+		{"html entity", []string{"&#39;quoted&#39;"}, nil},
+		{"inside a word", []string{"c#sharp"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hashtags(tt.text...); !slices.Equal(got, tt.want) {
+				t.Errorf("Hashtags(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		tags  []string
+		extra []string
+		want  []string
+	}{
+		{"empty", nil, nil, []string{}},
+		{"extra appended", []string{"go"}, []string{"rust"}, []string{"go", "rust"}},
+		{"duplicates dropped", []string{"go", "go"}, []string{"go", "rust"}, []string{"go", "rust"}},
+		{"blank tags dropped", []string{"", "go"}, []string{""}, []string{"go"}},
+		{"lowercased", []string{"Go", "RUST"}, nil, []string{"go", "rust"}},
+		{"duplicates differing in case dropped", []string{"Go", "go"}, []string{"GO", "rust"}, []string{"go", "rust"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeTags(tt.tags, tt.extra); !slices.Equal(got, tt.want) {
+				t.Errorf("MergeTags(%q, %q) = %q, want %q", tt.tags, tt.extra, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package jobs runs background work queued in the jobs table. Jobs survive
+// restarts, are retried with exponential backoff when they fail and are
+// dead-lettered once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	SendEmail      = "email.send"
+	DeliverWebhook = "webhook.deliver"
+)
+
+// Handler runs one job. A returned error fails the attempt; errors wrapped
+// with Permanent dead-letter the job straight away.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, such as a payload that cannot be
+// decoded.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type Store interface {
+	Claim(ctx context.Context, limit int) ([]*store.Job, error)
+	Complete(ctx context.Context, jobID int64) error
+	Retry(ctx context.Context, jobID int64, runAt time.Time, lastError string) error
+	Bury(ctx context.Context, jobID int64, lastError string) error
+	RequeueStale(ctx context.Context, timeout time.Duration) (int64, error)
+}
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	// JobTimeout bounds a single attempt. Jobs still running after twice
+	// this are assumed to belong to a dead worker and are requeued.
+	JobTimeout  time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+type Pool struct {
+	store    Store
+	cfg      Config
+	logger   *zap.SugaredLogger
+	handlers map[string]Handler
+	wake     chan struct{}
+}
+
+func NewPool(store Store, cfg Config, logger *zap.SugaredLogger) *Pool {
+	return &Pool{
+		store:    store,
+		cfg:      cfg,
+		logger:   logger,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a job type. It must be called before Run.
+func (p *Pool) Register(jobType string, handler Handler) {
+	p.handlers[jobType] = handler
+}
+
+// Wake tells an idle worker to check for jobs now rather than at its next
+// poll, so newly enqueued jobs start without waiting out the poll interval.
+func (p *Pool) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every job that
+// was already running has finished. Running jobs are not interrupted by ctx.
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.requeueStale(ctx)
+	}()
+
+	for i := 0; i < p.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there is work so a backlog drains without
+		// waiting between jobs.
+		for ctx.Err() == nil && p.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs one job, reporting whether there was one.
+func (p *Pool) runNext(ctx context.Context) bool {
+	jobs, err := p.store.Claim(ctx, 1)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Errorw("error claiming job", "error", err)
+		}
+		return false
+	}
+
+	if len(jobs) == 0 {
+		return false
+	}
+
+	p.run(jobs[0])
+	return true
+}
+
+func (p *Pool) run(job *store.Job) {
+	// The job outlives ctx so shutdown lets it finish instead of failing it.
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.JobTimeout)
+	defer cancel()
+
+	handler, ok := p.handlers[job.Type]
+	if !ok {
+		p.bury(ctx, job, fmt.Errorf("no handler for job type %q", job.Type))
+		return
+	}
+
+	if err := p.call(ctx, handler, job); err != nil {
+		var permanent *permanentError
+		if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+			p.bury(ctx, job, err)
+			return
+		}
+
+		runAt := time.Now().Add(p.backoff(job.Attempts))
+		p.logger.Warnw("job failed, retrying",
+			"job_id", job.ID, "type", job.Type, "attempt", job.Attempts, "run_at", runAt, "error", err)
+
+		if err := p.store.Retry(ctx, job.ID, runAt, err.Error()); err != nil {
+			p.logger.Errorw("error rescheduling job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	if err := p.store.Complete(ctx, job.ID); err != nil {
+		p.logger.Errorw("error completing job", "job_id", job.ID, "error", err)
+	}
+}
+
+func (p *Pool) call(ctx context.Context, handler Handler, job *store.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job.Payload)
+}
+
+func (p *Pool) bury(ctx context.Context, job *store.Job, err error) {
+	p.logger.Errorw("job dead-lettered",
+		"job_id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+
+	if err := p.store.Bury(ctx, job.ID, err.Error()); err != nil {
+		p.logger.Errorw("error dead-lettering job", "job_id", job.ID, "error", err)
+	}
+}
+
+// backoff doubles the delay with each attempt, up to MaxBackoff, and adds up
+// to 20% jitter so jobs that failed together do not retry together.
+func (p *Pool) backoff(attempt int) time.Duration {
+	delay := p.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < p.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, p.cfg.MaxBackoff)
+	return delay + rand.N(delay/5+1)
+}
+
+func (p *Pool) requeueStale(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.JobTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		requeued, err := p.store.RequeueStale(ctx, p.cfg.JobTimeout*2)
+		if err != nil {
+			if ctx.Err() == nil {
+				p.logger.Errorw("error requeueing stale jobs", "error", err)
+			}
+			continue
+		}
+
+		if requeued > 0 {
+			p.logger.Warnw("requeued stale jobs", "count", requeued)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryStore is a jobs table held in memory with the same claim semantics as
+// the Postgres store.
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*store.Job
+}
+
+func (s *memoryStore) enqueue(jobType string, maxAttempts int) *store.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := &store.Job{
+		ID:          int64(len(s.jobs) + 1),
+		Type:        jobType,
+		Payload:     json.RawMessage(`{}`),
+		Status:      store.JobPending,
+		MaxAttempts: maxAttempts,
+		RunAt:       time.Now(),
+	}
+	s.jobs = append(s.jobs, job)
+	return job
+}
+
+func (s *memoryStore) Claim(_ context.Context, limit int) ([]*store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	claimed := []*store.Job{}
+	for _, job := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.Status != store.JobPending || job.RunAt.After(now) {
+			continue
+		}
+
+		job.Status = store.JobRunning
+		job.Attempts++
+		job.LockedAt = &now
+
+		copied := *job
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, jobID int64) error {
+	return s.finish(jobID, func(job *store.Job) {
+		job.Status = store.JobCompleted
+	})
+}
+
+func (s *memoryStore) Retry(_ context.Context, jobID int64, runAt time.Time, lastError string) error {
+	return s.finish(jobID, func(job *store.Job) {
+		job.Status = store.JobPending
+		job.RunAt = runAt
+		job.LastError = &lastError
+	})
+}
+
+func (s *memoryStore) Bury(_ context.Context, jobID int64, lastError string) error {
+	return s.finish(jobID, func(job *store.Job) {
+		job.Status = store.JobDead
+		job.LastError = &lastError
+	})
+}
+
+func (s *memoryStore) finish(jobID int64, update func(job *store.Job)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.ID == jobID && job.Status == store.JobRunning {
+			update(job)
+			job.LockedAt = nil
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *memoryStore) RequeueStale(_ context.Context, timeout time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requeued int64
+	for _, job := range s.jobs {
+		if job.Status != store.JobRunning || time.Since(*job.LockedAt) <= timeout {
+			continue
+		}
+
+		job.Status = store.JobPending
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = store.JobDead
+		}
+		job.RunAt = time.Now()
+		job.LockedAt = nil
+		requeued++
+	}
+	return requeued, nil
+}
+
+func (s *memoryStore) get(jobID int64) store.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.jobs[jobID-1]
+}
+
+var testConfig = Config{
+	Workers:      4,
+	PollInterval: 5 * time.Millisecond,
+	JobTimeout:   time.Second,
+	BaseBackoff:  time.Millisecond,
+	MaxBackoff:   10 * time.Millisecond,
+}
+
+// runPool runs p until the test ends.
+func runPool(t *testing.T, p *Pool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitForStatus waits for the job to reach status.
+func waitForStatus(t *testing.T, s *memoryStore, jobID int64, status string) store.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		job := s.get(jobID)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s after %d attempts, want %s", jobID, job.Status, job.Attempts, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// countingStore records how often each job is claimed.
+type countingStore struct {
+	*memoryStore
+	claimsMu sync.Mutex
+	claims   map[int64]int
+}
+
+func (s *countingStore) Claim(ctx context.Context, limit int) ([]*store.Job, error) {
+	jobs, err := s.memoryStore.Claim(ctx, limit)
+
+	s.claimsMu.Lock()
+	defer s.claimsMu.Unlock()
+	for _, job := range jobs {
+		s.claims[job.ID]++
+	}
+	return jobs, err
+}
+
+func TestPoolRunsEachJobOnce(t *testing.T) {
+	s := &countingStore{memoryStore: &memoryStore{}, claims: map[int64]int{}}
+	for i := 0; i < 50; i++ {
+		s.enqueue("job", 1)
+	}
+
+	p := NewPool(s, testConfig, zap.NewNop().Sugar())
+	p.Register("job", func(context.Context, json.RawMessage) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	runPool(t, p)
+
+	for i := int64(1); i <= 50; i++ {
+		waitForStatus(t, s.memoryStore, i, store.JobCompleted)
+	}
+
+	s.claimsMu.Lock()
+	defer s.claimsMu.Unlock()
+	if len(s.claims) != 50 {
+		t.Errorf("claimed %d jobs, want 50", len(s.claims))
+	}
+	for id, n := range s.claims {
+		if n != 1 {
+			t.Errorf("job %d claimed %d times, want once", id, n)
+		}
+	}
+}
+
+func TestPoolRetriesFailedJobs(t *testing.T) {
+	s := &memoryStore{}
+	job := s.enqueue("flaky", 5)
+
+	var calls atomic.Int32
+	p := NewPool(s, testConfig, zap.NewNop().Sugar())
+	p.Register("flaky", func(ctx context.Context, _ json.RawMessage) error {
+		if calls.Add(1) < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+	runPool(t, p)
+
+	got := waitForStatus(t, s, job.ID, store.JobCompleted)
+	if got.Attempts != 3 {
+		t.Errorf("completed after %d attempts, want 3", got.Attempts)
+	}
+	if got.LastError == nil || *got.LastError != "try again" {
+		t.Errorf("last error = %v, want the handler's error", got.LastError)
+	}
+}
+
+func TestPoolBuriesJobs(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		handler      Handler
+		wantAttempts int
+	}{
+		{
+			name:         "out of attempts",
+			maxAttempts:  3,
+			handler:      func(context.Context, json.RawMessage) error { return errors.New("down") },
+			wantAttempts: 3,
+		},
+		{
+			name:         "permanent error",
+			maxAttempts:  3,
+			handler:      func(context.Context, json.RawMessage) error { return Permanent(errors.New("bad payload")) },
+			wantAttempts: 1,
+		},
+		{
+			name:         "panic",
+			maxAttempts:  2,
+			handler:      func(context.Context, json.RawMessage) error { panic("boom") },
+			wantAttempts: 2,
+		},
+		{
+			name:         "no handler",
+			maxAttempts:  3,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &memoryStore{}
+			job := s.enqueue("job", tt.maxAttempts)
+
+			p := NewPool(s, testConfig, zap.NewNop().Sugar())
+			if tt.handler != nil {
+				p.Register("job", tt.handler)
+			}
+			runPool(t, p)
+
+			got := waitForStatus(t, s, job.ID, store.JobDead)
+			if got.Attempts != tt.wantAttempts {
+				t.Errorf("buried after %d attempts, want %d", got.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestPoolRequeuesStaleJobs(t *testing.T) {
+	s := &memoryStore{}
+	job := s.enqueue("job", 3)
+
+	// Claimed by a worker that has since died.
+	if _, err := s.Claim(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	lockedAt := time.Now().Add(-time.Minute)
+	s.jobs[0].LockedAt = &lockedAt
+	s.mu.Unlock()
+
+	cfg := testConfig
+	cfg.JobTimeout = 10 * time.Millisecond
+
+	p := NewPool(s, cfg, zap.NewNop().Sugar())
+	p.Register("job", func(context.Context, json.RawMessage) error { return nil })
+	runPool(t, p)
+
+	got := waitForStatus(t, s, job.ID, store.JobCompleted)
+	if got.Attempts != 2 {
+		t.Errorf("completed after %d attempts, want 2", got.Attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := NewPool(nil, Config{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}, zap.NewNop().Sugar())
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := p.backoff(tt.attempt)
+			if got < tt.want || got > tt.want+tt.want/5 {
+				t.Errorf("backoff(%d) = %v, want %v plus up to 20%%", tt.attempt, got, tt.want)
+			}
+		}
+	}
+}
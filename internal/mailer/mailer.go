@@ -3,8 +3,9 @@ package mailer
 import "embed"
 
 var (
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	maxRetries                 = 3
+	UserWelcomeTemplate        = "user_invitation.tmpl"
+	NotificationDigestTemplate = "notification_digest.tmpl"
 )
 
 //go:embed "templates"
@@ -12,6 +13,9 @@ var templateFS embed.FS
 
 type Client interface {
 	Send(templateFile, username, email string, data any, isSandbox bool) error
+	// SendWithHeaders is Send with extra message headers, such as
+	// List-Unsubscribe.
+	SendWithHeaders(templateFile, username, email string, data any, headers map[string]string, isSandbox bool) error
 	SendEmail(templateFile string,
 		to []string,
 		cc []string,
@@ -33,6 +33,10 @@ func NewMailTrapClient(fromEmail, smtpAddr, smtpSandboxAddr, username, password
 }
 
 func (c *MailTrapClient) Send(templateFile, username, email string, data any, isSandbox bool) error {
+	return c.SendWithHeaders(templateFile, username, email, data, nil, isSandbox)
+}
+
+func (c *MailTrapClient) SendWithHeaders(templateFile, username, email string, data any, headers map[string]string, isSandbox bool) error {
 	message := gomail.NewMessage()
 
 	//template parsing and building
@@ -61,6 +65,10 @@ func (c *MailTrapClient) Send(templateFile, username, email string, data any, is
 	message.SetHeader("To", email)
 	message.SetHeader("Subject", subject.String())
 
+	for name, value := range headers {
+		message.SetHeader(name, value)
+	}
+
 	message.SetBody("text/html", body.String())
 
 	smtpAddr := c.smtpAddr
@@ -0,0 +1,239 @@
+// Package outbox relays domain events recorded in the outbox table to external
+// sinks. Delivery is at least once: a batch that any sink fails to take is
+// handed to every sink again, so sinks must tolerate duplicates, for example
+// by keying on the event ID. An event that keeps failing is dead-lettered so
+// it does not hold up the ones behind it.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+// Sink receives batches of outbox events in the order they were recorded.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, events []*store.OutboxEvent) error
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a sink error as not worth retrying, such as a payload that
+// cannot be decoded. The event is dead-lettered straight away.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type Store interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*store.OutboxEvent, error)
+	MarkPublished(ctx context.Context, ids []int64) error
+	Release(ctx context.Context, ids []int64) error
+	Retry(ctx context.Context, id int64, lastError string) error
+	Bury(ctx context.Context, id int64, lastError string) error
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Lease is how long a claimed batch is reserved for delivery. It must
+	// outlast the slowest sink, or the batch is handed out twice.
+	Lease time.Duration
+	// MaxAttempts is how many failed deliveries an event gets before it is
+	// dead-lettered. Zero retries forever.
+	MaxAttempts int
+	// Retention is how long published events are kept before they are
+	// deleted. Zero keeps them forever.
+	Retention time.Duration
+}
+
+type Relay struct {
+	store  Store
+	sinks  []Sink
+	cfg    Config
+	logger *zap.SugaredLogger
+}
+
+func NewRelay(store Store, sinks []Sink, cfg Config, logger *zap.SugaredLogger) *Relay {
+	return &Relay{
+		store:  store,
+		sinks:  sinks,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Run relays events until ctx is cancelled. A batch in flight when ctx is
+// cancelled is released and relayed again on the next run.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+
+	for {
+		// Drain a backlog without waiting between batches.
+		for ctx.Err() == nil && r.relayBatch(ctx) {
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastCleanup) > r.cfg.Retention/10 {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes one batch and reports whether it was full, meaning
+// there may be more waiting. The batch is claimed, delivered and marked in
+// separate steps so a slow sink holds no locks.
+func (r *Relay) relayBatch(ctx context.Context) bool {
+	events, err := r.store.Claim(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Errorw("error claiming outbox events", "error", err)
+		}
+		return false
+	}
+
+	if len(events) == 0 {
+		return false
+	}
+
+	ids := eventIDs(events)
+
+	// The claim is settled even when ctx is cancelled mid-delivery.
+	settleCtx := context.WithoutCancel(ctx)
+
+	if err := r.publish(ctx, events); err != nil {
+		if ctx.Err() != nil {
+			r.release(settleCtx, ids)
+			return false
+		}
+
+		r.logger.Errorw("error relaying outbox events", "error", err)
+
+		if len(events) == 1 {
+			r.fail(settleCtx, events[0], err)
+		} else {
+			// Deliver the events one at a time to find the ones being
+			// rejected.
+			r.relayEach(ctx, events)
+		}
+		return false
+	}
+
+	if err := r.store.MarkPublished(settleCtx, ids); err != nil {
+		r.logger.Errorw("error marking outbox events published", "error", err)
+		return false
+	}
+
+	return len(events) == r.cfg.BatchSize
+}
+
+// relayEach delivers events one at a time, in order, after their batch
+// failed. It stops at the first event that fails and is retried, releasing
+// the events after it; dead-lettered events are skipped.
+func (r *Relay) relayEach(ctx context.Context, events []*store.OutboxEvent) {
+	settleCtx := context.WithoutCancel(ctx)
+
+	for i, event := range events {
+		err := r.publish(ctx, events[i:i+1])
+		if err == nil {
+			if err := r.store.MarkPublished(settleCtx, []int64{event.ID}); err != nil {
+				r.logger.Errorw("error marking outbox event published", "id", event.ID, "error", err)
+				r.release(settleCtx, eventIDs(events[i+1:]))
+				return
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			r.release(settleCtx, eventIDs(events[i:]))
+			return
+		}
+
+		if !r.fail(settleCtx, event, err) {
+			r.release(settleCtx, eventIDs(events[i+1:]))
+			return
+		}
+	}
+}
+
+// fail records a failed delivery of event and reports whether it was
+// dead-lettered: events out of attempts, or failing with a Permanent error,
+// are set aside, and the rest are released to be tried again.
+func (r *Relay) fail(ctx context.Context, event *store.OutboxEvent, err error) bool {
+	var permanent *permanentError
+	if !errors.As(err, &permanent) && (r.cfg.MaxAttempts == 0 || event.Attempts+1 < r.cfg.MaxAttempts) {
+		if err := r.store.Retry(ctx, event.ID, err.Error()); err != nil {
+			r.logger.Errorw("error retrying outbox event", "id", event.ID, "error", err)
+		}
+		return false
+	}
+
+	r.logger.Errorw("dead-lettering outbox event",
+		"id", event.ID, "type", event.Type, "attempts", event.Attempts+1, "error", err)
+
+	if err := r.store.Bury(ctx, event.ID, err.Error()); err != nil {
+		r.logger.Errorw("error dead-lettering outbox event", "id", event.ID, "error", err)
+		return false
+	}
+
+	return true
+}
+
+func (r *Relay) release(ctx context.Context, ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := r.store.Release(ctx, ids); err != nil {
+		r.logger.Errorw("error releasing outbox events", "error", err)
+	}
+}
+
+func eventIDs(events []*store.OutboxEvent) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func (r *Relay) publish(ctx context.Context, events []*store.OutboxEvent) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, events); err != nil {
+			return fmt.Errorf("%s sink: %w", sink.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Relay) cleanup(ctx context.Context) {
+	deleted, err := r.store.DeletePublished(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Errorw("error deleting published outbox events", "error", err)
+		return
+	}
+
+	if deleted > 0 {
+		r.logger.Infow("deleted published outbox events", "count", deleted)
+	}
+}
@@ -0,0 +1,315 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryStore is an outbox held in memory with the same claim semantics as
+// the Postgres store.
+type memoryStore struct {
+	mu        sync.Mutex
+	events    []*store.OutboxEvent
+	claimed   map[int64]time.Time
+	published map[int64]bool
+	dead      map[int64]bool
+}
+
+func newMemoryStore(n int) *memoryStore {
+	s := &memoryStore{claimed: map[int64]time.Time{}, published: map[int64]bool{}, dead: map[int64]bool{}}
+	for i := 1; i <= n; i++ {
+		s.events = append(s.events, &store.OutboxEvent{ID: int64(i), Type: store.EventPostCreated, Payload: json.RawMessage(`{}`)})
+	}
+	return s
+}
+
+func (s *memoryStore) Claim(_ context.Context, limit int, lease time.Duration) ([]*store.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []*store.OutboxEvent{}
+	for _, event := range s.events {
+		if len(events) == limit {
+			break
+		}
+		if s.published[event.ID] || s.dead[event.ID] || time.Now().Before(s.claimed[event.ID]) {
+			continue
+		}
+
+		s.claimed[event.ID] = time.Now().Add(lease)
+		copied := *event
+		events = append(events, &copied)
+	}
+	return events, nil
+}
+
+func (s *memoryStore) MarkPublished(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.published[id] = true
+		delete(s.claimed, id)
+	}
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.claimed, id)
+	}
+	return nil
+}
+
+func (s *memoryStore) Retry(_ context.Context, id int64, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[id-1].Attempts++
+	delete(s.claimed, id)
+	return nil
+}
+
+func (s *memoryStore) Bury(_ context.Context, id int64, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[id-1].Attempts++
+	s.dead[id] = true
+	delete(s.claimed, id)
+	return nil
+}
+
+func (s *memoryStore) DeletePublished(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) publishedIDs() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedIDs(s.published)
+}
+
+func (s *memoryStore) deadIDs() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedIDs(s.dead)
+}
+
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := []int64{}
+	for id := range set {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]int64
+	fail    error
+	// rejects, when set, fails any batch holding one of its events.
+	rejects map[int64]error
+	// block, when set, holds Publish until it is closed.
+	block chan struct{}
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail != nil {
+		return s.fail
+	}
+	for _, event := range events {
+		if err := s.rejects[event.ID]; err != nil {
+			return err
+		}
+	}
+
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	s.batches = append(s.batches, ids)
+	return nil
+}
+
+func newTestRelay(s Store, sinks ...Sink) *Relay {
+	return NewRelay(s, sinks, Config{BatchSize: 2, Lease: time.Minute}, zap.NewNop().Sugar())
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	s := newMemoryStore(3)
+	sink := &recordingSink{}
+	r := newTestRelay(s, sink)
+
+	if !r.relayBatch(context.Background()) {
+		t.Error("a full batch should report that more may be waiting")
+	}
+	if r.relayBatch(context.Background()) {
+		t.Error("a short batch should report the outbox drained")
+	}
+	if r.relayBatch(context.Background()) {
+		t.Error("an empty outbox should report nothing waiting")
+	}
+
+	if want := [][]int64{{1, 2}, {3}}; !slices.EqualFunc(sink.batches, want, slices.Equal[[]int64]) {
+		t.Errorf("batches = %v, want %v", sink.batches, want)
+	}
+	if got := s.publishedIDs(); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("published = %v", got)
+	}
+}
+
+func TestRelayReleasesFailedBatches(t *testing.T) {
+	s := newMemoryStore(2)
+	failing := &recordingSink{fail: errors.New("sink down")}
+	r := newTestRelay(s, failing)
+
+	r.relayBatch(context.Background())
+
+	if got := s.publishedIDs(); len(got) != 0 {
+		t.Fatalf("published %v although the sink failed", got)
+	}
+
+	// Released events are handed out again straight away, not after the
+	// lease.
+	failing.fail = nil
+	r.relayBatch(context.Background())
+
+	if got := s.publishedIDs(); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("published = %v after the sink recovered", got)
+	}
+}
+
+func TestRelayDeadLettersRejectedEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantBatches int
+	}{
+		{name: "out of attempts", err: errors.New("rejected"), wantBatches: 3},
+		{name: "permanent error", err: Permanent(errors.New("bad payload")), wantBatches: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemoryStore(3)
+			sink := &recordingSink{rejects: map[int64]error{2: tt.err}}
+			r := NewRelay(s, []Sink{sink}, Config{BatchSize: 3, Lease: time.Minute, MaxAttempts: 3}, zap.NewNop().Sugar())
+
+			for i := 0; i < tt.wantBatches; i++ {
+				if got := s.deadIDs(); len(got) != 0 {
+					t.Fatalf("dead-lettered %v after %d batches", got, i)
+				}
+				r.relayBatch(context.Background())
+			}
+
+			if got := s.deadIDs(); !slices.Equal(got, []int64{2}) {
+				t.Errorf("dead = %v, want [2]", got)
+			}
+			// The events around the rejected one still go out, in order.
+			if got := s.publishedIDs(); !slices.Equal(got, []int64{1, 3}) {
+				t.Errorf("published = %v, want [1 3]", got)
+			}
+			if got := s.events[0].Attempts + s.events[2].Attempts; got != 0 {
+				t.Errorf("delivered events have %d failed attempts, want 0", got)
+			}
+		})
+	}
+}
+
+func TestRelayDoesNotBlockOtherRelaysOnSlowSinks(t *testing.T) {
+	s := newMemoryStore(4)
+	slow := &recordingSink{block: make(chan struct{})}
+	fast := &recordingSink{}
+
+	done := make(chan struct{})
+	go func() {
+		newTestRelay(s, slow).relayBatch(context.Background())
+		close(done)
+	}()
+
+	// Wait for the slow relay to claim its batch.
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.claimed)
+		s.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slow relay never claimed a batch")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	newTestRelay(s, fast).relayBatch(context.Background())
+	if want := [][]int64{{3, 4}}; !slices.EqualFunc(fast.batches, want, slices.Equal[[]int64]) {
+		t.Errorf("fast relay batches = %v, want %v", fast.batches, want)
+	}
+
+	close(slow.block)
+	<-done
+
+	if got := s.publishedIDs(); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("published = %v", got)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var (
+		body           map[string][]store.OutboxEvent
+		idempotencyKey string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	events := newMemoryStore(3).events
+
+	if err := NewHTTPSink(srv.URL, time.Second).Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(body["events"]) != 3 {
+		t.Errorf("posted %d events, want 3", len(body["events"]))
+	}
+	if idempotencyKey != "outbox-1-3" {
+		t.Errorf("Idempotency-Key = %q", idempotencyKey)
+	}
+
+	if err := NewHTTPSink(srv.URL+"/fail", time.Second).Publish(context.Background(), events); err == nil {
+		t.Error("a non-2xx response should fail the batch")
+	}
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// LogSink writes each event to the logger. It is mostly useful in
+// development to see what would be published.
+type LogSink struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogSink(logger *zap.SugaredLogger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Name() string { return "log" }
+
+func (s *LogSink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	for _, event := range events {
+		s.logger.Infow("outbox event",
+			"id", event.ID,
+			"type", event.Type,
+			"aggregate_type", event.AggregateType,
+			"aggregate_id", event.AggregateID,
+			"payload", string(event.Payload),
+		)
+	}
+
+	return nil
+}
+
+// RedisStreamSink appends events to a capped Redis stream for consumers that
+// read with consumer groups.
+type RedisStreamSink struct {
+	rdb    *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisStreamSink(rdb *redis.Client, stream string, maxLen int) *RedisStreamSink {
+	return &RedisStreamSink{rdb: rdb, stream: stream, maxLen: int64(maxLen)}
+}
+
+func (s *RedisStreamSink) Name() string { return "redis" }
+
+func (s *RedisStreamSink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	pipe := s.rdb.Pipeline()
+
+	for _, event := range events {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.stream,
+			MaxLen: s.maxLen,
+			Approx: true,
+			Values: map[string]any{
+				"id":             strconv.FormatInt(event.ID, 10),
+				"type":           event.Type,
+				"aggregate_type": event.AggregateType,
+				"aggregate_id":   strconv.FormatInt(event.AggregateID, 10),
+				"payload":        string(event.Payload),
+				"created_at":     event.CreatedAt.Format(time.RFC3339),
+			},
+		})
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// HTTPSink POSTs each batch as {"events": [...]} to a URL and treats any 2xx
+// response as success.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSink) Name() string { return "http" }
+
+func (s *HTTPSink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	body, err := json.Marshal(map[string]any{"events": events})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	// Receivers can use this to drop batches they have already seen.
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("outbox-%d-%d", events[0].ID, events[len(events)-1].ID))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package ranking
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+type Variant struct {
+	Ranker Ranker
+	Weight int
+}
+
+// Experiment splits users between rankers in proportion to their weights.
+// A user stays on the same ranker for as long as the variants are unchanged.
+type Experiment struct {
+	variants []Variant
+	total    int
+}
+
+func NewExperiment(variants ...Variant) *Experiment {
+	var total int
+	for _, v := range variants {
+		total += v.Weight
+	}
+	return &Experiment{variants: variants, total: total}
+}
+
+// ParseExperiment reads variants written as "name:weight" pairs separated by
+// commas, such as "balanced:90,recent:10".
+func ParseExperiment(spec string, rankers map[string]Ranker) (*Experiment, error) {
+	var variants []Variant
+
+	for _, part := range strings.Split(spec, ",") {
+		name, weight, _ := strings.Cut(strings.TrimSpace(part), ":")
+
+		ranker, ok := rankers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown ranker %q", name)
+		}
+
+		w := 1
+		if weight != "" {
+			var err error
+			if w, err = strconv.Atoi(weight); err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight %q for ranker %q", weight, name)
+			}
+		}
+
+		variants = append(variants, Variant{Ranker: ranker, Weight: w})
+	}
+
+	experiment := NewExperiment(variants...)
+	if experiment.total == 0 {
+		return nil, fmt.Errorf("ranking experiment %q has no weight", spec)
+	}
+
+	return experiment, nil
+}
+
+// Pick returns the user's ranker.
+func (e *Experiment) Pick(userID int64) Ranker {
+	h := fnv.New32a()
+	binary.Write(h, binary.BigEndian, userID)
+	bucket := int(h.Sum32() % uint32(e.total))
+
+	for _, v := range e.variants {
+		if bucket < v.Weight {
+			return v.Ranker
+		}
+		bucket -= v.Weight
+	}
+
+	return e.variants[len(e.variants)-1].Ranker
+}
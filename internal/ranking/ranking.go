@@ -0,0 +1,164 @@
+// Package ranking orders feed posts by how likely the viewer is to care about
+// them. A Ranker combines Scorers, each rating one signal, so strategies can
+// be compared by assigning users to them through an Experiment.
+package ranking
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// Viewer is what ranking knows about the person the feed is for, built from
+// their recent activity. Both maps are normalised to [0, 1] against the
+// viewer's strongest interest.
+type Viewer struct {
+	Authors map[int64]float64
+	Tags    map[string]float64
+}
+
+func NewViewer(history *store.ViewerHistory) *Viewer {
+	return &Viewer{
+		Authors: normalise(history.AuthorInteractions),
+		Tags:    normalise(history.TagCounts),
+	}
+}
+
+func normalise[K comparable](counts map[K]int) map[K]float64 {
+	var highest int
+	for _, count := range counts {
+		highest = max(highest, count)
+	}
+
+	weights := make(map[K]float64, len(counts))
+	for key, count := range counts {
+		weights[key] = float64(count) / float64(highest)
+	}
+	return weights
+}
+
+// Scorer rates one signal of a post for a viewer, from 0 to 1.
+type Scorer interface {
+	Score(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64
+}
+
+type ScorerFunc func(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64
+
+func (f ScorerFunc) Score(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64 {
+	return f(post, viewer, now)
+}
+
+// Recency halves a post's score every halfLife.
+func Recency(halfLife time.Duration) Scorer {
+	return ScorerFunc(func(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64 {
+		age := max(now.Sub(post.CreatedAt), 0)
+		return math.Exp(-math.Ln2 * age.Hours() / halfLife.Hours())
+	})
+}
+
+// Engagement rises with the comment count, reaching half at saturation
+// comments so a few busy threads do not drown out everything else.
+func Engagement(saturation int) Scorer {
+	return ScorerFunc(func(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64 {
+		comments := float64(post.CommentCount)
+		return comments / (comments + float64(saturation))
+	})
+}
+
+// Affinity favours authors the viewer interacts with most.
+func Affinity() Scorer {
+	return ScorerFunc(func(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64 {
+		return viewer.Authors[post.UserID]
+	})
+}
+
+// TagOverlap favours posts carrying the tags the viewer engages with, scored
+// by the best matching tag.
+func TagOverlap() Scorer {
+	return ScorerFunc(func(post *store.PostWithMetadata, viewer *Viewer, now time.Time) float64 {
+		var best float64
+		for _, tag := range post.Tags {
+			best = max(best, viewer.Tags[tag])
+		}
+		return best
+	})
+}
+
+type RankedPost struct {
+	*store.PostWithMetadata
+	Score float64 `json:"score"`
+}
+
+type Ranker interface {
+	Name() string
+	Rank(posts []*store.PostWithMetadata, viewer *Viewer, now time.Time) []*RankedPost
+}
+
+type Component struct {
+	Scorer Scorer
+	Weight float64
+}
+
+// Weighted scores posts by the weighted sum of its components, with newer
+// posts first on a tie.
+type Weighted struct {
+	name       string
+	components []Component
+}
+
+func NewWeighted(name string, components ...Component) *Weighted {
+	return &Weighted{name: name, components: components}
+}
+
+func (w *Weighted) Name() string { return w.name }
+
+func (w *Weighted) Rank(posts []*store.PostWithMetadata, viewer *Viewer, now time.Time) []*RankedPost {
+	ranked := make([]*RankedPost, len(posts))
+	for i, post := range posts {
+		var score float64
+		for _, c := range w.components {
+			score += c.Weight * c.Scorer.Score(post, viewer, now)
+		}
+		ranked[i] = &RankedPost{PostWithMetadata: post, Score: score}
+	}
+
+	slices.SortStableFunc(ranked, func(a, b *RankedPost) int {
+		switch {
+		case a.Score != b.Score:
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return b.CreatedAt.Compare(a.CreatedAt)
+		default:
+			return int(b.ID - a.ID)
+		}
+	})
+
+	return ranked
+}
+
+// Rankers returns the built-in strategies by name.
+func Rankers(halfLife time.Duration) map[string]Ranker {
+	rankers := []Ranker{
+		NewWeighted("balanced",
+			Component{Recency(halfLife), 0.4},
+			Component{Engagement(5), 0.2},
+			Component{Affinity(), 0.25},
+			Component{TagOverlap(), 0.15},
+		),
+		NewWeighted("recent",
+			Component{Recency(halfLife), 0.7},
+			Component{Engagement(5), 0.3},
+		),
+	}
+
+	byName := make(map[string]Ranker, len(rankers))
+	for _, ranker := range rankers {
+		byName[ranker.Name()] = ranker
+	}
+	return byName
+}
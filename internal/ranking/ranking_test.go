@@ -0,0 +1,113 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+func newPost(id, authorID int64, age time.Duration, comments int, tags ...string) *store.PostWithMetadata {
+	post := &store.PostWithMetadata{CommentCount: comments}
+	post.ID = id
+	post.UserID = authorID
+	post.CreatedAt = time.Unix(1_700_000_000, 0).Add(-age)
+	post.Tags = tags
+	return post
+}
+
+var now = time.Unix(1_700_000_000, 0)
+
+func TestScorers(t *testing.T) {
+	viewer := NewViewer(&store.ViewerHistory{
+		AuthorInteractions: map[int64]int{1: 4, 2: 1},
+		TagCounts:          map[string]int{"go": 10, "rust": 5},
+	})
+
+	tests := []struct {
+		name   string
+		scorer Scorer
+		post   *store.PostWithMetadata
+		want   float64
+	}{
+		{"recency of a new post", Recency(time.Hour), newPost(1, 1, 0, 0), 1},
+		{"recency after one half-life", Recency(time.Hour), newPost(1, 1, time.Hour, 0), 0.5},
+		{"engagement without comments", Engagement(5), newPost(1, 1, 0, 0), 0},
+		{"engagement at saturation", Engagement(5), newPost(1, 1, 0, 5), 0.5},
+		{"affinity with the closest author", Affinity(), newPost(1, 1, 0, 0), 1},
+		{"affinity with another author", Affinity(), newPost(1, 2, 0, 0), 0.25},
+		{"affinity with a stranger", Affinity(), newPost(1, 3, 0, 0), 0},
+		{"tag overlap takes the best tag", TagOverlap(), newPost(1, 1, 0, 0, "rust", "go"), 1},
+		{"tag overlap with unknown tags", TagOverlap(), newPost(1, 1, 0, 0, "zig"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scorer.Score(tt.post, viewer, now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWeightedRank(t *testing.T) {
+	viewer := NewViewer(&store.ViewerHistory{AuthorInteractions: map[int64]int{7: 3}})
+
+	ranker := NewWeighted("test",
+		Component{Recency(time.Hour), 0.5},
+		Component{Affinity(), 0.5},
+	)
+
+	posts := []*store.PostWithMetadata{
+		newPost(1, 2, 0, 0),              // new, unknown author
+		newPost(2, 7, 30*time.Minute, 0), // older, favourite author
+		newPost(3, 2, 0, 0),              // ties with 1
+	}
+
+	ranked := ranker.Rank(posts, viewer, now)
+
+	var got []int64
+	for _, post := range ranked {
+		got = append(got, post.ID)
+	}
+
+	want := []int64{2, 3, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, got)
+		}
+	}
+}
+
+func TestExperiment(t *testing.T) {
+	rankers := Rankers(time.Hour)
+
+	t.Run("should keep users on one ranker", func(t *testing.T) {
+		experiment, err := ParseExperiment("balanced:50,recent:50", rankers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts := map[string]int{}
+		for userID := int64(1); userID <= 1000; userID++ {
+			name := experiment.Pick(userID).Name()
+			if again := experiment.Pick(userID).Name(); again != name {
+				t.Fatalf("user %d moved from %s to %s", userID, name, again)
+			}
+			counts[name]++
+		}
+
+		if counts["balanced"] < 400 || counts["recent"] < 400 {
+			t.Errorf("expected an even split, got %v", counts)
+		}
+	})
+
+	t.Run("should reject bad variants", func(t *testing.T) {
+		for _, spec := range []string{"unknown:1", "balanced:x", "balanced:0"} {
+			if _, err := ParseExperiment(spec, rankers); err == nil {
+				t.Errorf("expected an error for %q", spec)
+			}
+		}
+	})
+}
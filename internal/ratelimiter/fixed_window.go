@@ -5,47 +5,80 @@ import (
 	"time"
 )
 
+// FixedWindowRateLimiter allows limit requests per key in each window,
+// counting from a key's first request.
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int
-	limit   int
-	window  time.Duration
+	sync.Mutex
+	clients   map[string]*fixedWindow
+	limit     int
+	window    time.Duration
+	lastSweep time.Time
+}
+
+type fixedWindow struct {
+	count   int
+	resetAt time.Time
 }
 
 func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
 	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
-		limit:   limit,
-		window:  window,
+		clients:   make(map[string]*fixedWindow),
+		limit:     limit,
+		window:    window,
+		lastSweep: time.Now(),
 	}
 }
 
-func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	counts, exist := rl.clients[ip]
+func (rl *FixedWindowRateLimiter) Allow(ip string) Result {
+	return rl.check(ip, true)
+}
 
-	rl.RUnlock()
+func (rl *FixedWindowRateLimiter) Peek(ip string) Result {
+	return rl.check(ip, false)
+}
 
-	if !exist || counts < rl.limit {
-		rl.Lock()
+func (rl *FixedWindowRateLimiter) check(ip string, consume bool) Result {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	rl.sweep(now)
 
-		if !exist {
-			go rl.resetCount(ip)
+	w, ok := rl.clients[ip]
+	if !ok || !now.Before(w.resetAt) {
+		w = &fixedWindow{resetAt: now.Add(rl.window)}
+		if consume {
+			rl.clients[ip] = w
 		}
+	}
 
-		rl.clients[ip]++
-		rl.Unlock()
+	if w.count >= rl.limit {
+		reset := w.resetAt.Sub(now)
+		return Result{Limit: rl.limit, RetryAfter: reset, Reset: reset}
+	}
 
-		return true, 0
+	if consume {
+		w.count++
 	}
 
-	return false, rl.window
+	res := Result{Allowed: true, Limit: rl.limit, Remaining: rl.limit - w.count}
+	if w.count > 0 {
+		res.Reset = w.resetAt.Sub(now)
+	}
 
+	return res
 }
 
-func (rl *FixedWindowRateLimiter) resetCount(ip string) {
-	time.Sleep(rl.window)
-	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
+// sweep drops windows that have ended. It runs at most once per window.
+func (rl *FixedWindowRateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.window {
+		return
+	}
+	rl.lastSweep = now
+
+	for ip, w := range rl.clients {
+		if !now.Before(w.resetAt) {
+			delete(rl.clients, ip)
+		}
+	}
 }
@@ -1,13 +1,197 @@
 package ratelimiter
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StrategyFixedWindow          = "fixed_window"
+	StrategySlidingWindow        = "sliding_window"
+	StrategySlidingWindowCounter = "sliding_window_counter"
+	StrategyTokenBucket          = "token_bucket"
+)
+
+// PolicyDefault applies to any request without a policy of its own.
+const PolicyDefault = "default"
 
 type RateLimiter interface {
-	Allow(key string) (bool, time.Duration)
+	// Allow counts a request against key's quota if it fits.
+	Allow(key string) Result
+	// Peek reports on key's quota without counting a request.
+	Peek(key string) Result
+}
+
+// Result is the state of a key's quota after a check.
+type Result struct {
+	// Allowed reports whether the request fits in the quota.
+	Allowed bool
+	Limit   int
+	// Remaining is how many more requests fit right now.
+	Remaining int
+	// RetryAfter is how long until a request fits again, when one does not.
+	RetryAfter time.Duration
+	// Reset is how long until the whole quota is available again.
+	Reset time.Duration
 }
 
 type Config struct {
-	RequestsPerTimeFrame int
-	TimeFrame            time.Duration
-	Enabled              bool
+	Enabled bool
+	// Policies is a policy spec for ParsePolicies.
+	Policies string
+}
+
+// Policy is a named limit of Limit requests per Window, enforced with
+// Strategy.
+type Policy struct {
+	Name     string
+	Strategy string
+	Limit    int
+	Window   time.Duration
+}
+
+// ParsePolicies reads policies written as "name=strategy:limit/window"
+// separated by commas, such as "default=fixed_window:20/5s,auth=token_bucket:5/1m".
+// A default policy is required.
+func ParsePolicies(spec string) ([]Policy, error) {
+	var policies []Policy
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, rest, ok := strings.Cut(part, "=")
+		strategy, rate, ok2 := strings.Cut(rest, ":")
+		limit, window, ok3 := strings.Cut(rate, "/")
+		if !ok || !ok2 || !ok3 || name == "" {
+			return nil, fmt.Errorf("invalid rate limit policy %q", part)
+		}
+
+		p := Policy{Name: name, Strategy: strategy}
+
+		var err error
+		if p.Limit, err = strconv.Atoi(limit); err != nil || p.Limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %q for rate limit policy %q", limit, name)
+		}
+		if p.Window, err = time.ParseDuration(window); err != nil || p.Window <= 0 {
+			return nil, fmt.Errorf("invalid window %q for rate limit policy %q", window, name)
+		}
+
+		switch strategy {
+		case StrategyFixedWindow, StrategySlidingWindow, StrategySlidingWindowCounter, StrategyTokenBucket:
+		default:
+			return nil, fmt.Errorf("unknown rate limiter strategy %q for policy %q", strategy, name)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("rate limit policy %q is defined twice", name)
+		}
+		seen[name] = true
+		policies = append(policies, p)
+	}
+
+	if !seen[PolicyDefault] {
+		return nil, fmt.Errorf("rate limit policies %q have no %s policy", spec, PolicyDefault)
+	}
+
+	return policies, nil
+}
+
+// New returns the limiter for a policy. With a Redis client the counters are
+// shared by every replica, with an in-memory limiter as a fallback for when
+// Redis is unreachable; without one they are kept in process. There is no
+// in-memory sliding window log, so that strategy counts in process with a
+// sliding window counter.
+func New(p Policy, rdb *redis.Client) RateLimiter {
+	var memory RateLimiter
+	switch p.Strategy {
+	case StrategyTokenBucket:
+		memory = NewTokenBucketLimiter(p.Limit, p.Window)
+	case StrategySlidingWindow, StrategySlidingWindowCounter:
+		memory = NewSlidingWindowCounterLimiter(p.Limit, p.Window)
+	default:
+		memory = NewFixedWindowLimiter(p.Limit, p.Window)
+	}
+
+	if rdb == nil {
+		return memory
+	}
+
+	switch p.Strategy {
+	case StrategyTokenBucket:
+		return NewRedisTokenBucketLimiter(rdb, p.Limit, p.Window, memory)
+	case StrategySlidingWindow:
+		return NewRedisSlidingWindowLimiter(rdb, p.Limit, p.Window, memory)
+	case StrategySlidingWindowCounter:
+		return NewRedisSlidingWindowCounterLimiter(rdb, p.Limit, p.Window, memory)
+	default:
+		return NewRedisFixedWindowLimiter(rdb, p.Limit, p.Window, memory)
+	}
+}
+
+// Policies holds a limiter per policy. Keys are namespaced by policy, so
+// the same client has a separate budget under each.
+type Policies struct {
+	policies []Policy
+	limiters map[string]RateLimiter
+}
+
+// Quota is a key's standing under one policy.
+type Quota struct {
+	Policy Policy
+	Result
+}
+
+func NewPolicies(policies []Policy, rdb *redis.Client) *Policies {
+	p := &Policies{
+		policies: policies,
+		limiters: make(map[string]RateLimiter, len(policies)),
+	}
+
+	for _, policy := range policies {
+		p.limiters[policy.Name] = New(policy, rdb)
+	}
+
+	return p
+}
+
+// Allow checks key against the named policy, or the default policy when
+// there is no policy by that name, and returns the policy applied.
+func (p *Policies) Allow(policy string, key string) (Policy, Result) {
+	if _, ok := p.limiters[policy]; !ok {
+		policy = PolicyDefault
+	}
+
+	return p.policy(policy), p.limiters[policy].Allow(policy + ":" + key)
+}
+
+func (p *Policies) policy(name string) Policy {
+	for _, policy := range p.policies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+
+	return Policy{}
+}
+
+// Quotas reports key's quota under every policy, in the order they were
+// given, without counting a request.
+func (p *Policies) Quotas(key string) []Quota {
+	quotas := make([]Quota, len(p.policies))
+	for i, policy := range p.policies {
+		quotas[i] = Quota{
+			Policy: policy,
+			Result: p.limiters[policy.Name].Peek(policy.Name + ":" + key),
+		}
+	}
+
+	return quotas
 }
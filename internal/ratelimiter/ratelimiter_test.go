@@ -0,0 +1,141 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("default=fixed_window:20/5s, auth=token_bucket:5/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Policy{
+		{Name: "default", Strategy: StrategyFixedWindow, Limit: 20, Window: 5 * time.Second},
+		{Name: "auth", Strategy: StrategyTokenBucket, Limit: 5, Window: time.Minute},
+	}
+	if len(policies) != len(want) {
+		t.Fatalf("got %d policies, want %d", len(policies), len(want))
+	}
+	for i := range want {
+		if policies[i] != want[i] {
+			t.Errorf("policy %d: got %+v, want %+v", i, policies[i], want[i])
+		}
+	}
+
+	for _, spec := range []string{
+		"auth=token_bucket:5/1m",
+		"default=leaky_bucket:5/1m",
+		"default=fixed_window:0/1m",
+		"default=fixed_window:5/soon",
+		"default=fixed_window",
+	} {
+		if _, err := ParsePolicies(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	rl := NewTokenBucketLimiter(3, 30*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("a").Allowed {
+			t.Fatalf("request %d of the burst was limited", i)
+		}
+	}
+
+	res := rl.Allow("a")
+	if res.Allowed {
+		t.Fatal("request past the burst was allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > 10*time.Millisecond {
+		t.Errorf("got retry after %v, want up to one token's refill", res.RetryAfter)
+	}
+
+	time.Sleep(res.RetryAfter + time.Millisecond)
+	if !rl.Allow("a").Allowed {
+		t.Error("request after a refill was limited")
+	}
+}
+
+func TestSlidingWindowQuota(t *testing.T) {
+	window := 10 * time.Second
+
+	tests := []struct {
+		name              string
+		previous, current int
+		elapsed           time.Duration
+		want              Result
+	}{
+		// 4 * 0.5 + 2 = 4 hits, so 6 more fit.
+		{"under the limit", 4, 2, 5 * time.Second, Result{Allowed: true, Limit: 10, Remaining: 6, Reset: 15 * time.Second}},
+		// 10 * 0.6 + 5 = 11 hits: full until the previous window's weight
+		// drops below 0.5, 5 seconds into this one.
+		{"previous window still weighs in", 10, 5, 4 * time.Second, Result{Limit: 10, RetryAfter: time.Second, Reset: 16 * time.Second}},
+		{"current window full", 0, 10, 4 * time.Second, Result{Limit: 10, RetryAfter: 6 * time.Second, Reset: 16 * time.Second}},
+		{"nothing counted", 0, 0, 4 * time.Second, Result{Allowed: true, Limit: 10, Remaining: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slidingWindowQuota(tt.previous, tt.current, 10, tt.elapsed, window); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitersReportQuota(t *testing.T) {
+	limiters := map[string]RateLimiter{
+		StrategyFixedWindow:          NewFixedWindowLimiter(3, time.Minute),
+		StrategyTokenBucket:          NewTokenBucketLimiter(3, time.Minute),
+		StrategySlidingWindowCounter: NewSlidingWindowCounterLimiter(3, time.Minute),
+	}
+
+	for name, rl := range limiters {
+		t.Run(name, func(t *testing.T) {
+			if res := rl.Peek("a"); !res.Allowed || res.Remaining != 3 || res.Reset != 0 {
+				t.Errorf("fresh key: got %+v", res)
+			}
+
+			for want := 2; want >= 0; want-- {
+				res := rl.Allow("a")
+				if !res.Allowed || res.Remaining != want || res.Limit != 3 {
+					t.Fatalf("got %+v, want %d remaining", res, want)
+				}
+				if res.Reset <= 0 || res.Reset > 2*time.Minute {
+					t.Errorf("got reset %v", res.Reset)
+				}
+			}
+
+			// Peeking does not use up quota, so it agrees with Allow.
+			for i := 0; i < 2; i++ {
+				if res := rl.Peek("a"); res.Allowed || res.Remaining != 0 || res.RetryAfter <= 0 {
+					t.Errorf("peek on a spent quota: got %+v", res)
+				}
+			}
+		})
+	}
+}
+
+func TestPoliciesSeparateBudgets(t *testing.T) {
+	p := NewPolicies([]Policy{
+		{Name: PolicyDefault, Strategy: StrategyFixedWindow, Limit: 1, Window: time.Minute},
+		{Name: "auth", Strategy: StrategyFixedWindow, Limit: 1, Window: time.Minute},
+	}, nil)
+
+	if _, res := p.Allow("auth", "ip:1.2.3.4"); !res.Allowed {
+		t.Fatal("first auth request was limited")
+	}
+	if _, res := p.Allow("auth", "ip:1.2.3.4"); res.Allowed {
+		t.Error("second auth request was allowed")
+	}
+	if _, res := p.Allow(PolicyDefault, "ip:1.2.3.4"); !res.Allowed {
+		t.Error("auth requests used up the default budget")
+	}
+	if _, res := p.Allow("unknown", "ip:1.2.3.4"); res.Allowed {
+		t.Error("unknown policy did not fall back to the default")
+	}
+}
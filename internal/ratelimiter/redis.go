@@ -0,0 +1,287 @@
+package ratelimiter
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis round trip. Allow sits in front of every
+// request, so a slow Redis falls back rather than stalling traffic.
+const redisTimeout = 250 * time.Millisecond
+
+// stats is published at /debug/vars as "ratelimiter".
+var stats = expvar.NewMap("ratelimiter")
+
+// Every script takes the window in milliseconds as ARGV[1], the limit as
+// ARGV[2], a member unique to this request as ARGV[3] and "1" as ARGV[4] to
+// count the request or "0" to only report on the quota. They return
+// {allowed, remaining, retry after in ms, reset in ms}.
+
+// fixedWindowScript counts hits in KEYS[1], which expires a window after the
+// first hit.
+var fixedWindowScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = window
+end
+
+if count >= limit then
+	return {0, 0, ttl, ttl}
+end
+
+if ARGV[4] == '1' then
+	count = redis.call('INCR', KEYS[1])
+	if count == 1 then
+		redis.call('PEXPIRE', KEYS[1], window)
+		ttl = window
+	end
+end
+
+if count == 0 then
+	ttl = 0
+end
+
+return {1, limit - count, 0, ttl}
+`)
+
+// slidingWindowScript keeps a log of hit times in the sorted set KEYS[1] and
+// allows a hit when fewer than the limit fall within the last window. Time
+// is read from Redis so replicas with skewed clocks agree.
+var slidingWindowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local function expiresIn(index)
+	local entry = redis.call('ZRANGE', KEYS[1], index, index, 'WITHSCORES')
+	return tonumber(entry[2]) + window - now
+end
+
+if count >= limit then
+	return {0, 0, expiresIn(0), expiresIn(-1)}
+end
+
+if ARGV[4] == '1' then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+end
+
+local reset = 0
+if count > 0 then
+	reset = expiresIn(-1)
+end
+
+return {1, limit - count, 0, reset}
+`)
+
+// tokenBucketScript keeps a bucket of up to limit tokens in the hash
+// KEYS[1], refilled at limit per window.
+var tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local rate = limit / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or limit
+local last = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + (now - last) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	allowed = 1
+	if ARGV[4] == '1' then
+		tokens = tokens - 1
+	end
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+if ARGV[4] == '1' then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+	redis.call('PEXPIRE', KEYS[1], window)
+end
+
+return {allowed, math.floor(tokens), retry, math.ceil((limit - tokens) / rate)}
+`)
+
+// slidingWindowCounterScript counts hits per fixed window in the hash
+// KEYS[1], keyed by window number, and allows a hit while the current count
+// plus the previous one, weighted by its overlap with the sliding window,
+// stays under the limit. See slidingWindowQuota.
+var slidingWindowCounterScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+local idx = math.floor(now / window)
+local elapsed = now - idx * window
+local currentField, previousField = tostring(idx), tostring(idx - 1)
+
+local counts = redis.call('HMGET', KEYS[1], previousField, currentField)
+local previous = tonumber(counts[1]) or 0
+local current = tonumber(counts[2]) or 0
+local estimate = previous * (1 - elapsed / window) + current
+
+local function reset()
+	if current > 0 then
+		return 2 * window - elapsed
+	elseif previous > 0 then
+		return window - elapsed
+	end
+	return 0
+end
+
+if estimate < limit then
+	if ARGV[4] == '1' then
+		redis.call('HINCRBY', KEYS[1], currentField, 1)
+		if redis.call('HLEN', KEYS[1]) > 2 then
+			for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+				if field ~= currentField and field ~= previousField then
+					redis.call('HDEL', KEYS[1], field)
+				end
+			end
+		end
+		redis.call('PEXPIRE', KEYS[1], window * 2)
+		current = current + 1
+		estimate = estimate + 1
+	end
+	return {1, math.max(0, math.ceil(limit - estimate)), 0, reset()}
+end
+
+if current >= limit then
+	return {0, 0, math.ceil(window - elapsed + window * (1 - limit / current)), reset()}
+end
+
+return {0, 0, math.max(1, math.ceil(window * (1 - (limit - current) / previous) - elapsed)), reset()}
+`)
+
+// RedisRateLimiter shares its counters across API replicas through Redis.
+// When Redis cannot be reached it defers to a process-local fallback, so
+// limits loosen during an outage rather than blocking or letting everything
+// through.
+type RedisRateLimiter struct {
+	rdb      *redis.Client
+	script   *redis.Script
+	prefix   string
+	limit    int
+	window   time.Duration
+	fallback RateLimiter
+}
+
+// NewRedisFixedWindowLimiter allows limit requests per key in each window,
+// counting from a key's first request.
+func NewRedisFixedWindowLimiter(rdb *redis.Client, limit int, window time.Duration, fallback RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		rdb:      rdb,
+		script:   fixedWindowScript,
+		prefix:   "ratelimit:fixed:",
+		limit:    limit,
+		window:   window,
+		fallback: fallback,
+	}
+}
+
+// NewRedisSlidingWindowLimiter allows limit requests per key in any window
+// ending now, which avoids the burst of up to twice the limit a fixed
+// window lets through at its boundary.
+func NewRedisSlidingWindowLimiter(rdb *redis.Client, limit int, window time.Duration, fallback RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		rdb:      rdb,
+		script:   slidingWindowScript,
+		prefix:   "ratelimit:sliding:",
+		limit:    limit,
+		window:   window,
+		fallback: fallback,
+	}
+}
+
+// NewRedisTokenBucketLimiter is the Redis counterpart of
+// TokenBucketLimiter.
+func NewRedisTokenBucketLimiter(rdb *redis.Client, limit int, window time.Duration, fallback RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		rdb:      rdb,
+		script:   tokenBucketScript,
+		prefix:   "ratelimit:bucket:",
+		limit:    limit,
+		window:   window,
+		fallback: fallback,
+	}
+}
+
+// NewRedisSlidingWindowCounterLimiter is the Redis counterpart of
+// SlidingWindowCounterLimiter.
+func NewRedisSlidingWindowCounterLimiter(rdb *redis.Client, limit int, window time.Duration, fallback RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		rdb:      rdb,
+		script:   slidingWindowCounterScript,
+		prefix:   "ratelimit:counter:",
+		limit:    limit,
+		window:   window,
+		fallback: fallback,
+	}
+}
+
+func (rl *RedisRateLimiter) Allow(key string) Result {
+	res, err := rl.run(key, true)
+	if err != nil {
+		stats.Add("redis_errors", 1)
+		return rl.fallback.Allow(key)
+	}
+
+	return res
+}
+
+func (rl *RedisRateLimiter) Peek(key string) Result {
+	res, err := rl.run(key, false)
+	if err != nil {
+		stats.Add("redis_errors", 1)
+		return rl.fallback.Peek(key)
+	}
+
+	return res
+}
+
+func (rl *RedisRateLimiter) run(key string, consume bool) (Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	member := fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Uint32())
+
+	flag := "0"
+	if consume {
+		flag = "1"
+	}
+
+	res, err := rl.script.Run(ctx, rl.rdb, []string{rl.prefix + key}, rl.window.Milliseconds(), rl.limit, member, flag).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(res) != 4 {
+		return Result{}, fmt.Errorf("rate limit script returned %d values", len(res))
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      rl.limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
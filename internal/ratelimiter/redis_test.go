@@ -0,0 +1,133 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return mr, rdb
+}
+
+func TestRedisFixedWindowLimiter(t *testing.T) {
+	mr, rdb := newRedis(t)
+	rl := NewRedisFixedWindowLimiter(rdb, 2, time.Minute, NewFixedWindowLimiter(2, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if !rl.Allow("1.2.3.4").Allowed {
+			t.Fatalf("request %d was limited", i)
+		}
+	}
+
+	res := rl.Allow("1.2.3.4")
+	if res.Allowed {
+		t.Fatal("third request was allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("got retry after %v, want within the window", res.RetryAfter)
+	}
+
+	if !rl.Allow("5.6.7.8").Allowed {
+		t.Error("another key was limited")
+	}
+
+	mr.FastForward(time.Minute)
+	if !rl.Allow("1.2.3.4").Allowed {
+		t.Error("request in the next window was limited")
+	}
+}
+
+func TestRedisSlidingWindowLimiter(t *testing.T) {
+	_, rdb := newRedis(t)
+	rl := NewRedisSlidingWindowLimiter(rdb, 3, time.Minute, NewFixedWindowLimiter(3, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("1.2.3.4").Allowed {
+			t.Fatalf("request %d was limited", i)
+		}
+	}
+
+	res := rl.Allow("1.2.3.4")
+	if res.Allowed {
+		t.Fatal("fourth request was allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("got retry after %v, want within the window", res.RetryAfter)
+	}
+}
+
+func TestRedisLimiterFallsBack(t *testing.T) {
+	mr, rdb := newRedis(t)
+	rl := NewRedisFixedWindowLimiter(rdb, 1, time.Minute, NewFixedWindowLimiter(1, time.Minute))
+	mr.Close()
+
+	if !rl.Allow("1.2.3.4").Allowed {
+		t.Fatal("first request was limited by the fallback")
+	}
+	if rl.Allow("1.2.3.4").Allowed {
+		t.Error("fallback did not limit the second request")
+	}
+}
+
+func redisErrors() string {
+	if n := stats.Get("redis_errors"); n != nil {
+		return n.String()
+	}
+	return "0"
+}
+
+func TestRedisLimitersReportQuota(t *testing.T) {
+	_, rdb := newRedis(t)
+	errorsBefore := redisErrors()
+
+	limiters := map[string]RateLimiter{
+		StrategyFixedWindow:          NewRedisFixedWindowLimiter(rdb, 3, time.Minute, NewFixedWindowLimiter(3, time.Minute)),
+		StrategySlidingWindow:        NewRedisSlidingWindowLimiter(rdb, 3, time.Minute, NewSlidingWindowCounterLimiter(3, time.Minute)),
+		StrategyTokenBucket:          NewRedisTokenBucketLimiter(rdb, 3, time.Minute, NewTokenBucketLimiter(3, time.Minute)),
+		StrategySlidingWindowCounter: NewRedisSlidingWindowCounterLimiter(rdb, 3, time.Minute, NewSlidingWindowCounterLimiter(3, time.Minute)),
+	}
+
+	for name, rl := range limiters {
+		t.Run(name, func(t *testing.T) {
+			if res := rl.Peek("1.2.3.4"); !res.Allowed || res.Remaining != 3 || res.Reset != 0 {
+				t.Errorf("fresh key: got %+v", res)
+			}
+
+			for want := 2; want >= 0; want-- {
+				res := rl.Allow("1.2.3.4")
+				if !res.Allowed || res.Remaining != want || res.Limit != 3 {
+					t.Fatalf("got %+v, want %d remaining", res, want)
+				}
+				if res.Reset <= 0 || res.Reset > 2*time.Minute {
+					t.Errorf("got reset %v", res.Reset)
+				}
+			}
+
+			if res := rl.Peek("1.2.3.4"); res.Allowed || res.Remaining != 0 {
+				t.Errorf("peek on a spent quota: got %+v", res)
+			}
+
+			res := rl.Allow("1.2.3.4")
+			if res.Allowed {
+				t.Fatal("fourth request was allowed")
+			}
+			if res.RetryAfter <= 0 || res.RetryAfter > 2*time.Minute {
+				t.Errorf("got retry after %v", res.RetryAfter)
+			}
+		})
+	}
+
+	// Errors would mean the scripts failed and the fallback answered.
+	if n := redisErrors(); n != errorsBefore {
+		t.Errorf("redis errors went from %s to %s", errorsBefore, n)
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingWindowCounterLimiter approximates a sliding window from two fixed
+// window counters: the previous window's count is weighted by how much of it
+// still overlaps the sliding window ending now. It needs two counters per
+// key where a sliding window log needs one entry per request.
+type SlidingWindowCounterLimiter struct {
+	mu        sync.Mutex
+	counters  map[string]*windowCounter
+	limit     int
+	window    time.Duration
+	lastSweep time.Time
+}
+
+type windowCounter struct {
+	start    time.Time
+	previous int
+	current  int
+}
+
+func NewSlidingWindowCounterLimiter(limit int, window time.Duration) *SlidingWindowCounterLimiter {
+	return &SlidingWindowCounterLimiter{
+		counters:  make(map[string]*windowCounter),
+		limit:     limit,
+		window:    window,
+		lastSweep: time.Now(),
+	}
+}
+
+func (rl *SlidingWindowCounterLimiter) Allow(key string) Result {
+	return rl.check(key, true)
+}
+
+func (rl *SlidingWindowCounterLimiter) Peek(key string) Result {
+	return rl.check(key, false)
+}
+
+func (rl *SlidingWindowCounterLimiter) check(key string, consume bool) Result {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	start := now.Truncate(rl.window)
+	rl.sweep(now)
+
+	c, ok := rl.counters[key]
+	switch {
+	case !ok:
+		c = &windowCounter{start: start}
+	case start.Sub(c.start) == rl.window:
+		c = &windowCounter{start: start, previous: c.current}
+	case start.Sub(c.start) > rl.window:
+		c = &windowCounter{start: start}
+	}
+
+	elapsed := now.Sub(start)
+	res := slidingWindowQuota(c.previous, c.current, rl.limit, elapsed, rl.window)
+
+	if consume {
+		if res.Allowed {
+			c.current++
+			res = slidingWindowQuota(c.previous, c.current, rl.limit, elapsed, rl.window)
+			res.Allowed, res.RetryAfter = true, 0
+		}
+		rl.counters[key] = c
+	}
+
+	return res
+}
+
+// sweep drops counters with no requests in the last two windows. It runs at
+// most once per window.
+func (rl *SlidingWindowCounterLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.window {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, c := range rl.counters {
+		if now.Sub(c.start) >= 2*rl.window {
+			delete(rl.counters, key)
+		}
+	}
+}
+
+// slidingWindowQuota works out the quota for a request elapsed into the
+// current window: whether it fits under limit, how many more would, how long
+// until one would if not, and how long until the counts no longer weigh in
+// at all.
+func slidingWindowQuota(previous, current, limit int, elapsed, window time.Duration) Result {
+	res := Result{Limit: limit}
+
+	switch {
+	case current > 0:
+		// The current count weighs in until the end of the next window.
+		res.Reset = 2*window - elapsed
+	case previous > 0:
+		res.Reset = window - elapsed
+	}
+
+	estimate := float64(previous)*(1-float64(elapsed)/float64(window)) + float64(current)
+	if estimate < float64(limit) {
+		res.Allowed = true
+		res.Remaining = int(math.Ceil(float64(limit) - estimate))
+		return res
+	}
+
+	// The current window alone is full: wait for it to roll over and for
+	// its count, now the previous one, to decay below the limit.
+	if current >= limit {
+		res.RetryAfter = window - elapsed + time.Duration(float64(window)*(1-float64(limit)/float64(current)))
+		return res
+	}
+
+	// Otherwise wait for the previous window's weight to fall far enough.
+	needed := 1 - float64(limit-current)/float64(previous)
+	res.RetryAfter = max(time.Duration(float64(window)*needed)-elapsed, time.Millisecond)
+	return res
+}
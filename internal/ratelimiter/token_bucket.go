@@ -0,0 +1,100 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucketLimiter gives each key a bucket of limit tokens that refills at
+// limit per window. A request takes a token, so bursts up to limit are
+// allowed after a quiet spell while the long-run rate stays at limit per
+// window.
+type TokenBucketLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	limit     int
+	window    time.Duration
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucketLimiter(limit int, window time.Duration) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		limit:     limit,
+		window:    window,
+		lastSweep: time.Now(),
+	}
+}
+
+func (rl *TokenBucketLimiter) Allow(key string) Result {
+	return rl.check(key, true)
+}
+
+func (rl *TokenBucketLimiter) Peek(key string) Result {
+	return rl.check(key, false)
+}
+
+func (rl *TokenBucketLimiter) check(key string, consume bool) Result {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rl.limit), last: now}
+	}
+
+	tokens := rl.refill(b, now)
+	res := Result{Limit: rl.limit}
+
+	if tokens >= 1 {
+		res.Allowed = true
+		if consume {
+			tokens--
+		}
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) / rl.rate())
+	}
+
+	if consume {
+		b.tokens, b.last = tokens, now
+		rl.buckets[key] = b
+	}
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = time.Duration((float64(rl.limit) - tokens) / rl.rate())
+
+	return res
+}
+
+// rate is the refill rate in tokens per nanosecond.
+func (rl *TokenBucketLimiter) rate() float64 {
+	return float64(rl.limit) / float64(rl.window)
+}
+
+func (rl *TokenBucketLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return min(float64(rl.limit), b.tokens+float64(now.Sub(b.last))*rl.rate())
+}
+
+// sweep drops buckets that have refilled completely, which behave the same
+// as missing ones. It runs at most once per window.
+func (rl *TokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.window {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, b := range rl.buckets {
+		if rl.refill(b, now) >= float64(rl.limit) {
+			delete(rl.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package search
+
+import (
+	"context"
+	"html"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// Titles outweigh bodies the way Postgres weighs the A and B labels on the
+// tsvector columns.
+const (
+	titleWeight     = 1.0
+	bodyWeight      = 0.4
+	maxSnippetWords = 35
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+type docKey struct {
+	docType string
+	id      int64
+}
+
+// posting counts how often a term appears in each field of a document.
+type posting struct {
+	title int
+	body  int
+}
+
+// Memory is an in-memory inverted index. Every query term must match, with
+// no stemming or typo tolerance, so it finds fewer near misses than
+// Postgres. It starts empty, only learns about changes it is fed and skips
+// some of the Postgres visibility rules, so it is for tests and local
+// development only.
+type Memory struct {
+	mu       sync.RWMutex
+	docs     map[docKey]Document
+	counts   map[string]int
+	postings map[string]map[string]map[int64]posting // type, term, document ID
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		docs:     make(map[docKey]Document),
+		counts:   make(map[string]int),
+		postings: make(map[string]map[string]map[int64]posting),
+	}
+}
+
+func (m *Memory) Index(ctx context.Context, doc Document) error {
+	if doc.Type != TypePosts && doc.Type != TypeUsers {
+		return ErrUnsupportedType
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := docKey{doc.Type, doc.ID}
+	m.remove(key)
+
+	m.docs[key] = doc
+	m.counts[doc.Type]++
+
+	terms := m.postings[doc.Type]
+	if terms == nil {
+		terms = make(map[string]map[int64]posting)
+		m.postings[doc.Type] = terms
+	}
+
+	add := func(text string, field func(*posting)) {
+		for _, term := range indexTerms(text) {
+			if terms[term] == nil {
+				terms[term] = make(map[int64]posting)
+			}
+			p := terms[term][doc.ID]
+			field(&p)
+			terms[term][doc.ID] = p
+		}
+	}
+
+	add(doc.Title, func(p *posting) { p.title++ })
+	add(doc.Body, func(p *posting) { p.body++ })
+
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, docType string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(docKey{docType, id})
+	return nil
+}
+
+// remove drops a document from the index. The caller must hold the write
+// lock.
+func (m *Memory) remove(key docKey) {
+	doc, ok := m.docs[key]
+	if !ok {
+		return
+	}
+
+	terms := m.postings[key.docType]
+	for _, term := range append(indexTerms(doc.Title), indexTerms(doc.Body)...) {
+		delete(terms[term], key.id)
+		if len(terms[term]) == 0 {
+			delete(terms, term)
+		}
+	}
+
+	delete(m.docs, key)
+	m.counts[key.docType]--
+}
+
+func (m *Memory) Search(ctx context.Context, query Query) ([]Hit, store.Metadata, error) {
+	if query.Type != TypePosts && query.Type != TypeUsers {
+		return nil, store.Metadata{}, ErrUnsupportedType
+	}
+
+	queryTerms := slices.Compact(slices.Sorted(slices.Values(indexTerms(query.Text))))
+	if len(queryTerms) == 0 {
+		return []Hit{}, store.Metadata{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := m.postings[query.Type]
+
+	// Walk the rarest term's postings; every other term must match too.
+	slices.SortFunc(queryTerms, func(a, b string) int { return len(terms[a]) - len(terms[b]) })
+
+	var hits []Hit
+	for id := range terms[queryTerms[0]] {
+		doc := m.docs[docKey{query.Type, id}]
+		if !m.visible(doc, query.ViewerID) {
+			continue
+		}
+
+		score, ok := m.score(terms, queryTerms, query.Type, id)
+		if !ok {
+			continue
+		}
+
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return int(b.ID - a.ID)
+	})
+
+	total := len(hits)
+	start := min(query.Paginate.Offset(), total)
+	end := min(start+query.Paginate.Limit(), total)
+	hits = hits[start:end]
+
+	match := make(map[string]bool, len(queryTerms))
+	for _, term := range queryTerms {
+		match[term] = true
+	}
+
+	for i := range hits {
+		hits[i].Source = m.source(m.docs[docKey{query.Type, hits[i].ID}], hits[i].Score, match)
+	}
+
+	return hits, store.CalculateMetadata(total, query.Paginate.Page, query.Paginate.PageSize), nil
+}
+
+// score sums, for each query term, how often it appears in each field of the
+// document, weighted by field and by how rare the term is. It reports false
+// if any term is missing.
+func (m *Memory) score(terms map[string]map[int64]posting, queryTerms []string, docType string, id int64) (float64, bool) {
+	var (
+		score float64
+		n     = float64(m.counts[docType])
+	)
+
+	saturate := func(tf int) float64 { return float64(tf) / float64(tf+1) }
+
+	for _, term := range queryTerms {
+		p, ok := terms[term][id]
+		if !ok {
+			return 0, false
+		}
+
+		idf := math.Log(1 + n/float64(len(terms[term])))
+		score += idf * (titleWeight*saturate(p.title) + bodyWeight*saturate(p.body))
+	}
+
+	return score, true
+}
+
+// visible is a subset of the Postgres rules: hidden posts and posts by
+// private users are only found by their author. Blocks, suspensions and
+// follower access are not checked.
+func (m *Memory) visible(doc Document, viewerID int64) bool {
+	if doc.Type != TypePosts || doc.User.ID == viewerID {
+		return true
+	}
+
+	if doc.Hidden {
+		return false
+	}
+
+	author, ok := m.docs[docKey{TypeUsers, doc.User.ID}]
+	return !ok || !author.Private
+}
+
+func (m *Memory) source(doc Document, score float64, match map[string]bool) any {
+	if doc.Type == TypeUsers {
+		return &store.UserSearchResult{
+			UserSummary: doc.User,
+			Name:        highlight(doc.Body, match),
+			Rank:        score,
+		}
+	}
+
+	author := doc.User
+	if user, ok := m.docs[docKey{TypeUsers, doc.User.ID}]; ok {
+		author = user.User
+	}
+
+	return &store.PostSearchResult{
+		ID:        doc.ID,
+		Title:     highlight(doc.Title, match),
+		Snippet:   snippet(doc.Body, match),
+		Tags:      doc.Tags,
+		CreatedAt: doc.CreatedAt,
+		Author:    author,
+		Rank:      score,
+	}
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower-cased runs of letters and digits.
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		start  = -1
+	)
+
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+// indexTerms returns the indexable terms in text, leaving out stop words.
+func indexTerms(text string) []string {
+	var terms []string
+	for _, t := range tokenize(text) {
+		if !stopWords[t.term] {
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// highlight HTML-escapes text and wraps matching terms in <mark> tags.
+func highlight(text string, match map[string]bool) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+
+	for _, t := range tokenize(text) {
+		if !match[t.term] {
+			continue
+		}
+
+		b.WriteString(html.EscapeString(text[last:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString("</mark>")
+		last = t.end
+	}
+
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// snippet highlights up to maxSnippetWords words of text, starting just
+// before the first match.
+func snippet(text string, match map[string]bool) string {
+	tokens := tokenize(text)
+	if len(tokens) <= maxSnippetWords {
+		return highlight(text, match)
+	}
+
+	first := slices.IndexFunc(tokens, func(t token) bool { return match[t.term] })
+	start := min(max(first-5, 0), len(tokens)-maxSnippetWords)
+	end := start + maxSnippetWords
+
+	fragment := highlight(text[tokens[start].start:tokens[end-1].end], match)
+	if start > 0 {
+		fragment = "… " + fragment
+	}
+	if end < len(tokens) {
+		fragment += " …"
+	}
+
+	return fragment
+}
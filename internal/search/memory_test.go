@@ -0,0 +1,167 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+func page(size int) store.PaginateQueryFilter {
+	return store.PaginateQueryFilter{Page: 1, PageSize: size}
+}
+
+func post(id, authorID int64, title, body string) Document {
+	return Document{Type: TypePosts, ID: id, User: store.UserSummary{ID: authorID}, Title: title, Body: body}
+}
+
+func newIndex(t *testing.T, docs ...Document) *Memory {
+	t.Helper()
+
+	index := NewMemory()
+	for _, doc := range docs {
+		if err := index.Index(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func ids(hits []Hit) []int64 {
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+func TestMemorySearch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should rank title matches above body matches", func(t *testing.T) {
+		index := newIndex(t,
+			post(1, 10, "Weekend plans", "Going hiking in the mountains"),
+			post(2, 10, "Hiking the coast", "Three days on the trail"),
+			post(3, 10, "Recipes", "Nothing about the outdoors"),
+		)
+
+		hits, metadata, err := index.Search(ctx, Query{Type: TypePosts, Text: "hiking", Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(hits); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+			t.Errorf("expected hits [2 1], got %v", got)
+		}
+
+		if metadata.TotalRecords != 2 {
+			t.Errorf("expected 2 total records, got %d", metadata.TotalRecords)
+		}
+	})
+
+	t.Run("should require every term to match", func(t *testing.T) {
+		index := newIndex(t,
+			post(1, 10, "Go generics", "Type parameters explained"),
+			post(2, 10, "Go modules", "Versioning explained"),
+		)
+
+		hits, _, err := index.Search(ctx, Query{Type: TypePosts, Text: "go generics", Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(hits); len(got) != 1 || got[0] != 1 {
+			t.Errorf("expected hits [1], got %v", got)
+		}
+	})
+
+	t.Run("should hide hidden posts and posts by private users from others", func(t *testing.T) {
+		hidden := post(1, 10, "Secret garden", "")
+		hidden.Hidden = true
+
+		index := newIndex(t,
+			hidden,
+			post(2, 20, "Garden party", ""),
+			Document{Type: TypeUsers, ID: 20, User: store.UserSummary{ID: 20, Username: "ada"}, Title: "ada", Private: true},
+			post(3, 30, "Garden tools", ""),
+		)
+
+		hits, _, err := index.Search(ctx, Query{Type: TypePosts, Text: "garden", ViewerID: 99, Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(hits); len(got) != 1 || got[0] != 3 {
+			t.Errorf("expected hits [3] for another user, got %v", got)
+		}
+
+		hits, _, err = index.Search(ctx, Query{Type: TypePosts, Text: "garden", ViewerID: 10, Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(hits); len(got) != 2 {
+			t.Errorf("expected the author to find their hidden post, got %v", got)
+		}
+	})
+
+	t.Run("should forget deleted and replaced documents", func(t *testing.T) {
+		index := newIndex(t, post(1, 10, "Old title", ""), post(2, 10, "Old news", ""))
+
+		if err := index.Index(ctx, post(1, 10, "New title", "")); err != nil {
+			t.Fatal(err)
+		}
+		if err := index.Delete(ctx, TypePosts, 2); err != nil {
+			t.Fatal(err)
+		}
+
+		hits, _, err := index.Search(ctx, Query{Type: TypePosts, Text: "old", Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(hits) != 0 {
+			t.Errorf("expected no hits, got %v", ids(hits))
+		}
+	})
+
+	t.Run("should paginate hits", func(t *testing.T) {
+		index := newIndex(t, post(1, 10, "Tea", ""), post(2, 10, "Tea", ""), post(3, 10, "Tea", ""))
+
+		hits, metadata, err := index.Search(ctx, Query{
+			Type:     TypePosts,
+			Text:     "tea",
+			Paginate: store.PaginateQueryFilter{Page: 2, PageSize: 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(hits); len(got) != 1 || got[0] != 1 {
+			t.Errorf("expected hits [1] on the second page, got %v", got)
+		}
+
+		if metadata.LastPage != 2 || metadata.TotalRecords != 3 {
+			t.Errorf("unexpected metadata %+v", metadata)
+		}
+	})
+
+	t.Run("should escape and highlight matches", func(t *testing.T) {
+		index := newIndex(t, post(1, 10, "<b>Coffee</b> & cake", strings.Repeat("word ", 50)+"coffee"))
+
+		hits, _, err := index.Search(ctx, Query{Type: TypePosts, Text: "coffee", Paginate: page(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := hits[0].Source.(*store.PostSearchResult)
+		if want := "&lt;b&gt;<mark>Coffee</mark>&lt;/b&gt; &amp; cake"; result.Title != want {
+			t.Errorf("expected title %q, got %q", want, result.Title)
+		}
+
+		if !strings.HasPrefix(result.Snippet, "… ") || !strings.HasSuffix(result.Snippet, "<mark>coffee</mark>") {
+			t.Errorf("expected a trimmed snippet ending in the match, got %q", result.Snippet)
+		}
+	})
+}
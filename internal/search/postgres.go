@@ -0,0 +1,62 @@
+package search
+
+import (
+	"context"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type Store interface {
+	SearchPosts(ctx context.Context, viewerID int64, query string, paginateQuery store.PaginateQueryFilter) ([]*store.PostSearchResult, store.Metadata, error)
+	SearchUsers(ctx context.Context, viewerID int64, query string, paginateQuery store.PaginateQueryFilter) ([]*store.UserSearchResult, store.Metadata, error)
+}
+
+// Postgres searches the tsvector columns on the posts and users tables.
+// Postgres keeps those columns current itself, so Index and Delete do
+// nothing.
+type Postgres struct {
+	store Store
+}
+
+func NewPostgres(store Store) *Postgres {
+	return &Postgres{store: store}
+}
+
+func (p *Postgres) Index(ctx context.Context, doc Document) error {
+	return nil
+}
+
+func (p *Postgres) Delete(ctx context.Context, docType string, id int64) error {
+	return nil
+}
+
+func (p *Postgres) Search(ctx context.Context, query Query) ([]Hit, store.Metadata, error) {
+	switch query.Type {
+	case TypePosts:
+		results, metadata, err := p.store.SearchPosts(ctx, query.ViewerID, query.Text, query.Paginate)
+		if err != nil {
+			return nil, store.Metadata{}, err
+		}
+
+		hits := make([]Hit, len(results))
+		for i, result := range results {
+			hits[i] = Hit{ID: result.ID, Score: result.Rank, Source: result}
+		}
+		return hits, metadata, nil
+
+	case TypeUsers:
+		results, metadata, err := p.store.SearchUsers(ctx, query.ViewerID, query.Text, query.Paginate)
+		if err != nil {
+			return nil, store.Metadata{}, err
+		}
+
+		hits := make([]Hit, len(results))
+		for i, result := range results {
+			hits[i] = Hit{ID: result.ID, Score: result.Rank, Source: result}
+		}
+		return hits, metadata, nil
+
+	default:
+		return nil, store.Metadata{}, ErrUnsupportedType
+	}
+}
@@ -0,0 +1,65 @@
+// Package search puts full-text search behind an Index so the engine can be
+// swapped without touching the handlers. Indexes are kept current by Sink,
+// which applies post and user change events from the outbox.
+package search
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+const (
+	TypePosts = store.SearchPosts
+	TypeUsers = store.SearchUsers
+)
+
+var ErrUnsupportedType = errors.New("search: unsupported document type")
+
+// Document is what gets indexed for a post or a user.
+type Document struct {
+	Type string
+	ID   int64
+	// User is the post's author, or the user itself for a user document.
+	User  store.UserSummary
+	Title string
+	Body  string
+	Tags  []string
+	// Hidden posts are only found by their author; Private users' posts
+	// are only found by the user.
+	Hidden    bool
+	Private   bool
+	CreatedAt time.Time
+}
+
+type Query struct {
+	Type     string
+	Text     string
+	ViewerID int64
+	Paginate store.PaginateQueryFilter
+}
+
+// Hit is one result. Source is the result as returned to clients: a
+// *store.PostSearchResult or a *store.UserSearchResult.
+type Hit struct {
+	ID     int64
+	Score  float64
+	Source any
+}
+
+type Index interface {
+	Index(ctx context.Context, doc Document) error
+	Delete(ctx context.Context, docType string, id int64) error
+	Search(ctx context.Context, query Query) ([]Hit, store.Metadata, error)
+}
+
+// Sources returns the source of each hit.
+func Sources(hits []Hit) []any {
+	sources := make([]any, len(hits))
+	for i, hit := range hits {
+		sources[i] = hit.Source
+	}
+	return sources
+}
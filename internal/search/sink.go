@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/devphaseX/mingle.git/internal/outbox"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// Sink is an outbox sink that keeps an Index current as posts and users
+// change. Applying an event twice leaves the index as it was, so redelivered
+// batches are harmless.
+type Sink struct {
+	index Index
+}
+
+func NewSink(index Index) *Sink {
+	return &Sink{index: index}
+}
+
+func (s *Sink) Name() string { return "search" }
+
+// Publish applies events in order. Payloads that cannot be decoded fail with
+// a permanent error, so the relay dead-letters them instead of retrying.
+func (s *Sink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	for _, event := range events {
+		if err := s.apply(ctx, event); err != nil {
+			return fmt.Errorf("applying %s event %d: %w", event.Type, event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sink) apply(ctx context.Context, event *store.OutboxEvent) error {
+	switch event.Type {
+	case store.EventPostCreated, store.EventPostUpdated:
+		var post store.Post
+		if err := json.Unmarshal(event.Payload, &post); err != nil {
+			return outbox.Permanent(err)
+		}
+
+		return s.index.Index(ctx, Document{
+			Type:      TypePosts,
+			ID:        post.ID,
+			User:      store.UserSummary{ID: post.UserID},
+			Title:     post.Title,
+			Body:      post.Context,
+			Tags:      post.Tags,
+			Hidden:    post.HiddenAt != nil,
+			CreatedAt: post.CreatedAt,
+		})
+
+	case store.EventPostDeleted:
+		return s.index.Delete(ctx, TypePosts, event.AggregateID)
+
+	case store.EventUserCreated, store.EventUserActivated:
+		var user store.UserEvent
+		if err := json.Unmarshal(event.Payload, &user); err != nil {
+			return outbox.Permanent(err)
+		}
+
+		// Only active users can be found.
+		if !user.IsActive {
+			return s.index.Delete(ctx, TypeUsers, user.ID)
+		}
+
+		return s.index.Index(ctx, Document{
+			Type: TypeUsers,
+			ID:   user.ID,
+			User: store.UserSummary{
+				ID:        user.ID,
+				Username:  user.Username,
+				FirstName: user.FirstName,
+				LastName:  user.LastName,
+			},
+			Title:     user.Username,
+			Body:      strings.TrimSpace(user.FirstName + " " + user.LastName),
+			Private:   user.IsPrivate,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+
+	return nil
+}
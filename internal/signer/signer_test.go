@@ -0,0 +1,79 @@
+package signer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func newTestSigner(t *testing.T, key string) *Signer {
+	t.Helper()
+
+	s, err := New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewRejectsShortKeys(t *testing.T) {
+	for _, key := range []string{"", "secret", testKey[:MinKeySize-1]} {
+		if _, err := New(key); err == nil {
+			t.Errorf("New(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	s := newTestSigner(t, testKey)
+
+	payload, err := s.Verify(s.Sign([]byte(`{"user_id":1}`), time.Hour))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if string(payload) != `{"user_id":1}` {
+		t.Errorf("payload = %s", payload)
+	}
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	s := newTestSigner(t, testKey)
+	other := newTestSigner(t, strings.Repeat("k", MinKeySize))
+
+	token := s.Sign([]byte(`{"user_id":1}`), time.Hour)
+	parts := strings.Split(token, ".")
+	forged := strings.Split(s.Sign([]byte(`{"user_id":2}`), time.Hour), ".")
+
+	tests := []struct {
+		name   string
+		token  string
+		signer *Signer
+	}{
+		{"empty", "", s},
+		{"no signature", parts[0] + "." + parts[1], s},
+		{"swapped payload", forged[0] + "." + parts[1] + "." + parts[2], s},
+		{"extended expiry", parts[0] + "." + parts[1] + "0." + parts[2], s},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-2], s},
+		{"bad encoding", parts[0] + "." + parts[1] + ".!!", s},
+		{"other key", token, other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(tt.token); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsExpiredTokens(t *testing.T) {
+	s := newTestSigner(t, testKey)
+
+	if _, err := s.Verify(s.Sign([]byte("payload"), -time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify = %v, want ErrExpired", err)
+	}
+}
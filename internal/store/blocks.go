@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Block struct {
+	UserID    int64     `json:"user_id"`
+	BlockedID int64     `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block blocks a user. Follows and pending follow requests between the two
+// users are removed in both directions.
+func (s *BlockStore) Block(ctx context.Context, block *Block) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO user_blocks(user_id, blocked_id) VALUES ($1, $2) RETURNING created_at`
+
+		err := tx.QueryRowContext(ctx, query, block.UserID, block.BlockedID).Scan(&block.CreatedAt)
+		if err != nil {
+			var pgErr *pq.Error
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		unfollowStmt := `DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`
+		if _, err := tx.ExecContext(ctx, unfollowStmt, block.UserID, block.BlockedID); err != nil {
+			return err
+		}
+
+		requestsStmt := `DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)`
+		_, err = tx.ExecContext(ctx, requestsStmt, block.UserID, block.BlockedID)
+
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, userID int64, blockedID int64) error {
+	stmt := `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, userID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID int64, otherID int64) (bool, error) {
+	blocked, err := s.BlockedAmong(ctx, userID, []int64{otherID})
+	if err != nil {
+		return false, err
+	}
+
+	return len(blocked) > 0, nil
+}
+
+// BlockedAmong returns the users in otherIDs that have a block with userID in
+// either direction.
+func (s *BlockStore) BlockedAmong(ctx context.Context, userID int64, otherIDs []int64) ([]int64, error) {
+	query := `SELECT DISTINCT CASE WHEN user_id = $1 THEN blocked_id ELSE user_id END
+		FROM user_blocks
+		WHERE (user_id = $1 AND blocked_id = ANY($2::bigint[]))
+		OR (blocked_id = $1 AND user_id = ANY($2::bigint[]))`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(otherIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, id)
+	}
+
+	return blocked, rows.Err()
+}
+
+// notBlockedBetween matches rows where neither user has blocked the other.
+func notBlockedBetween(userColumn string, otherColumn string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.user_id = %[1]s AND ub.blocked_id = %[2]s) OR (ub.user_id = %[2]s AND ub.blocked_id = %[1]s)
+	)`, userColumn, otherColumn)
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrBreakerOpen is returned for calls skipped while a Breaker is open.
+var ErrBreakerOpen = errors.New("cache: circuit breaker open")
+
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failures that opens the
+	// breaker.
+	Threshold int
+	// Cooldown is how long the breaker stays open before letting a single
+	// probe through.
+	Cooldown time.Duration
+}
+
+// maxPending bounds the invalidations a Breaker holds for replay. Past it,
+// further keys are widened to their cache's whole namespace.
+const maxPending = 10000
+
+// flushTimeout bounds replaying held invalidations.
+const flushTimeout = 5 * time.Second
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// Breaker wraps a backend that may go away, such as Redis. After Threshold
+// consecutive failures it stops calling the backend for Cooldown, then lets
+// one call through as a probe: success closes it again and failure restarts
+// the cooldown. While open, reads are misses and writes are skipped, so a
+// cache outage costs database load rather than failed requests.
+//
+// Deletes that are skipped or fail are held and replayed before the next
+// call reaches the backend, so it cannot serve entries that should be gone
+// once it is back.
+type Breaker struct {
+	next   Backend
+	cfg    BreakerConfig
+	logger *zap.SugaredLogger
+	stats  *expvar.Map
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// flushMu makes calls wait for a replay in progress, as it may be
+	// deleting the entries they are about to read.
+	flushMu         sync.Mutex
+	pendingKeys     map[string]struct{}
+	pendingPrefixes map[string]struct{}
+}
+
+// NewBreaker wraps next, publishing its state and counters under name in
+// the cache stats.
+func NewBreaker(name string, next Backend, cfg BreakerConfig, logger *zap.SugaredLogger) *Breaker {
+	m := new(expvar.Map).Init()
+	stats.Set(name, m)
+
+	b := &Breaker{
+		next:            next,
+		cfg:             cfg,
+		logger:          logger,
+		stats:           m,
+		pendingKeys:     make(map[string]struct{}),
+		pendingPrefixes: make(map[string]struct{}),
+	}
+	b.setState(breakerClosed)
+
+	return b
+}
+
+func (b *Breaker) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := b.do(ctx, func() error {
+		var err error
+		data, err = b.next.Get(ctx, key)
+		return err
+	})
+
+	return data, err
+}
+
+func (b *Breaker) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.do(ctx, func() error {
+		return b.next.Set(ctx, key, value, ttl)
+	})
+}
+
+func (b *Breaker) Delete(ctx context.Context, keys ...string) error {
+	err := b.do(ctx, func() error {
+		return b.next.Delete(ctx, keys...)
+	})
+	if err != nil {
+		b.hold(keys)
+	}
+
+	return err
+}
+
+func (b *Breaker) DeletePrefix(ctx context.Context, prefix string) error {
+	err := b.do(ctx, func() error {
+		return b.next.DeletePrefix(ctx, prefix)
+	})
+	if err != nil {
+		b.hold(nil, prefix)
+	}
+
+	return err
+}
+
+func (b *Breaker) do(ctx context.Context, fn func() error) error {
+	if !b.allow() {
+		b.stats.Add("skipped", 1)
+		return ErrBreakerOpen
+	}
+
+	// Replay held deletes first, so this call cannot read an entry they
+	// should have removed.
+	if err := b.flush(); err != nil {
+		b.fail(err)
+		return err
+	}
+
+	err := fn()
+
+	switch {
+	case err == nil || errors.Is(err, ErrMiss):
+		b.record(nil)
+	case ctx.Err() != nil:
+		// A cancelled request says nothing about the backend's health.
+		b.release()
+	default:
+		b.fail(err)
+	}
+
+	return err
+}
+
+func (b *Breaker) fail(err error) {
+	b.stats.Add("failures", 1)
+	b.logger.Warnw("cache backend error", "error", err)
+	b.record(err)
+}
+
+// hold keeps deletes that did not reach the backend for flush to replay.
+func (b *Breaker) hold(keys []string, prefixes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		if len(b.pendingKeys) < maxPending {
+			b.pendingKeys[key] = struct{}{}
+			continue
+		}
+
+		// Cache keys are "name:id", so this drops every entry in the cache.
+		if i := strings.IndexByte(key, ':'); i >= 0 {
+			key = key[:i+1]
+		}
+		b.pendingPrefixes[key] = struct{}{}
+	}
+
+	for _, prefix := range prefixes {
+		b.pendingPrefixes[prefix] = struct{}{}
+	}
+}
+
+// flush replays held deletes, holding them again if the backend fails.
+func (b *Breaker) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	if len(b.pendingKeys) == 0 && len(b.pendingPrefixes) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+
+	keys := make([]string, 0, len(b.pendingKeys))
+	for key := range b.pendingKeys {
+		keys = append(keys, key)
+	}
+	prefixes := make([]string, 0, len(b.pendingPrefixes))
+	for prefix := range b.pendingPrefixes {
+		prefixes = append(prefixes, prefix)
+	}
+
+	b.pendingKeys = make(map[string]struct{})
+	b.pendingPrefixes = make(map[string]struct{})
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	var err error
+	if len(keys) > 0 {
+		err = b.next.Delete(ctx, keys...)
+	}
+	for _, prefix := range prefixes {
+		if err != nil {
+			break
+		}
+		err = b.next.DeletePrefix(ctx, prefix)
+	}
+
+	if err != nil {
+		b.hold(keys, prefixes...)
+		return err
+	}
+
+	b.stats.Add("replayed", int64(len(keys)+len(prefixes)))
+	return nil
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cfg.Cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		// One probe at a time.
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// release gives up a probe slot without a verdict, leaving the next call to
+// probe instead.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.logger.Infow("cache backend recovered, closing circuit breaker")
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.cfg.Threshold {
+		if b.state != breakerOpen {
+			b.logger.Errorw("cache backend failing, opening circuit breaker", "failures", b.failures, "cooldown", b.cfg.Cooldown)
+			b.stats.Add("opened", 1)
+		}
+		b.setState(breakerOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *Breaker) setState(state string) {
+	b.state = state
+
+	s := new(expvar.String)
+	s.Set(state)
+	b.stats.Set("state", s)
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMiss is returned by a Backend when a key is not cached.
+var ErrMiss = errors.New("cache: miss")
+
+// loadTimeout bounds a shared load, which runs detached from the context of
+// the caller that started it.
+const loadTimeout = 10 * time.Second
+
+// stats is published at /debug/vars as "cache", with one map of hit, miss
+// and error counters per named cache.
+var stats = expvar.NewMap("cache")
+
+// Backend stores encoded cache entries.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Codec encodes the values held in a cache.
+type Codec[V any] interface {
+	Marshal(v V) ([]byte, error)
+	Unmarshal(data []byte, v *V) error
+}
+
+type jsonCodec[V any] struct{}
+
+func (jsonCodec[V]) Marshal(v V) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec[V]) Unmarshal(data []byte, v *V) error { return json.Unmarshal(data, v) }
+
+// Cache is a read-through cache of values of type V keyed by K. Concurrent
+// misses for the same key share a single load, and every caller gets its own
+// decoded copy of the value, so callers are free to modify what they get
+// back.
+type Cache[K comparable, V any] struct {
+	name    string
+	backend Backend
+	ttl     time.Duration
+	codec   Codec[V]
+	group   singleflight.Group
+	stats   *expvar.Map
+}
+
+// New returns a cache whose keys are prefixed with name, which also labels
+// its counters. Values are encoded as JSON.
+func New[K comparable, V any](name string, backend Backend, ttl time.Duration) *Cache[K, V] {
+	return NewWithCodec[K, V](name, backend, ttl, jsonCodec[V]{})
+}
+
+// NewWithCodec is New for values whose JSON form leaves out fields the cache
+// must keep.
+func NewWithCodec[K comparable, V any](name string, backend Backend, ttl time.Duration, codec Codec[V]) *Cache[K, V] {
+	m := new(expvar.Map).Init()
+	stats.Set(name, m)
+
+	return &Cache[K, V]{
+		name:    name,
+		backend: backend,
+		ttl:     ttl,
+		codec:   codec,
+		stats:   m,
+	}
+}
+
+func (c *Cache[K, V]) key(k K) string {
+	return fmt.Sprintf("%s:%v", c.name, k)
+}
+
+// Get returns the cached value for k, calling load and caching its result on
+// a miss. Errors from load are returned as is and never cached. A failing
+// backend is counted and treated as a miss, so the cache never fails a read
+// that the loader can serve.
+func (c *Cache[K, V]) Get(ctx context.Context, k K, load func(context.Context) (V, error)) (V, error) {
+	var v V
+	key := c.key(k)
+
+	data, err := c.backend.Get(ctx, key)
+	switch {
+	case err == nil:
+		if err := c.codec.Unmarshal(data, &v); err == nil {
+			c.stats.Add("hits", 1)
+			return v, nil
+		}
+		c.stats.Add("errors", 1)
+	case errors.Is(err, ErrMiss), errors.Is(err, ErrBreakerOpen):
+	default:
+		c.stats.Add("errors", 1)
+	}
+
+	c.stats.Add("misses", 1)
+
+	// The load is shared with every caller waiting on key, so it must not be
+	// cut short when the caller that happened to start it goes away. Each
+	// caller still stops waiting when its own context is done.
+	ch := c.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		loaded, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := c.codec.Marshal(loaded)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.backend.Set(ctx, key, data, c.ttl); err != nil && !errors.Is(err, ErrBreakerOpen) {
+			c.stats.Add("errors", 1)
+		}
+
+		return data, nil
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return v, ctx.Err()
+	}
+
+	if res.Err != nil {
+		return v, res.Err
+	}
+
+	if err := c.codec.Unmarshal(res.Val.([]byte), &v); err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+// Invalidate drops the cached values for keys. A load already in flight may
+// still write its result back, which the TTL bounds.
+func (c *Cache[K, V]) Invalidate(ctx context.Context, keys ...K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	cacheKeys := make([]string, len(keys))
+	for i, k := range keys {
+		cacheKeys[i] = c.key(k)
+		c.group.Forget(cacheKeys[i])
+	}
+
+	return c.backend.Delete(ctx, cacheKeys...)
+}
+
+// Purge drops every value in the cache, for changes that touch more entries
+// than can be named.
+func (c *Cache[K, V]) Purge(ctx context.Context) error {
+	return c.backend.DeletePrefix(ctx, c.name+":")
+}
+
+type nopBackend struct{}
+
+// NopBackend caches nothing. Loads still go through singleflight.
+var NopBackend Backend = nopBackend{}
+
+func (nopBackend) Get(context.Context, string) ([]byte, error) { return nil, ErrMiss }
+
+func (nopBackend) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (nopBackend) Delete(context.Context, ...string) error { return nil }
+
+func (nopBackend) DeletePrefix(context.Context, string) error { return nil }
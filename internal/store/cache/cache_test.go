@@ -0,0 +1,485 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+type mapBackend struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{data: map[string][]byte{}}
+}
+
+func (b *mapBackend) Get(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, ok := b.data[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	return data, nil
+}
+
+func (b *mapBackend) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data[key] = value
+	return nil
+}
+
+func (b *mapBackend) Delete(_ context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		delete(b.data, key)
+	}
+	return nil
+}
+
+func (b *mapBackend) DeletePrefix(_ context.Context, prefix string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key := range b.data {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.data, key)
+		}
+	}
+	return nil
+}
+
+type item struct {
+	Name string
+}
+
+func counter(c *Cache[int64, *item], name string) int64 {
+	v := c.stats.Get(name)
+	if v == nil {
+		return 0
+	}
+	return v.(interface{ Value() int64 }).Value()
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	ctx := context.Background()
+	c := New[int64, *item]("test-read-through", newMapBackend(), time.Minute)
+
+	var loads int
+	load := func(context.Context) (*item, error) {
+		loads++
+		return &item{Name: "first"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := c.Get(ctx, 1, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Name != "first" {
+			t.Fatalf("got %q, want first", v.Name)
+		}
+		v.Name = "changed by caller"
+	}
+
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+	if hits, misses := counter(c, "hits"), counter(c, "misses"); hits != 2 || misses != 1 {
+		t.Errorf("got %d hits and %d misses, want 2 and 1", hits, misses)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := New[int64, *item]("test-invalidate", newMapBackend(), time.Minute)
+
+	name := "before"
+	load := func(context.Context) (*item, error) {
+		return &item{Name: name}, nil
+	}
+
+	if _, err := c.Get(ctx, 1, load); err != nil {
+		t.Fatal(err)
+	}
+
+	name = "after"
+	if err := c.Invalidate(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.Get(ctx, 1, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "after" {
+		t.Errorf("got %q after invalidation, want after", v.Name)
+	}
+
+	name = "purged"
+	if err := c.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := c.Get(ctx, 1, load); v.Name != "purged" {
+		t.Errorf("got %q after purge, want purged", v.Name)
+	}
+}
+
+func TestCacheDoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	c := New[int64, *item]("test-errors", newMapBackend(), time.Minute)
+
+	errLoad := errors.New("boom")
+	if _, err := c.Get(ctx, 1, func(context.Context) (*item, error) { return nil, errLoad }); !errors.Is(err, errLoad) {
+		t.Fatalf("got %v, want %v", err, errLoad)
+	}
+
+	v, err := c.Get(ctx, 1, func(context.Context) (*item, error) { return &item{Name: "ok"}, nil })
+	if err != nil || v.Name != "ok" {
+		t.Fatalf("got %v, %v after a failed load", v, err)
+	}
+}
+
+func TestCacheSharesConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+	c := New[int64, *item]("test-singleflight", NopBackend, time.Minute)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (*item, error) {
+		loads.Add(1)
+		<-release
+		return &item{Name: "shared"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*item, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.Get(ctx, 1, load)
+		}(i)
+	}
+
+	// Give the callers time to pile up behind the first load.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v == nil || v.Name != "shared" {
+			t.Fatalf("caller %d got %v", i, v)
+		}
+		if i > 0 && v == results[0] {
+			t.Fatalf("caller %d shares a pointer with caller 0", i)
+		}
+	}
+}
+
+func TestCacheLoadOutlivesCancelledCaller(t *testing.T) {
+	c := New[int64, *item]("test-singleflight-cancel", NopBackend, time.Minute)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*item, error) {
+		close(started)
+		select {
+		case <-release:
+			return &item{Name: "shared"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(first, 1, load)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan *item, 1)
+	go func() {
+		v, _ := c.Get(context.Background(), 1, load)
+		second <- v
+	}()
+
+	// Give the second caller time to join the first load, then walk away
+	// from it.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if v := <-second; v == nil || v.Name != "shared" {
+		t.Fatalf("waiting caller got %v", v)
+	}
+}
+
+func TestLRUBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	b := NewLRUBackend(2)
+
+	b.Set(ctx, "a", []byte("1"), time.Minute)
+	b.Set(ctx, "b", []byte("2"), time.Minute)
+
+	// Touch a so that b is the least recently used.
+	if _, err := b.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	b.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, err := b.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+		t.Errorf("b: got %v, want a miss", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := b.Get(ctx, key); err != nil {
+			t.Errorf("%s: got %v, want a hit", key, err)
+		}
+	}
+	if n := b.Len(); n != 2 {
+		t.Errorf("holding %d entries, want 2", n)
+	}
+}
+
+func TestLRUBackendExpires(t *testing.T) {
+	ctx := context.Background()
+	b := NewLRUBackend(10)
+
+	b.Set(ctx, "a", []byte("1"), -time.Second)
+	if _, err := b.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("got %v, want a miss for an expired entry", err)
+	}
+}
+
+func TestLRUBackendWithoutRoom(t *testing.T) {
+	ctx := context.Background()
+
+	for _, size := range []int{0, -1} {
+		b := NewLRUBackend(size)
+		if err := b.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if _, err := b.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+			t.Errorf("size %d: got %v, want a miss", size, err)
+		}
+	}
+}
+
+func TestTieredBackend(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := NewLRUBackend(10), newMapBackend()
+	b := NewTieredBackend(l1, l2, time.Minute)
+
+	l2.Set(ctx, "users:1", []byte("from l2"), time.Minute)
+
+	data, err := b.Get(ctx, "users:1")
+	if err != nil || string(data) != "from l2" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if _, err := l1.Get(ctx, "users:1"); err != nil {
+		t.Errorf("L2 hit was not copied into L1: %v", err)
+	}
+
+	if err := b.Delete(ctx, "users:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get(ctx, "users:1"); !errors.Is(err, ErrMiss) {
+		t.Errorf("got %v after delete, want a miss", err)
+	}
+}
+
+type flakyBackend struct {
+	*mapBackend
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+var errDown = errors.New("connection refused")
+
+func (b *flakyBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	b.calls.Add(1)
+	if b.down.Load() {
+		return nil, errDown
+	}
+	return b.mapBackend.Get(ctx, key)
+}
+
+func (b *flakyBackend) Delete(ctx context.Context, keys ...string) error {
+	b.calls.Add(1)
+	if b.down.Load() {
+		return errDown
+	}
+	return b.mapBackend.Delete(ctx, keys...)
+}
+
+// openBreaker returns a breaker over flaky that has just opened.
+func openBreaker(t *testing.T, name string, flaky *flakyBackend) *Breaker {
+	t.Helper()
+
+	flaky.down.Store(true)
+	b := NewBreaker(name, flaky, BreakerConfig{Threshold: 2, Cooldown: 20 * time.Millisecond}, zap.NewNop().Sugar())
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.Get(context.Background(), "k"); !errors.Is(err, errDown) {
+			t.Fatalf("call %d: got %v, want the backend error", i, err)
+		}
+	}
+	return b
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyBackend{mapBackend: newMapBackend()}
+	flaky.down.Store(true)
+
+	b := NewBreaker("test-breaker", flaky, BreakerConfig{Threshold: 3, Cooldown: 20 * time.Millisecond}, zap.NewNop().Sugar())
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Get(ctx, "k"); !errors.Is(err, errDown) {
+			t.Fatalf("call %d: got %v, want the backend error", i, err)
+		}
+	}
+
+	if _, err := b.Get(ctx, "k"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("got %v, want the breaker to be open", err)
+	}
+	if n := flaky.calls.Load(); n != 3 {
+		t.Errorf("backend called %d times, want 3", n)
+	}
+
+	// After the cooldown a single probe goes through and, on success,
+	// closes the breaker.
+	time.Sleep(30 * time.Millisecond)
+	flaky.down.Store(false)
+
+	if _, err := b.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("probe: got %v, want a miss", err)
+	}
+	if _, err := b.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("got %v, want the breaker to be closed", err)
+	}
+}
+
+func TestBreakerIgnoresCancelledProbe(t *testing.T) {
+	flaky := &flakyBackend{mapBackend: newMapBackend()}
+	b := openBreaker(t, "test-breaker-cancelled", flaky)
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Get(ctx, "k"); !errors.Is(err, errDown) {
+		t.Fatalf("cancelled probe: got %v, want the backend error", err)
+	}
+
+	// The cancelled probe neither closed the breaker nor kept the slot, so
+	// the next call probes and, failing, reopens it.
+	if _, err := b.Get(context.Background(), "k"); !errors.Is(err, errDown) {
+		t.Fatalf("probe: got %v, want the backend error", err)
+	}
+	if _, err := b.Get(context.Background(), "k"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("got %v, want the breaker to be open", err)
+	}
+}
+
+func TestBreakerReplaysDeletes(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyBackend{mapBackend: newMapBackend()}
+	flaky.mapBackend.Set(ctx, "posts:1", []byte("stale"), time.Minute)
+	flaky.mapBackend.Set(ctx, "posts:2", []byte("fresh"), time.Minute)
+
+	b := openBreaker(t, "test-breaker-replay", flaky)
+	if err := b.Delete(ctx, "posts:1"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("got %v, want the delete to be skipped", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	flaky.down.Store(false)
+
+	// The probe replays the skipped delete before anything reads it.
+	if _, err := b.Get(ctx, "posts:2"); err != nil {
+		t.Fatalf("probe: got %v, want a hit", err)
+	}
+	if _, err := flaky.mapBackend.Get(ctx, "posts:1"); !errors.Is(err, ErrMiss) {
+		t.Errorf("got %v, want the skipped delete to be replayed", err)
+	}
+}
+
+func TestBreakerDoesNotServeFailedDeletes(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyBackend{mapBackend: newMapBackend()}
+	flaky.mapBackend.Set(ctx, "users:1", []byte("stale"), time.Minute)
+
+	b := NewBreaker("test-breaker-failed-delete", flaky, BreakerConfig{Threshold: 5, Cooldown: time.Minute}, zap.NewNop().Sugar())
+
+	flaky.down.Store(true)
+	if err := b.Delete(ctx, "users:1"); !errors.Is(err, errDown) {
+		t.Fatalf("got %v, want the delete to fail", err)
+	}
+	flaky.down.Store(false)
+
+	// The breaker never opened, but the failed delete must still land
+	// before the key is read again.
+	if data, err := b.Get(ctx, "users:1"); !errors.Is(err, ErrMiss) {
+		t.Errorf("got %q, %v, want a miss", data, err)
+	}
+}
+
+func TestCacheServesLoadsWhenBackendFails(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyBackend{mapBackend: newMapBackend()}
+	flaky.down.Store(true)
+
+	c := New[int64, *item]("test-backend-down", flaky, time.Minute)
+
+	v, err := c.Get(ctx, 1, func(context.Context) (*item, error) { return &item{Name: "db"}, nil })
+	if err != nil || v.Name != "db" {
+		t.Fatalf("got %v, %v, want the loaded value", v, err)
+	}
+	if n := counter(c, "errors"); n != 1 {
+		t.Errorf("counted %d errors, want 1", n)
+	}
+}
+
+func TestUserCacheKeepsSuspension(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(newMapBackend(), Config{UserTTL: time.Minute})
+
+	load := func(context.Context) (*store.User, error) {
+		return &store.User{ID: 1, Username: "suspended", Suspension: &store.Suspension{UserID: 1, Reason: "spam"}}, nil
+	}
+
+	// The first read loads, the second decodes the cached copy.
+	for i := 0; i < 2; i++ {
+		user, err := s.Users.Get(ctx, 1, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.IsSuspended() || user.Suspension.Reason != "spam" {
+			t.Fatalf("read %d: suspension = %+v", i, user.Suspension)
+		}
+	}
+}
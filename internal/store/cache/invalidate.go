@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"go.uber.org/zap"
+)
+
+// Invalidating wraps the store writes that change cached users, posts and
+// comment lists so that each successful write drops the entries it made
+// stale. Invalidation failures are logged and never fail the write; the
+// entry's TTL bounds how stale it can get.
+func Invalidating(s store.Storage, c Storage, logger *zap.SugaredLogger) store.Storage {
+	inv := &invalidator{cache: c, logger: logger}
+
+	s.Users = &invalidatingUsers{UserStorage: s.Users, inv: inv}
+	s.Posts = &invalidatingPosts{PostStorage: s.Posts, inv: inv}
+	s.Comments = &invalidatingComments{CommentStorage: s.Comments, inv: inv}
+	s.Suspensions = &invalidatingSuspensions{SuspensionStorage: s.Suspensions, inv: inv}
+	s.Reports = &invalidatingReports{ReportStorage: s.Reports, inv: inv}
+
+	return s
+}
+
+type invalidator struct {
+	cache  Storage
+	logger *zap.SugaredLogger
+}
+
+func (i *invalidator) user(ctx context.Context, userID int64) {
+	if err := i.cache.Users.Invalidate(ctx, userID); err != nil {
+		i.logger.Errorw("error invalidating cached user", "id", userID, "error", err)
+	}
+}
+
+// post drops a cached post and its comment list.
+func (i *invalidator) post(ctx context.Context, postID int64) {
+	if err := i.cache.Posts.Invalidate(ctx, postID); err != nil {
+		i.logger.Errorw("error invalidating cached post", "id", postID, "error", err)
+	}
+
+	i.postComments(ctx, postID)
+}
+
+func (i *invalidator) postComments(ctx context.Context, postID int64) {
+	if err := i.cache.PostComments.Invalidate(ctx, postID); err != nil {
+		i.logger.Errorw("error invalidating cached comments", "post_id", postID, "error", err)
+	}
+}
+
+// allPostComments drops every cached comment list, for writes such as a
+// suspension that hide one user's comments across many posts.
+func (i *invalidator) allPostComments(ctx context.Context) {
+	if err := i.cache.PostComments.Purge(ctx); err != nil {
+		i.logger.Errorw("error purging cached comments", "error", err)
+	}
+}
+
+type invalidatingUsers struct {
+	store.UserStorage
+	inv *invalidator
+}
+
+func (s *invalidatingUsers) Activate(ctx context.Context, token string) (int64, error) {
+	userID, err := s.UserStorage.Activate(ctx, token)
+	if err == nil {
+		s.inv.user(ctx, userID)
+	}
+	return userID, err
+}
+
+func (s *invalidatingUsers) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	err := s.UserStorage.SetPrivacy(ctx, userID, isPrivate)
+	if err == nil {
+		s.inv.user(ctx, userID)
+	}
+	return err
+}
+
+func (s *invalidatingUsers) Delete(ctx context.Context, userID int64) error {
+	err := s.UserStorage.Delete(ctx, userID)
+	if err == nil {
+		s.inv.user(ctx, userID)
+	}
+	return err
+}
+
+type invalidatingPosts struct {
+	store.PostStorage
+	inv *invalidator
+}
+
+func (s *invalidatingPosts) UpdateByUser(ctx context.Context, post *store.Post) error {
+	err := s.PostStorage.UpdateByUser(ctx, post)
+	if err == nil {
+		s.inv.post(ctx, post.ID)
+	}
+	return err
+}
+
+func (s *invalidatingPosts) DeleteByUser(ctx context.Context, postID int64, userID int64) error {
+	err := s.PostStorage.DeleteByUser(ctx, postID, userID)
+	if err == nil {
+		s.inv.post(ctx, postID)
+	}
+	return err
+}
+
+type invalidatingComments struct {
+	store.CommentStorage
+	inv *invalidator
+}
+
+func (s *invalidatingComments) Create(ctx context.Context, comment *store.Comment) error {
+	err := s.CommentStorage.Create(ctx, comment)
+	if err == nil {
+		s.inv.postComments(ctx, comment.PostID)
+	}
+	return err
+}
+
+func (s *invalidatingComments) Update(ctx context.Context, comment *store.Comment) error {
+	err := s.CommentStorage.Update(ctx, comment)
+	if err == nil {
+		s.inv.postComments(ctx, comment.PostID)
+	}
+	return err
+}
+
+type invalidatingSuspensions struct {
+	store.SuspensionStorage
+	inv *invalidator
+}
+
+func (s *invalidatingSuspensions) Suspend(ctx context.Context, suspension *store.Suspension) error {
+	err := s.SuspensionStorage.Suspend(ctx, suspension)
+	if err == nil {
+		s.inv.user(ctx, suspension.UserID)
+		s.inv.allPostComments(ctx)
+	}
+	return err
+}
+
+func (s *invalidatingSuspensions) Lift(ctx context.Context, userID int64, liftedBy int64) error {
+	err := s.SuspensionStorage.Lift(ctx, userID, liftedBy)
+	if err == nil {
+		s.inv.user(ctx, userID)
+		s.inv.allPostComments(ctx)
+	}
+	return err
+}
+
+type invalidatingReports struct {
+	store.ReportStorage
+	inv *invalidator
+}
+
+// Resolve drops whatever the moderation action changed: the suspended user,
+// or the hidden post or comment. A hidden comment's post is not known here,
+// so every comment list goes.
+func (s *invalidatingReports) Resolve(ctx context.Context, report *store.Report, action *store.ModerationAction) error {
+	if err := s.ReportStorage.Resolve(ctx, report, action); err != nil {
+		return err
+	}
+
+	if action.Suspension != nil {
+		s.inv.user(ctx, action.Suspension.UserID)
+		s.inv.allPostComments(ctx)
+	}
+
+	if action.Action == store.ModerationActionHideContent {
+		switch report.TargetType {
+		case store.ReportTargetPost:
+			s.inv.post(ctx, report.TargetID)
+		case store.ReportTargetComment:
+			s.inv.allPostComments(ctx)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LRUBackend keeps up to size entries in process memory, evicting the least
+// recently used entry when full. Entries also expire after their TTL. A size
+// of zero or less holds nothing, so every Get is a miss.
+type LRUBackend struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewLRUBackend(size int) *LRUBackend {
+	return &LRUBackend{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (b *LRUBackend) Get(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	el, ok := b.items[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		b.remove(el)
+		return nil, ErrMiss
+	}
+
+	b.order.MoveToFront(el)
+	return entry.value, nil
+}
+
+func (b *LRUBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if b.size <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+
+	if el, ok := b.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		b.order.MoveToFront(el)
+		return nil
+	}
+
+	b.items[key] = b.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for b.order.Len() > b.size {
+		b.remove(b.order.Back())
+	}
+
+	return nil
+}
+
+func (b *LRUBackend) Delete(_ context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := b.items[key]; ok {
+			b.remove(el)
+		}
+	}
+
+	return nil
+}
+
+func (b *LRUBackend) DeletePrefix(_ context.Context, prefix string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, el := range b.items {
+		if strings.HasPrefix(key, prefix) {
+			b.remove(el)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones not yet
+// evicted.
+func (b *LRUBackend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.order.Len()
+}
+
+func (b *LRUBackend) remove(el *list.Element) {
+	b.order.Remove(el)
+	delete(b.items, el.Value.(*lruEntry).key)
+}
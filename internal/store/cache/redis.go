@@ -1,6 +1,12 @@
 package cache
 
-import "github.com/redis/go-redis/v9"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
 
 func NewRedisClient(addr, pw string, db int) *redis.Client {
 	return redis.NewClient(&redis.Options{
@@ -9,3 +15,53 @@ func NewRedisClient(addr, pw string, db int) *redis.Client {
 		DB:       db,
 	})
 }
+
+// RedisBackend stores cache entries in Redis with a TTL.
+type RedisBackend struct {
+	rdb *redis.Client
+}
+
+func NewRedisBackend(rdb *redis.Client) *RedisBackend {
+	return &RedisBackend{rdb: rdb}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := b.rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+
+	return data, err
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.rdb.SetEx(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, keys ...string) error {
+	return b.rdb.Del(ctx, keys...).Err()
+}
+
+// DeletePrefix scans for keys under prefix and deletes them a batch at a
+// time.
+func (b *RedisBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	var cursor uint64
+
+	for {
+		keys, next, err := b.rdb.Scan(ctx, cursor, prefix+"*", 500).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if err := b.rdb.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -1,23 +1,134 @@
 package cache
 
 import (
-	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/devphaseX/mingle.git/internal/store"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
+type Config struct {
+	UserTTL     time.Duration
+	PostTTL     time.Duration
+	CommentsTTL time.Duration
+
+	// LocalSize bounds the in-process LRU used when Redis is off and as the
+	// L1 for users when LocalUserTTL is set.
+	LocalSize int
+	// LocalUserTTL, when positive, puts an in-process L1 in front of Redis
+	// for user lookups, which run on every authenticated request.
+	LocalUserTTL time.Duration
+
+	// Breaker guards the Redis backend.
+	Breaker BreakerConfig
+}
+
 type Storage struct {
-	Users interface {
-		Get(context.Context, int64) (*store.User, error)
-		Set(context.Context, *store.User) error
+	Users        *Cache[int64, *store.User]
+	Posts        *Cache[int64, *store.Post]
+	PostComments *Cache[int64, []*store.Comment]
+}
+
+// NewStorage returns caches that all use backend.
+func NewStorage(backend Backend, cfg Config) Storage {
+	return newStorage(backend, backend, cfg)
+}
+
+// NewRedisStorage returns caches backed by Redis behind a circuit breaker,
+// with a local L1 for users when cfg.LocalUserTTL is set.
+func NewRedisStorage(rdb *redis.Client, cfg Config, logger *zap.SugaredLogger) Storage {
+	var backend Backend = NewBreaker("redis-breaker", NewRedisBackend(rdb), cfg.Breaker, logger)
+
+	var users Backend = backend
+	if cfg.LocalUserTTL > 0 {
+		users = NewTieredBackend(NewLRUBackend(cfg.LocalSize), backend, cfg.LocalUserTTL)
 	}
+
+	return newStorage(users, backend, cfg)
 }
 
-func NewRedisStorage(rdb *redis.Client) Storage {
+// NewLocalStorage returns caches held in a single in-process LRU, for when
+// Redis is off.
+func NewLocalStorage(cfg Config) Storage {
+	return NewStorage(NewLRUBackend(cfg.LocalSize), cfg)
+}
+
+func newStorage(users, backend Backend, cfg Config) Storage {
 	return Storage{
-		Users: &UserStore{
-			rdb: rdb,
-		},
+		Users:        NewWithCodec[int64, *store.User]("users", users, cfg.UserTTL, userCodec{}),
+		Posts:        New[int64, *store.Post]("posts", backend, cfg.PostTTL),
+		PostComments: NewWithCodec[int64, []*store.Comment]("post-comments", backend, cfg.CommentsTTL, commentsCodec{}),
+	}
+}
+
+// userCodec keeps a user's suspension, which User leaves out of its JSON but
+// every authenticated request checks on the cached user.
+type userCodec struct{}
+
+type cachedUser struct {
+	*store.User
+	Suspension *store.Suspension `json:"suspension,omitempty"`
+}
+
+func (userCodec) Marshal(u *store.User) ([]byte, error) {
+	if u == nil {
+		return json.Marshal(nil)
+	}
+	return json.Marshal(cachedUser{User: u, Suspension: u.Suspension})
+}
+
+func (userCodec) Unmarshal(data []byte, u **store.User) error {
+	var c cachedUser
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	if c.User != nil {
+		c.User.Suspension = c.Suspension
+	}
+	*u = c.User
+	return nil
+}
+
+// commentsCodec keeps the suspensions of comment authors, which readers of a
+// cached comment list check so that it needs no invalidating when a
+// suspension runs out.
+type commentsCodec struct{}
+
+type cachedComment struct {
+	*store.Comment
+	Suspension *store.Suspension `json:"suspension,omitempty"`
+}
+
+func (commentsCodec) Marshal(comments []*store.Comment) ([]byte, error) {
+	if comments == nil {
+		return json.Marshal(nil)
+	}
+
+	cached := make([]cachedComment, len(comments))
+	for i, comment := range comments {
+		cached[i] = cachedComment{Comment: comment, Suspension: comment.User.Suspension}
+	}
+	return json.Marshal(cached)
+}
+
+func (commentsCodec) Unmarshal(data []byte, comments *[]*store.Comment) error {
+	var cached []cachedComment
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return err
+	}
+
+	if cached == nil {
+		*comments = nil
+		return nil
+	}
+
+	*comments = make([]*store.Comment, len(cached))
+	for i, c := range cached {
+		c.Comment.User.Suspension = c.Suspension
+		(*comments)[i] = c.Comment
 	}
+	return nil
 }
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TieredBackend puts a local L1 in front of a shared L2 such as Redis. Hits
+// in L2 are copied into L1 for at most l1TTL. Invalidation only reaches this
+// process's L1, so other instances may serve a stale entry until their copy
+// expires; keep l1TTL short.
+type TieredBackend struct {
+	l1    Backend
+	l2    Backend
+	l1TTL time.Duration
+}
+
+func NewTieredBackend(l1, l2 Backend, l1TTL time.Duration) *TieredBackend {
+	return &TieredBackend{l1: l1, l2: l2, l1TTL: l1TTL}
+}
+
+func (b *TieredBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	if data, err := b.l1.Get(ctx, key); err == nil {
+		return data, nil
+	}
+
+	data, err := b.l2.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = b.l1.Set(ctx, key, data, b.l1TTL)
+	return data, nil
+}
+
+func (b *TieredBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_ = b.l1.Set(ctx, key, value, min(ttl, b.l1TTL))
+	return b.l2.Set(ctx, key, value, ttl)
+}
+
+func (b *TieredBackend) Delete(ctx context.Context, keys ...string) error {
+	return errors.Join(b.l1.Delete(ctx, keys...), b.l2.Delete(ctx, keys...))
+}
+
+func (b *TieredBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	return errors.Join(b.l1.DeletePrefix(ctx, prefix), b.l2.DeletePrefix(ctx, prefix))
+}
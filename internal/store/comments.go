@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -10,6 +13,7 @@ type Comment struct {
 	ID        int64     `json:"id"`
 	UserID    int64     `json:"user_id"`
 	PostID    int64     `json:"post_id"`
+	ParentID  *int64    `json:"parent_id,omitempty"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	User      User      `json:"user"`
@@ -19,14 +23,19 @@ type CommentStore struct {
 	db *sql.DB
 }
 
+// GetByPostID returns a post's visible comments, newest first. Comments by
+// suspended authors are included along with the author's suspension, so that
+// callers filter them out when they read the list: a cached list then stays
+// right once a suspension runs out.
 func (c *CommentStore) GetByPostID(ctx context.Context, postId int64) ([]*Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id,
+	query := fmt.Sprintf(`SELECT c.id, c.post_id, c.parent_id, c.user_id,
 			c.content, c.created_at,users.first_name, users.last_name,
-		    users.username, users.id FROM comments c
+		    users.username, users.id, %s FROM comments c
 			JOIN users on users.id = c.user_id
-			WHERE c.post_id = $1
+			%s
+			WHERE c.post_id = $1 AND c.hidden_at IS NULL
 			ORDER by c.created_at DESC
-	`
+	`, activeSuspensionColumns, activeSuspensionJoin)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -39,13 +48,76 @@ func (c *CommentStore) GetByPostID(ctx context.Context, postId int64) ([]*Commen
 	defer rows.Close()
 	comments := []*Comment{}
 
+	for rows.Next() {
+		var (
+			comment    Comment
+			suspension nullSuspension
+		)
+
+		user := &comment.User
+		dest := []any{
+			&comment.ID,
+			&comment.PostID,
+			&comment.ParentID,
+			&comment.UserID,
+			&comment.Content,
+			&comment.CreatedAt,
+			&user.FirstName,
+			&user.LastName,
+			&user.Username,
+			&user.ID,
+		}
+
+		if err := rows.Scan(append(dest, suspension.dest()...)...); err != nil {
+			return nil, err
+		}
+
+		user.Suspension = suspension.suspension(user.ID)
+		comments = append(comments, &comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// GetPostComments returns one page of a post's comments, in page or cursor
+// mode.
+func (c *CommentStore) GetPostComments(ctx context.Context, postId int64, paginateQuery PaginateQueryFilter) ([]*Comment, Metadata, error) {
+	keyset, keysetArgs := paginateQuery.KeysetCondition("c.created_at", "c.id", 4)
+
+	query := fmt.Sprintf(`SELECT count(*) OVER(), c.id, c.post_id, c.parent_id, c.user_id,
+			c.content, c.created_at, users.first_name, users.last_name,
+		    users.username, users.id FROM comments c
+			JOIN users on users.id = c.user_id
+			WHERE c.post_id = $1 AND c.hidden_at IS NULL AND %s AND %s
+			ORDER BY %s
+			LIMIT $2 OFFSET $3
+	`, notSuspended("c.user_id"), keyset, paginateQuery.KeysetOrderBy("c.created_at", "c.id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{postId, paginateQuery.Limit(), paginateQuery.Offset()}, keysetArgs...)
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		comments     = []*Comment{}
+		totalRecords int
+	)
+
 	for rows.Next() {
 		var comment Comment
 
 		user := &comment.User
 		err := rows.Scan(
+			&totalRecords,
 			&comment.ID,
 			&comment.PostID,
+			&comment.ParentID,
 			&comment.UserID,
 			&comment.Content,
 			&comment.CreatedAt,
@@ -54,13 +126,103 @@ func (c *CommentStore) GetByPostID(ctx context.Context, postId int64) ([]*Commen
 			&user.Username,
 			&user.ID,
 		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
 
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if paginateQuery.Cursor != nil {
+		comments, metadata := CursorPage(paginateQuery, comments, func(comment *Comment) (time.Time, string) {
+			return comment.CreatedAt, strconv.FormatInt(comment.ID, 10)
+		})
+		return comments, metadata, nil
+	}
+
+	return comments, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+func (c *CommentStore) Create(ctx context.Context, comment *Comment) error {
+	query := `INSERT INTO comments(post_id, parent_id, user_id, content)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, comment.PostID, comment.ParentID, comment.UserID, comment.Content).
+			Scan(&comment.ID, &comment.CreatedAt)
 		if err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, "comment", comment.ID, EventCommentCreated, newCommentEvent(comment))
+	})
+}
+
+func (c *CommentStore) GetById(ctx context.Context, id int64) (*Comment, error) {
+	query := `SELECT c.id, c.post_id, c.parent_id, c.user_id,
+			c.content, c.created_at, users.first_name, users.last_name,
+		    users.username, users.id FROM comments c
+			JOIN users on users.id = c.user_id
+			WHERE c.id = $1 AND c.hidden_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var comment Comment
+	user := &comment.User
+	err := c.db.QueryRowContext(ctx, query, id).Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.ParentID,
+		&comment.UserID,
+		&comment.Content,
+		&comment.CreatedAt,
+		&user.FirstName,
+		&user.LastName,
+		&user.Username,
+		&user.ID,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
 			return nil, err
 		}
+	}
 
-		comments = append(comments, &comment)
+	return &comment, nil
+}
+
+func (c *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	stmt := `UPDATE comments SET content = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := c.db.ExecContext(ctx, stmt, comment.Content, comment.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
 	}
 
-	return comments, nil
+	return nil
 }
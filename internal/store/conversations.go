@@ -0,0 +1,434 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxConversationMembers caps group conversations, creator included.
+const MaxConversationMembers = 10
+
+type Conversation struct {
+	ID          int64     `json:"id"`
+	IsGroup     bool      `json:"is_group"`
+	Title       string    `json:"title,omitempty"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UnreadCount int       `json:"unread_count"`
+	LastMessage *Message  `json:"last_message,omitempty"`
+	Members     []*User   `json:"members"`
+}
+
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	Sender         *User     `json:"sender,omitempty"`
+}
+
+type ConversationStore struct {
+	db *sql.DB
+}
+
+func directKey(a, b int64) string {
+	if a > b {
+		a, b = b, a
+	}
+
+	return fmt.Sprintf("%d:%d", a, b)
+}
+
+// conversationColumns selects a conversation as seen by the member in cm,
+// along with their unread count and the latest message.
+const conversationColumns = `c.id, c.is_group, c.title, c.created_by, c.created_at, c.updated_at,
+	(SELECT count(*) FROM messages m
+		WHERE m.conversation_id = c.id AND m.id > cm.last_read_message_id AND m.sender_id <> cm.user_id),
+	lm.id, lm.sender_id, lm.content, lm.created_at`
+
+const lastMessageJoin = `LEFT JOIN LATERAL (
+		SELECT id, sender_id, content, created_at FROM messages
+		WHERE messages.conversation_id = c.id
+		ORDER BY id DESC
+		LIMIT 1
+	) lm ON TRUE`
+
+type conversationRow struct {
+	conversation  Conversation
+	lastID        sql.NullInt64
+	lastSenderID  sql.NullInt64
+	lastContent   sql.NullString
+	lastCreatedAt sql.NullTime
+	totalRecords  *int
+}
+
+func (r *conversationRow) dest() []any {
+	c := &r.conversation
+	dest := []any{
+		&c.ID, &c.IsGroup, &c.Title, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt, &c.UnreadCount,
+		&r.lastID, &r.lastSenderID, &r.lastContent, &r.lastCreatedAt,
+	}
+
+	if r.totalRecords != nil {
+		dest = append([]any{r.totalRecords}, dest...)
+	}
+
+	return dest
+}
+
+func (r *conversationRow) result() *Conversation {
+	c := r.conversation
+	c.Members = []*User{}
+
+	if r.lastID.Valid {
+		c.LastMessage = &Message{
+			ID:             r.lastID.Int64,
+			ConversationID: c.ID,
+			SenderID:       r.lastSenderID.Int64,
+			Content:        r.lastContent.String,
+			CreatedAt:      r.lastCreatedAt.Time,
+		}
+	}
+
+	return &c
+}
+
+// Create starts a conversation between memberIDs, which must include the
+// creator. Every member must be an activated user. A 1:1 conversation is only
+// created once per pair of users; created is false when the existing one is
+// returned instead.
+func (s *ConversationStore) Create(ctx context.Context, conversation *Conversation, memberIDs []int64) (created bool, err error) {
+	var key *string
+	if !conversation.IsGroup {
+		if len(memberIDs) != 2 {
+			return false, fmt.Errorf("direct conversation needs exactly 2 members, got %d", len(memberIDs))
+		}
+		k := directKey(memberIDs[0], memberIDs[1])
+		key = &k
+	}
+
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var active int
+		activeQuery := `SELECT count(*) FROM users WHERE id = ANY($1::bigint[]) AND is_active`
+		if err := tx.QueryRowContext(ctx, activeQuery, pq.Array(memberIDs)).Scan(&active); err != nil {
+			return err
+		}
+
+		if active != len(memberIDs) {
+			return ErrNotFound
+		}
+
+		// The no-op update lets RETURNING hand back an existing direct
+		// conversation, and xmax tells whether the row was just inserted.
+		query := `INSERT INTO conversations(is_group, title, created_by, direct_key)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+			RETURNING id, created_by, created_at, updated_at, (xmax = 0)`
+
+		err := tx.QueryRowContext(ctx, query, conversation.IsGroup, conversation.Title, conversation.CreatedBy, key).Scan(
+			&conversation.ID,
+			&conversation.CreatedBy,
+			&conversation.CreatedAt,
+			&conversation.UpdatedAt,
+			&created,
+		)
+		if err != nil {
+			return err
+		}
+
+		if !created {
+			return nil
+		}
+
+		membersStmt := `INSERT INTO conversation_members(conversation_id, user_id)
+			SELECT $1, unnest($2::bigint[])`
+		_, err = tx.ExecContext(ctx, membersStmt, conversation.ID, pq.Array(memberIDs))
+
+		return err
+	})
+
+	return created, err
+}
+
+// GetForMember returns a conversation as seen by one of its members, or
+// ErrNotFound when the user is not a member.
+func (s *ConversationStore) GetForMember(ctx context.Context, conversationID int64, userID int64) (*Conversation, error) {
+	query := fmt.Sprintf(`SELECT %s
+		FROM conversations c
+		INNER JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = $2
+		%s
+		WHERE c.id = $1`, conversationColumns, lastMessageJoin)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var row conversationRow
+	if err := s.db.QueryRowContext(ctx, query, conversationID, userID).Scan(row.dest()...); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	conversation := row.result()
+	if err := s.attachMembers(ctx, []*Conversation{conversation}); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// GetAllForUser lists a user's conversations with their unread counts.
+func (s *ConversationStore) GetAllForUser(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*Conversation, Metadata, error) {
+	query := fmt.Sprintf(`SELECT %s
+		FROM conversations c
+		INNER JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = $1
+		%s
+		ORDER BY c.%s %s, c.id DESC
+		LIMIT $2 OFFSET $3`,
+		"count(*) OVER(), "+conversationColumns, lastMessageJoin,
+		paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		conversations = []*Conversation{}
+		totalRecords  int
+	)
+
+	for rows.Next() {
+		row := conversationRow{totalRecords: &totalRecords}
+		if err := rows.Scan(row.dest()...); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		conversations = append(conversations, row.result())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if err := s.attachMembers(ctx, conversations); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return conversations, metadata, nil
+}
+
+func (s *ConversationStore) attachMembers(ctx context.Context, conversations []*Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Conversation, len(conversations))
+	ids := make([]int64, 0, len(conversations))
+	for _, c := range conversations {
+		byID[c.ID] = c
+		ids = append(ids, c.ID)
+	}
+
+	query := `SELECT cm.conversation_id, u.id, u.first_name, u.last_name, u.username
+		FROM conversation_members cm
+		INNER JOIN users u ON u.id = cm.user_id
+		WHERE cm.conversation_id = ANY($1::bigint[])
+		ORDER BY cm.joined_at, u.id`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			conversationID int64
+			user           User
+		)
+
+		if err := rows.Scan(&conversationID, &user.ID, &user.FirstName, &user.LastName, &user.Username); err != nil {
+			return err
+		}
+
+		if c, ok := byID[conversationID]; ok {
+			c.Members = append(c.Members, &user)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *ConversationStore) GetMemberIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	query := `SELECT user_id FROM conversation_members WHERE conversation_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// CountUnread returns the number of unread messages across all of a user's
+// conversations.
+func (s *ConversationStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT count(*) FROM conversation_members cm
+		INNER JOIN messages m ON m.conversation_id = cm.conversation_id
+		WHERE cm.user_id = $1 AND m.id > cm.last_read_message_id AND m.sender_id <> cm.user_id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateMessage saves a message, bumps the conversation to the top of its
+// members' lists and marks it read for the sender.
+func (s *ConversationStore) CreateMessage(ctx context.Context, message *Message) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO messages(conversation_id, sender_id, content)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at`
+
+		err := tx.QueryRowContext(ctx, query, message.ConversationID, message.SenderID, message.Content).
+			Scan(&message.ID, &message.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE conversations SET updated_at = $2 WHERE id = $1`,
+			message.ConversationID, message.CreatedAt); err != nil {
+			return err
+		}
+
+		readStmt := `UPDATE conversation_members SET last_read_message_id = $3
+			WHERE conversation_id = $1 AND user_id = $2`
+		_, err = tx.ExecContext(ctx, readStmt, message.ConversationID, message.SenderID, message.ID)
+
+		return err
+	})
+}
+
+func (s *ConversationStore) GetMessages(ctx context.Context, conversationID int64, paginateQuery PaginateQueryFilter) ([]*Message, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), m.id, m.conversation_id, m.sender_id, m.content, m.created_at,
+		u.id, u.first_name, u.last_name, u.username
+		FROM messages m
+		INNER JOIN users u ON u.id = m.sender_id
+		WHERE m.conversation_id = $1
+		ORDER BY m.%s %s, m.id %[2]s
+		LIMIT $2 OFFSET $3`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		messages     = []*Message{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		message := &Message{Sender: &User{}}
+		err := rows.Scan(
+			&totalRecords,
+			&message.ID,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Content,
+			&message.CreatedAt,
+			&message.Sender.ID,
+			&message.Sender.FirstName,
+			&message.Sender.LastName,
+			&message.Sender.Username,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return messages, metadata, nil
+}
+
+// MarkRead marks a member's messages as read up to messageID, or up to the
+// latest message when messageID is 0. The read position never moves
+// backwards; the resulting position is returned.
+func (s *ConversationStore) MarkRead(ctx context.Context, conversationID int64, userID int64, messageID int64) (int64, error) {
+	query := `UPDATE conversation_members cm SET last_read_message_id = GREATEST(
+			cm.last_read_message_id,
+			LEAST(COALESCE(NULLIF($3, 0), latest.id), latest.id)
+		)
+		FROM (SELECT COALESCE(MAX(id), 0) AS id FROM messages WHERE conversation_id = $1) latest
+		WHERE cm.conversation_id = $1 AND cm.user_id = $2
+		RETURNING cm.last_read_message_id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var lastRead int64
+	if err := s.db.QueryRowContext(ctx, query, conversationID, userID, messageID).Scan(&lastRead); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return lastRead, nil
+}
@@ -2,8 +2,12 @@ package store
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/devphaseX/mingle.git/internal/validator"
+	"github.com/lib/pq"
 )
 
 type Filterable interface {
@@ -11,10 +15,12 @@ type Filterable interface {
 }
 
 type GetUserFeedFilter struct {
-	Search *string    `json:"search" validate:"omitempty,min=1"`
-	Tags   []string   `json:"tags" validate:"omitempty"`
-	Since  *time.Time `json:"since" validate:"omitempty"`
-	Until  *time.Time `json:"until" validate:"omitempty,gtfield=Since"`
+	Search      *string    `json:"search" validate:"omitempty,min=1"`
+	Tags        []string   `json:"tags" validate:"omitempty"`
+	Since       *time.Time `json:"since" validate:"omitempty"`
+	Until       *time.Time `json:"until" validate:"omitempty"`
+	Authors     []string   `json:"author" validate:"omitempty,dive,min=1"`
+	HasComments *bool      `json:"has_comments" validate:"omitempty"`
 	Filterable
 }
 
@@ -28,12 +34,14 @@ func (f *GetUserFeedFilter) ParseFilters(r *http.Request) error {
 	}
 
 	if tags := qs.Get("tags"); tags != "" {
-		f.Tags = strings.Split(tags, ",")
+		f.Tags = strings.Split(strings.ToLower(tags), ",")
 	}
 
+	var errs validator.ValidationErrors
+
 	if since := qs.Get("since"); since != "" {
 		if t, err := parseTime(since); err != nil {
-			return err
+			errs.AddFieldError("since", "must be an RFC 3339 timestamp or YYYY-MM-DD HH:MM:SS")
 		} else {
 			f.Since = t
 		}
@@ -42,17 +50,131 @@ func (f *GetUserFeedFilter) ParseFilters(r *http.Request) error {
 
 	if until := qs.Get("until"); until != "" {
 		if t, err := parseTime(until); err != nil {
-			return err
+			errs.AddFieldError("until", "must be an RFC 3339 timestamp or YYYY-MM-DD HH:MM:SS")
 		} else {
 			f.Until = t
 		}
 	}
 
+	// Checked here rather than with gtfield, which fails whenever since is
+	// left out.
+	if f.Since != nil && f.Until != nil && !f.Until.After(*f.Since) {
+		errs.AddFieldError("until", "must be after since")
+	}
+
+	if authors := qs.Get("author"); authors != "" {
+		f.Authors = strings.Split(authors, ",")
+	}
+
+	if hasComments := qs.Get("has_comments"); hasComments != "" {
+		if v, err := strconv.ParseBool(hasComments); err != nil {
+			errs.AddFieldError("has_comments", "must be true or false")
+		} else {
+			f.HasComments = &v
+		}
+	}
+
+	if errs.Error() != "" {
+		return &errs
+	}
+
+	return nil
+}
+
+// sqlConditions returns the filter's WHERE and HAVING conditions for a post
+// query over posts p joined to their authors as users and grouped by post,
+// with arguments bound to $4 through $9.
+func (f *GetUserFeedFilter) sqlConditions() (where string, having string, args []any) {
+	where = `
+		($4::text IS NULL OR p.title ILIKE '%' || $4 || '%') AND
+		($4::text IS NULL OR p.content ILIKE '%' || $4 || '%') AND
+		($5::text[] IS NULL OR p.tags ?| $5::text[]) AND
+		($6::timestamptz IS NULL OR p.created_at >= $6) AND
+		($7::timestamptz IS NULL OR p.created_at <= $7) AND
+		($8::text[] IS NULL OR users.username = ANY($8))`
+
+	having = `($9::boolean IS NULL OR (count(c.id) > 0) = $9)`
+
+	args = []any{f.Search, pq.Array(f.Tags), f.Since, f.Until, pq.Array(f.Authors), f.HasComments}
+	return where, having, args
+}
+
+type GetReportsFilter struct {
+	Status     *string `json:"status" validate:"omitempty,oneof=open claimed resolved"`
+	TargetType *string `json:"target_type" validate:"omitempty,oneof=post comment user"`
+}
+
+// ParseFilters extracts the moderation queue filters from the HTTP request.
+func (f *GetReportsFilter) ParseFilters(r *http.Request) error {
+	qs := r.URL.Query()
+
+	if status := qs.Get("status"); status != "" {
+		f.Status = &status
+	}
+
+	if targetType := qs.Get("target_type"); targetType != "" {
+		f.TargetType = &targetType
+	}
+
 	return nil
 }
 
+type GetNotificationsFilter struct {
+	Unread *bool `json:"unread" validate:"omitempty"`
+}
+
+// ParseFilters extracts the notification filters from the HTTP request.
+func (f *GetNotificationsFilter) ParseFilters(r *http.Request) error {
+	if unread := r.URL.Query().Get("unread"); unread != "" {
+		v, err := strconv.ParseBool(unread)
+		if err != nil {
+			return err
+		}
+		f.Unread = &v
+	}
+
+	return nil
+}
+
+type GetWebhookDeliveriesFilter struct {
+	Status *string `json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+}
+
+// ParseFilters extracts the webhook delivery filters from the HTTP request.
+func (f *GetWebhookDeliveriesFilter) ParseFilters(r *http.Request) error {
+	if status := r.URL.Query().Get("status"); status != "" {
+		f.Status = &status
+	}
+
+	return nil
+}
+
+type SearchFilter struct {
+	Query string   `json:"q" validate:"required,max=200"`
+	Types []string `json:"type" validate:"omitempty,dive,oneof=posts comments users tags"`
+}
+
+// ParseFilters extracts the search query and result types from the HTTP
+// request.
+func (f *SearchFilter) ParseFilters(r *http.Request) error {
+	qs := r.URL.Query()
+
+	f.Query = strings.TrimSpace(qs.Get("q"))
+
+	if types := qs.Get("type"); types != "" {
+		f.Types = strings.Split(types, ",")
+	}
+
+	return nil
+}
+
+// parseTime accepts RFC 3339 timestamps and, for older clients, the
+// YYYY-MM-DD HH:MM:SS layout, which is read as UTC.
 func parseTime(s string) (*time.Time, error) {
-	t, err := time.Parse(time.DateTime, s)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateTime, s)
+	}
 
 	if err != nil {
 		return nil, err
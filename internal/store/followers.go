@@ -26,8 +26,15 @@ func (s *FollowerStore) FollowUser(ctx context.Context, follower *Follower) erro
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, follower.UserID, follower.FollowerID).
-		Scan(&follower.CreatedAt)
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, follower.UserID, follower.FollowerID).
+			Scan(&follower.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, "user", follower.UserID, EventUserFollowed, follower)
+	})
 
 	if err != nil {
 		var pgErr *pq.Error
@@ -67,3 +74,244 @@ func (s *FollowerStore) UnFollowUser(ctx context.Context, followedUserID int64,
 
 	return nil
 }
+
+// GetFollowerIDs returns the IDs of everyone following the user.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *FollowerStore) CountFollowers(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT count(*) FROM followers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+type FollowRequest struct {
+	UserID      int64     `json:"user_id"`
+	RequesterID int64     `json:"requester_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Requester   *User     `json:"requester,omitempty"`
+}
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, userID int64, followerID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+
+	return following, err
+}
+
+func (s *FollowerStore) RequestFollow(ctx context.Context, request *FollowRequest) error {
+	query := `INSERT INTO follow_requests(user_id, requester_id) VALUES ($1, $2) RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, request.UserID, request.RequesterID).
+		Scan(&request.CreatedAt)
+
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) {
+			// SQL State "23505" means unique_violation
+			if pgErr.Code == "23505" {
+				return ErrConflict
+			}
+		}
+		return fmt.Errorf("failed to request follow: %w", err)
+	}
+	return nil
+}
+
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*FollowRequest, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), fr.user_id, fr.requester_id, fr.created_at,
+		u.id, u.first_name, u.last_name, u.username
+		FROM follow_requests fr
+		INNER JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1
+		ORDER BY fr.%s %s
+		LIMIT $2 OFFSET $3`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		requests     = []*FollowRequest{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		request := &FollowRequest{Requester: &User{}}
+		err := rows.Scan(
+			&totalRecords,
+			&request.UserID,
+			&request.RequesterID,
+			&request.CreatedAt,
+			&request.Requester.ID,
+			&request.Requester.FirstName,
+			&request.Requester.LastName,
+			&request.Requester.Username,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return requests, metadata, nil
+}
+
+// AcceptFollowRequest turns a pending follow request into a follow.
+func (s *FollowerStore) AcceptFollowRequest(ctx context.Context, userID int64, requesterID int64) (*Follower, error) {
+	follower := &Follower{
+		UserID:     userID,
+		FollowerID: requesterID,
+	}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, userID, requesterID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO followers(user_id, follower_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, follower_id) DO UPDATE SET created_at = followers.created_at
+		RETURNING created_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, userID, requesterID).Scan(&follower.CreatedAt); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, "user", userID, EventUserFollowed, follower)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return follower, nil
+}
+
+// RejectFollowRequest drops a pending follow request. It is also used by the
+// requester to withdraw a request they no longer want.
+func (s *FollowerStore) RejectFollowRequest(ctx context.Context, userID int64, requesterID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteFollowRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID int64, requesterID int64) error {
+	stmt := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, stmt, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// acceptPendingFollowRequests turns every pending request to follow userID
+// into a follow, recording a followed event for each as AcceptFollowRequest
+// does.
+func acceptPendingFollowRequests(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `WITH accepted AS (
+		DELETE FROM follow_requests WHERE user_id = $1
+		RETURNING user_id, requester_id
+	)
+	INSERT INTO followers(user_id, follower_id)
+	SELECT user_id, requester_id FROM accepted
+	ON CONFLICT (user_id, follower_id) DO NOTHING
+	RETURNING user_id, follower_id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	followers := []*Follower{}
+	for rows.Next() {
+		var follower Follower
+		if err := rows.Scan(&follower.UserID, &follower.FollowerID, &follower.CreatedAt); err != nil {
+			return err
+		}
+
+		followers = append(followers, &follower)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The rows must be closed before the transaction can run anything else.
+	rows.Close()
+
+	for _, follower := range followers {
+		if err := recordEvent(ctx, tx, "user", userID, EventUserFollowed, follower); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	// JobDead marks a job that failed on every attempt, or that no worker
+	// knows how to run. Dead jobs are kept for inspection and are never
+	// picked up again.
+	JobDead = "dead"
+
+	DefaultJobMaxAttempts = 5
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   *string         `json:"last_error,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type JobStore struct {
+	db *sql.DB
+}
+
+// Enqueue adds a pending job. A zero RunAt runs the job as soon as a worker is
+// free and a zero MaxAttempts uses DefaultJobMaxAttempts.
+func (s *JobStore) Enqueue(ctx context.Context, job *Job) error {
+	query := `INSERT INTO jobs(type, payload, max_attempts, run_at)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()))
+		RETURNING id, status, attempts, run_at, created_at, updated_at`
+
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = DefaultJobMaxAttempts
+	}
+
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, job.Type, string(job.Payload), job.MaxAttempts, runAt).Scan(
+		&job.ID,
+		&job.Status,
+		&job.Attempts,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+}
+
+// Claim locks up to limit due jobs for the caller, counting the attempt.
+// Concurrent callers never claim the same job.
+func (s *JobStore) Claim(ctx context.Context, limit int) ([]*Job, error) {
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1,
+			locked_at = NOW(), updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE status = 'pending' AND run_at <= NOW()
+			ORDER BY run_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, status, attempts, max_attempts, last_error,
+			run_at, locked_at, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		var job Job
+		err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Payload,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.LastError,
+			&job.RunAt,
+			&job.LockedAt,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (s *JobStore) Complete(ctx context.Context, jobID int64) error {
+	stmt := `UPDATE jobs SET status = 'completed', locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	return s.finish(ctx, stmt, jobID)
+}
+
+// Retry puts a running job back in the queue to run again at runAt.
+func (s *JobStore) Retry(ctx context.Context, jobID int64, runAt time.Time, lastError string) error {
+	stmt := `UPDATE jobs SET status = 'pending', run_at = $2, last_error = $3,
+			locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	return s.finish(ctx, stmt, jobID, runAt, lastError)
+}
+
+// Bury dead-letters a running job.
+func (s *JobStore) Bury(ctx context.Context, jobID int64, lastError string) error {
+	stmt := `UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	return s.finish(ctx, stmt, jobID, lastError)
+}
+
+func (s *JobStore) finish(ctx context.Context, stmt string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RequeueStale returns jobs that have been running for longer than timeout to
+// the queue, or dead-letters them if they have no attempts left. It recovers
+// jobs whose worker died mid-run and returns how many were recovered.
+func (s *JobStore) RequeueStale(ctx context.Context, timeout time.Duration) (int64, error) {
+	stmt := `UPDATE jobs SET
+			status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			last_error = 'worker stopped before the job finished',
+			run_at = NOW(), locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, timeout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// SetPostMentions replaces the users mentioned in a post and returns the IDs
+// of users that were not mentioned in it before.
+func (s *MentionStore) SetPostMentions(ctx context.Context, postID int64, userIDs []int64) ([]int64, error) {
+	return s.setMentions(ctx, "post_mentions", "post_id", postID, userIDs)
+}
+
+// SetCommentMentions replaces the users mentioned in a comment and returns the
+// IDs of users that were not mentioned in it before.
+func (s *MentionStore) SetCommentMentions(ctx context.Context, commentID int64, userIDs []int64) ([]int64, error) {
+	return s.setMentions(ctx, "comment_mentions", "comment_id", commentID, userIDs)
+}
+
+func (s *MentionStore) setMentions(ctx context.Context, table, column string, id int64, userIDs []int64) ([]int64, error) {
+	added := []int64{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND NOT (user_id = ANY($2::bigint[]))`, table, column)
+		if _, err := tx.ExecContext(ctx, deleteStmt, id, pq.Array(userIDs)); err != nil {
+			return err
+		}
+
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		insertQuery := fmt.Sprintf(`INSERT INTO %s (%s, user_id)
+			SELECT $1, unnest($2::bigint[])
+			ON CONFLICT DO NOTHING
+			RETURNING user_id`, table, column)
+
+		rows, err := tx.QueryContext(ctx, insertQuery, id, pq.Array(userIDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				return err
+			}
+			added = append(added, userID)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
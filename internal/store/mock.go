@@ -9,7 +9,11 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: NewMockUserStore(),
+		Users:    NewMockUserStore(),
+		Posts:    NewMockPostStore(),
+		Comments: NewMockCommentStore(),
+		Mentions: &MockMentionStore{},
+		Roles:    &MockRoleStore{},
 	}
 }
 
@@ -64,14 +68,35 @@ func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (m *MockUserStore) Activate(ctx context.Context, email string) error {
+func (m *MockUserStore) Activate(ctx context.Context, email string) (int64, error) {
 	for _, user := range m.users {
 		if user.Email == email {
 			user.IsActive = true
-			return nil
+			return user.ID, nil
 		}
 	}
-	return errors.New("user not found")
+	return 0, errors.New("user not found")
+}
+
+func (m *MockUserStore) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	user, exists := m.users[userID]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.IsPrivate = isPrivate
+	return nil
+}
+
+func (m *MockUserStore) GetIDsByUsernames(ctx context.Context, usernames []string) ([]int64, error) {
+	ids := []int64{}
+	for _, user := range m.users {
+		for _, username := range usernames {
+			if user.Username == username {
+				ids = append(ids, user.ID)
+			}
+		}
+	}
+	return ids, nil
 }
 
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, invitationExp time.Duration, token string) error {
@@ -95,3 +120,157 @@ func (m *MockUserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, to
 	m.invitations[token] = userId
 	return nil
 }
+
+// MockPostStore keeps posts in memory. Only the single-post reads and writes
+// are implemented; the feed queries return nothing.
+type MockPostStore struct {
+	posts map[int64]*Post
+}
+
+func NewMockPostStore() *MockPostStore {
+	return &MockPostStore{posts: make(map[int64]*Post)}
+}
+
+func (m *MockPostStore) GetById(ctx context.Context, id int64) (*Post, error) {
+	post, exists := m.posts[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *post
+	return &copied, nil
+}
+
+func (m *MockPostStore) GetVisibleById(ctx context.Context, postID int64, viewerID int64) (*Post, error) {
+	return m.GetById(ctx, postID)
+}
+
+func (m *MockPostStore) DeleteByUser(ctx context.Context, postID int64, userID int64) error {
+	if _, exists := m.posts[postID]; !exists {
+		return ErrNotFound
+	}
+	delete(m.posts, postID)
+	return nil
+}
+
+// UpdateByUser applies the same optimistic lock as PostStore.UpdateByUser.
+func (m *MockPostStore) UpdateByUser(ctx context.Context, post *Post) error {
+	existing, exists := m.posts[post.ID]
+	if !exists || existing.Version != post.Version {
+		return ErrNotFound
+	}
+	post.Version++
+	post.UpdatedAt = time.Now()
+	copied := *post
+	m.posts[post.ID] = &copied
+	return nil
+}
+
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	if post == nil {
+		return errors.New("post cannot be nil")
+	}
+	if post.ID == 0 {
+		post.ID = int64(len(m.posts) + 1)
+	}
+	post.CreatedAt = time.Now()
+	post.UpdatedAt = post.CreatedAt
+	copied := *post
+	m.posts[post.ID] = &copied
+	return nil
+}
+
+func (m *MockPostStore) GetUserFeed(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m *MockPostStore) GetUserPosts(ctx context.Context, authorID int64, viewerID int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m *MockPostStore) GetTimeline(ctx context.Context, userID int64, postIDs []int64, authorIDs []int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m *MockPostStore) GetTimelineSeed(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	return nil, nil
+}
+
+func (m *MockPostStore) GetViewerHistory(ctx context.Context, userID int64, since time.Time) (*ViewerHistory, error) {
+	return &ViewerHistory{}, nil
+}
+
+type MockCommentStore struct {
+	comments map[int64]*Comment
+}
+
+func NewMockCommentStore() *MockCommentStore {
+	return &MockCommentStore{comments: make(map[int64]*Comment)}
+}
+
+func (m *MockCommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+	comments := []*Comment{}
+	for _, comment := range m.comments {
+		if comment.PostID == postID {
+			copied := *comment
+			comments = append(comments, &copied)
+		}
+	}
+	return comments, nil
+}
+
+func (m *MockCommentStore) GetPostComments(ctx context.Context, postID int64, paginateQuery PaginateQueryFilter) ([]*Comment, Metadata, error) {
+	comments, err := m.GetByPostID(ctx, postID)
+	return comments, Metadata{}, err
+}
+
+func (m *MockCommentStore) GetById(ctx context.Context, id int64) (*Comment, error) {
+	comment, exists := m.comments[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *comment
+	return &copied, nil
+}
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	if comment == nil {
+		return errors.New("comment cannot be nil")
+	}
+	comment.ID = int64(len(m.comments) + 1)
+	copied := *comment
+	m.comments[comment.ID] = &copied
+	return nil
+}
+
+func (m *MockCommentStore) Update(ctx context.Context, comment *Comment) error {
+	if _, exists := m.comments[comment.ID]; !exists {
+		return ErrNotFound
+	}
+	copied := *comment
+	m.comments[comment.ID] = &copied
+	return nil
+}
+
+// MockMentionStore records nothing and reports no new mentions.
+type MockMentionStore struct{}
+
+func (m *MockMentionStore) SetPostMentions(ctx context.Context, postID int64, userIDs []int64) ([]int64, error) {
+	return nil, nil
+}
+
+func (m *MockMentionStore) SetCommentMentions(ctx context.Context, commentID int64, userIDs []int64) ([]int64, error) {
+	return nil, nil
+}
+
+// MockRoleStore serves the roles the migrations seed.
+type MockRoleStore struct{}
+
+func (m *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	levels := map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+	level, ok := levels[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Role{Name: name, Level: level}, nil
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	NotificationFollow         = "follow"
+	NotificationFollowAccepted = "follow_accepted"
+	NotificationComment        = "comment"
+	NotificationReply          = "reply"
+	NotificationMention        = "mention"
+)
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	ActorID   int64      `json:"actor_id"`
+	Type      string     `json:"type"`
+	PostID    *int64     `json:"post_id,omitempty"`
+	CommentID *int64     `json:"comment_id,omitempty"`
+	Count     int        `json:"count"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Actor     *User      `json:"actor,omitempty"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// Create records a notification. An event from the same actor, of the same
+// type and on the same post as an existing notification is collapsed into it:
+// the count goes up and the notification is marked unread again. It returns
+// ErrNotificationSkipped when the user has turned the type off, when either
+// user has blocked the other, or when the user cannot see the post.
+func (s *NotificationStore) Create(ctx context.Context, notification *Notification) error {
+	query := fmt.Sprintf(`INSERT INTO notifications(user_id, actor_id, type, post_id, comment_id)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_preferences
+			WHERE user_id = $1 AND type = $3 AND channel = 'off'
+		) AND %s AND (
+			$4::bigint IS NULL OR EXISTS (
+				SELECT 1 FROM posts p
+				INNER JOIN users u ON u.id = p.user_id
+				WHERE p.id = $4 AND %s
+			)
+		)
+		ON CONFLICT (user_id, type, actor_id, post_id) DO UPDATE
+		SET comment_id = EXCLUDED.comment_id, count = notifications.count + 1,
+			read_at = NULL, updated_at = NOW()
+		RETURNING id, count, created_at, updated_at`, notBlockedBetween("$1", "$2"), postVisibleTo("$1"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		notification.UserID,
+		notification.ActorID,
+		notification.Type,
+		notification.PostID,
+		notification.CommentID,
+	).Scan(&notification.ID, &notification.Count, &notification.CreatedAt, &notification.UpdatedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotificationSkipped
+		default:
+			return err
+		}
+	}
+
+	notification.ReadAt = nil
+	return nil
+}
+
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*Notification, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id,
+		n.count, n.read_at, n.created_at, n.updated_at,
+		u.id, u.first_name, u.last_name, u.username
+		FROM notifications n
+		INNER JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+		ORDER BY n.%s %s, n.id %[2]s
+		LIMIT $3 OFFSET $4`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	var unreadOnly bool
+	if filter, ok := paginateQuery.Filters.(*GetNotificationsFilter); ok && filter.Unread != nil {
+		unreadOnly = *filter.Unread
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, unreadOnly, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		notifications = []*Notification{}
+		totalRecords  int
+	)
+
+	for rows.Next() {
+		notification := &Notification{Actor: &User{}}
+		err := rows.Scan(
+			&totalRecords,
+			&notification.ID,
+			&notification.UserID,
+			&notification.ActorID,
+			&notification.Type,
+			&notification.PostID,
+			&notification.CommentID,
+			&notification.Count,
+			&notification.ReadAt,
+			&notification.CreatedAt,
+			&notification.UpdatedAt,
+			&notification.Actor.ID,
+			&notification.Actor.FirstName,
+			&notification.Actor.LastName,
+			&notification.Actor.Username,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return notifications, metadata, nil
+}
+
+func (s *NotificationStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkRead marks one of a user's notifications as read.
+func (s *NotificationStore) MarkRead(ctx context.Context, userID int64, notificationID int64) (*Notification, error) {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, actor_id, type, post_id, comment_id, count, read_at, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var notification Notification
+	err := s.db.QueryRowContext(ctx, query, notificationID, userID).Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.ActorID,
+		&notification.Type,
+		&notification.PostID,
+		&notification.CommentID,
+		&notification.Count,
+		&notification.ReadAt,
+		&notification.CreatedAt,
+		&notification.UpdatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &notification, nil
+}
+
+// MarkAllRead marks all of a user's unread notifications as read and returns
+// how many were updated.
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	stmt := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *NotificationStore) Delete(ctx context.Context, userID int64, notificationID int64) error {
+	stmt := `DELETE FROM notifications WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, notificationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
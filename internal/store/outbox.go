@@ -0,0 +1,224 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	EventUserCreated    = "user.created"
+	EventUserActivated  = "user.activated"
+	EventUserFollowed   = "user.followed"
+	EventPostCreated    = "post.created"
+	EventPostUpdated    = "post.updated"
+	EventPostDeleted    = "post.deleted"
+	EventCommentCreated = "comment.created"
+)
+
+// OutboxEvent is a domain event recorded in the same transaction as the change
+// it describes, so it is published if and only if the change is committed.
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	// Attempts is how many deliveries of the event have failed so far.
+	Attempts int `json:"-"`
+}
+
+// UserEvent is the payload of user events. It carries only what consumers
+// need: the email, role and suspension stay out of the outbox and everything
+// it feeds, webhooks included.
+type UserEvent struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	IsActive  bool      `json:"is_active"`
+	IsPrivate bool      `json:"is_private"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUserEvent(user *User) *UserEvent {
+	return &UserEvent{
+		ID:        user.ID,
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		IsActive:  user.IsActive,
+		IsPrivate: user.IsPrivate,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// CommentEvent is the payload of comment events. It leaves out the author
+// the handler attaches to the comment, whose email must not leave the API.
+type CommentEvent struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	ParentID  *int64    `json:"parent_id,omitempty"`
+	UserID    int64     `json:"user_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newCommentEvent(comment *Comment) *CommentEvent {
+	return &CommentEvent{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		UserID:    comment.UserID,
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// recordEvent writes an event to the outbox as part of tx.
+func recordEvent(ctx context.Context, tx *sql.Tx, aggregateType string, aggregateID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO outbox(aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = tx.ExecContext(ctx, stmt, aggregateType, aggregateID, eventType, string(data))
+	return err
+}
+
+// Claim leases up to limit unpublished events, oldest first, to the caller
+// for lease and returns them in order. The claim is committed before it
+// returns, so delivery happens outside any transaction; events whose lease
+// runs out before MarkPublished or Release is called are handed out again.
+// Concurrent callers get disjoint batches. Dead-lettered events are never
+// claimed.
+func (s *OutboxStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	query := `UPDATE outbox SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND dead_at IS NULL
+			AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, aggregate_type, aggregate_id, event_type, payload, created_at, attempts`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*OutboxEvent{}
+	for rows.Next() {
+		var event OutboxEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.AggregateType,
+			&event.AggregateID,
+			&event.Type,
+			&event.Payload,
+			&event.CreatedAt,
+			&event.Attempts,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the subquery's order.
+	slices.SortFunc(events, func(a, b *OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+
+	return events, nil
+}
+
+// MarkPublished records that the claimed events with ids were delivered.
+func (s *OutboxStore) MarkPublished(ctx context.Context, ids []int64) error {
+	stmt := `UPDATE outbox SET published_at = NOW(), claimed_until = NULL WHERE id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, stmt, pq.Array(ids))
+	return err
+}
+
+// Release gives up the claim on events with ids so they can be claimed again
+// without waiting for the lease to run out.
+func (s *OutboxStore) Release(ctx context.Context, ids []int64) error {
+	stmt := `UPDATE outbox SET claimed_until = NULL WHERE id = ANY($1) AND published_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, stmt, pq.Array(ids))
+	return err
+}
+
+// Retry records a failed delivery of the claimed event with id and releases
+// it to be claimed again.
+func (s *OutboxStore) Retry(ctx context.Context, id int64, lastError string) error {
+	stmt := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, claimed_until = NULL
+		WHERE id = $1 AND published_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, stmt, id, lastError)
+	return err
+}
+
+// Bury records a failed delivery of the claimed event with id and
+// dead-letters it, so it is no longer claimed. It stays in the outbox with
+// its last error until it is looked into; clearing dead_at relays it again.
+func (s *OutboxStore) Bury(ctx context.Context, id int64, lastError string) error {
+	stmt := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, dead_at = NOW(), claimed_until = NULL
+		WHERE id = $1 AND published_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, stmt, id, lastError)
+	return err
+}
+
+// DeletePublished removes events published before the given time and returns
+// how many were removed.
+func (s *OutboxStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	stmt := `DELETE FROM outbox WHERE published_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -1,12 +1,17 @@
 package store
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/devphaseX/mingle.git/internal/validator"
+	"github.com/google/uuid"
 )
 
 type PaginateQueryFilter struct {
@@ -16,9 +21,59 @@ type PaginateQueryFilter struct {
 
 	Filters      Filterable
 	SortSafelist []string // List of allowed sort fields
+	// CursorSigner lets the list be paged with a "cursor" query parameter
+	// instead of "page". Lists sorted by created_at set it.
+	CursorSigner CursorSigner
+	// CursorPurpose names the list being paged. Cursors from other lists are
+	// rejected.
+	CursorPurpose CursorPurpose
+	// Cursor is set in cursor mode. A zero Cursor starts at the top.
+	Cursor *Cursor
 	*validator.Validator
 }
 
+// CursorSigner signs cursors so clients cannot forge a position.
+type CursorSigner interface {
+	Sign(payload []byte, ttl time.Duration) string
+	Verify(token string) ([]byte, error)
+}
+
+// CursorPurpose is signed into a cursor so it only resumes the list that
+// issued it.
+type CursorPurpose string
+
+const (
+	FeedCursor      CursorPurpose = "feed"
+	UserPostsCursor CursorPurpose = "user_posts"
+	CommentsCursor  CursorPurpose = "comments"
+	SessionsCursor  CursorPurpose = "sessions"
+)
+
+// validID reports whether id has the type of the IDs in the list: UUIDs for
+// sessions, integers for everything else.
+func (p CursorPurpose) validID(id string) bool {
+	if p == SessionsCursor {
+		return uuid.Validate(id) == nil
+	}
+
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
+
+// cursorTTL is how long a cursor can be used to resume a list.
+const cursorTTL = 24 * time.Hour
+
+// Cursor is a position in a list sorted by (created_at, id). Unlike an
+// offset it stays put when rows are added ahead of it.
+type Cursor struct {
+	CreatedAt time.Time     `json:"t"`
+	ID        string        `json:"i"`
+	Sort      string        `json:"s"`
+	Purpose   CursorPurpose `json:"p"`
+	// Before pages back towards the top of the list.
+	Before bool `json:"b,omitempty"`
+}
+
 // Parse extracts and converts query parameters from the HTTP request.
 // It returns a PaginateQueryFilter and any conversion errors.
 func (q *PaginateQueryFilter) Parse(r *http.Request) error {
@@ -58,11 +113,33 @@ func (q *PaginateQueryFilter) Parse(r *http.Request) error {
 		}
 	}
 
+	// An empty "cursor" asks for the first page in cursor mode.
+	if q.CursorSigner != nil && qs.Has("cursor") {
+		if page != "" {
+			filterValidationErrors.AddFieldError("page", "cannot be combined with cursor")
+		}
+
+		cursor, err := q.decodeCursor(qs.Get("cursor"))
+		if err != nil {
+			filterValidationErrors.AddFieldError("cursor", "is invalid")
+		} else {
+			q.Cursor = cursor
+			if cursor.Sort != "" {
+				q.Sort = cursor.Sort
+			}
+		}
+	}
+
 	// Validate the struct using the validator
 	if err := q.Validator.Struct(q, &filterValidationErrors); err != nil {
 		return err
 	}
 
+	// Struct only reports the errors above when a struct rule fails too.
+	if len(filterValidationErrors.FieldErrors()) != 0 {
+		return &filterValidationErrors
+	}
+
 	if q.Filters != nil {
 		if err := q.Filters.ParseFilters(r); err != nil {
 			return err
@@ -95,16 +172,125 @@ func (q *PaginateQueryFilter) SortDirection() string {
 	return "ASC"
 }
 
-// Limit returns the number of items to return per page.
+// Limit returns the number of items to return per page. In cursor mode it
+// asks for one more, which CursorPage uses to tell whether another page
+// follows.
 func (q *PaginateQueryFilter) Limit() int {
+	if q.Cursor != nil {
+		return q.PageSize + 1
+	}
 	return q.PageSize
 }
 
 // Offset returns the number of items to skip (for pagination).
 func (q *PaginateQueryFilter) Offset() int {
+	if q.Cursor != nil {
+		return 0
+	}
 	return (q.Page - 1) * q.PageSize
 }
 
+func (q *PaginateQueryFilter) decodeCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return &Cursor{}, nil
+	}
+
+	payload, err := q.CursorSigner.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, err
+	}
+
+	if cursor.Purpose != q.CursorPurpose {
+		return nil, fmt.Errorf("cursor for %q used on %q", cursor.Purpose, q.CursorPurpose)
+	}
+
+	if cursor.ID != "" && !cursor.Purpose.validID(cursor.ID) {
+		return nil, fmt.Errorf("cursor id %q is malformed", cursor.ID)
+	}
+
+	if !PermittedValue(cursor.Sort, q.SortSafelist...) {
+		return nil, fmt.Errorf("cursor sort %q is not permitted", cursor.Sort)
+	}
+
+	return &cursor, nil
+}
+
+func (q *PaginateQueryFilter) encodeCursor(createdAt time.Time, id string, before bool) string {
+	payload, _ := json.Marshal(Cursor{CreatedAt: createdAt, ID: id, Sort: q.Sort, Purpose: q.CursorPurpose, Before: before})
+	return q.CursorSigner.Sign(payload, cursorTTL)
+}
+
+// KeysetCondition returns a condition matching the rows past the cursor,
+// comparing createdAtColumn and idColumn with parameters $n and $n+1, along
+// with the arguments to bind to them. Outside cursor mode, or at the top, it
+// matches every row and binds nothing.
+func (q *PaginateQueryFilter) KeysetCondition(createdAtColumn, idColumn string, n int) (string, []any) {
+	if q.Cursor == nil || q.Cursor.ID == "" {
+		return "TRUE", nil
+	}
+
+	op := ">"
+	if (q.SortDirection() == "DESC") != q.Cursor.Before {
+		op = "<"
+	}
+
+	condition := fmt.Sprintf("(%s, %s) %s ($%d, $%d)", createdAtColumn, idColumn, op, n, n+1)
+	return condition, []any{q.Cursor.CreatedAt, q.Cursor.ID}
+}
+
+// KeysetOrderBy returns an ORDER BY list sorting on createdAtColumn with
+// idColumn breaking ties, reversed when paging back so the rows nearest the
+// cursor come first.
+func (q *PaginateQueryFilter) KeysetOrderBy(createdAtColumn, idColumn string) string {
+	direction := q.SortDirection()
+	if q.Cursor != nil && q.Cursor.Before {
+		direction = map[string]string{"ASC": "DESC", "DESC": "ASC"}[direction]
+	}
+
+	return fmt.Sprintf("%s %s, %s %s", createdAtColumn, direction, idColumn, direction)
+}
+
+// CursorPage turns the rows fetched in cursor mode into a page: it drops the
+// extra row asked for by Limit, puts rows fetched backwards back in sort
+// order and returns metadata with cursors to the pages either side. key
+// returns a row's created_at and id.
+func CursorPage[T any](q PaginateQueryFilter, rows []T, key func(T) (time.Time, string)) ([]T, Metadata) {
+	more := len(rows) > q.PageSize
+	if more {
+		rows = rows[:q.PageSize]
+	}
+
+	if q.Cursor.Before {
+		slices.Reverse(rows)
+	}
+
+	metadata := Metadata{PageSize: q.PageSize}
+	if len(rows) == 0 {
+		return rows, metadata
+	}
+
+	// Coming from one side means there is a page on that side.
+	hasNext := more || q.Cursor.Before
+	hasPrev := (more && q.Cursor.Before) || (!q.Cursor.Before && q.Cursor.ID != "")
+
+	if hasNext {
+		createdAt, id := key(rows[len(rows)-1])
+		metadata.NextCursor = q.encodeCursor(createdAt, id, false)
+	}
+
+	if hasPrev {
+		createdAt, id := key(rows[0])
+		metadata.PrevCursor = q.encodeCursor(createdAt, id, true)
+	}
+
+	return rows, metadata
+}
+
 // PermittedValue checks if a value is in a list of permitted values.
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	for _, permittedValue := range permittedValues {
@@ -121,9 +307,13 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+	// Set instead of the page fields in cursor mode.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
-func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+// CalculateMetadata describes where a page sits among totalRecords results.
+func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
 	if totalRecords == 0 {
 		return Metadata{}
 	}
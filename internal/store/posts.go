@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/lib/pq"
@@ -20,6 +21,7 @@ type Post struct {
 	Version   int        `json:"version"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+	HiddenAt  *time.Time `json:"hidden_at,omitempty"`
 	Comments  []*Comment `json:"comments,omitempty"`
 }
 
@@ -46,14 +48,23 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	args := []any{post.Title, post.Context, post.UserID, pq.Array(post.Tags)}
-	row := s.db.QueryRowContext(ctx, query, args...)
+	tagsJSON, err := json.Marshal(post.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %v", err)
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		args := []any{post.Title, post.Context, post.UserID, tagsJSON}
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt); err != nil {
+			return err
+		}
 
-	return row.Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+		return recordEvent(ctx, tx, "post", post.ID, EventPostCreated, post)
+	})
 }
 
 func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id, title, content, user_id, tags,version, created_at, updated_at FROM posts
+	query := `SELECT id, title, content, user_id, tags,version, created_at, updated_at, hidden_at FROM posts
 			 WHERE id = $1
 			 `
 
@@ -71,6 +82,7 @@ func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 		&post.Version,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.HiddenAt,
 	)
 
 	if err != nil {
@@ -91,53 +103,143 @@ func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 	return &post, nil
 }
 
-func (s *PostStore) DeleteByUser(ctx context.Context, postId int64, userId int64) error {
-	stmt := `DELETE FROM posts WHERE posts.id = $1 and posts.user_id = $2`
+// GetVisibleById fetches a post the viewer is allowed to see. Posts by private
+// accounts are only visible to the author and their followers, and posts hidden
+// by a moderator are only visible to their author. Posts by suspended users
+// are hidden from everyone. Anyone else gets ErrNotFound so the post's
+// existence is not leaked.
+func (s *PostStore) GetVisibleById(ctx context.Context, id int64, viewerID int64) (*Post, error) {
+	query := fmt.Sprintf(`SELECT p.id, p.title, p.content, p.user_id, p.tags, p.version, p.created_at, p.updated_at, p.hidden_at FROM posts p
+			 INNER JOIN users u ON u.id = p.user_id
+			 WHERE p.id = $1 AND %s AND (
+				p.user_id = $2 OR (
+					p.hidden_at IS NULL AND (
+						NOT u.is_private OR
+						EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $2)
+					)
+				)
+			 )
+			 `, notSuspended("p.user_id"))
+
+	var post Post
+	var tagsJSON []byte
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	res, err := s.db.ExecContext(ctx, stmt, postId, userId)
+	err := s.db.QueryRowContext(ctx, query, id, viewerID).Scan(
+		&post.ID,
+		&post.Title,
+		&post.Context,
+		&post.UserID,
+		&tagsJSON,
+		&post.Version,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.HiddenAt,
+	)
 
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
-	rowsCount, err := res.RowsAffected()
+	if tagsJSON != nil {
+		if err := json.Unmarshal(tagsJSON, &post.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %v", err)
+		}
 
-	if err != nil {
-		return err
 	}
 
-	if rowsCount == 0 {
-		return ErrNotFound
-	}
+	return &post, nil
+}
+
+func (s *PostStore) DeleteByUser(ctx context.Context, postId int64, userId int64) error {
+	stmt := `DELETE FROM posts WHERE posts.id = $1 and posts.user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, stmt, postId, userId)
 
-	return nil
+		if err != nil {
+			return err
+		}
+
+		rowsCount, err := res.RowsAffected()
+
+		if err != nil {
+			return err
+		}
+
+		if rowsCount == 0 {
+			return ErrNotFound
+		}
+
+		payload := map[string]int64{"id": postId, "user_id": userId}
+		return recordEvent(ctx, tx, "post", postId, EventPostDeleted, payload)
+	})
 }
 
 func (s *PostStore) UpdateByUser(ctx context.Context, post *Post) error {
-	query := `UPDATE posts SET title = $1, content = $2, version = version + 1
-			WHERE id = $3 AND version = $4
-			RETURNING version
+	query := `UPDATE posts SET title = $1, content = $2, tags = $3, version = version + 1, updated_at = NOW()
+			WHERE id = $4 AND version = $5
+			RETURNING version, updated_at
 		`
 
+	tagsJSON, err := json.Marshal(post.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %v", err)
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(ctx, query, post.Title, post.Context, post.ID, post.Version).Scan(&post.Version)
 
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, post.Title, post.Context, tagsJSON, post.ID, post.Version).Scan(&post.Version, &post.UpdatedAt)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+
 		}
 
-	}
-	return nil
+		return recordEvent(ctx, tx, "post", post.ID, EventPostUpdated, post)
+	})
 }
 
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	return s.getFeed(ctx, userID, "TRUE", nil, paginateQuery)
+}
+
+// GetTimeline is GetUserFeed limited to the posts in a materialised timeline
+// plus any by authorIDs, whose posts are not copied into timelines. The
+// feed's visibility rules still apply, so posts deleted, hidden or unfollowed
+// since they were added drop out.
+func (s *PostStore) GetTimeline(ctx context.Context, userID int64, postIDs []int64, authorIDs []int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	source := fmt.Sprintf("(p.id = ANY($%d) OR p.user_id = ANY($%d))", feedSourceParam, feedSourceParam+1)
+	return s.getFeed(ctx, userID, source, []any{pq.Array(postIDs), pq.Array(authorIDs)}, paginateQuery)
+}
+
+// feedSourceParam is the first parameter free for getFeed's source, after the
+// user, pagination and feed filter parameters.
+const feedSourceParam = 10
+
+// getFeed reads a page of the user's feed limited to posts matching source,
+// whose arguments are bound from feedSourceParam.
+func (s *PostStore) getFeed(ctx context.Context, userID int64, source string, sourceArgs []any, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	filter := paginateQuery.Filters.(*GetUserFeedFilter)
+	filterWhere, filterHaving, filterArgs := filter.sqlConditions()
+
+	keyset, keysetArgs := paginateQuery.KeysetCondition("p.created_at", "p.id", feedSourceParam+len(sourceArgs))
+
 	query := fmt.Sprintf(`
     		SELECT count(p.id) OVER (), p.id, p.title, p.content,
     		p.user_id, p.created_at, p.version, p.tags, count(c.id) as comments_count,
@@ -145,37 +247,229 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, paginateQuery
     		users.id as current_user_id
     		FROM posts p
     		INNER JOIN users ON p.user_id = users.id
-    		LEFT JOIN followers f ON f.follower_id = users.id
-    		LEFT JOIN comments c ON p.id = c.post_id
-    		WHERE (p.user_id = $1 OR p.user_id = f.user_id) AND
-    		(
-    		    ($4::text IS NULL OR p.title ILIKE '%%' || $4 || '%%') AND
-    		    ($4::text IS NULL OR p.content ILIKE '%%' || $4 || '%%') AND
-                ($5::text[] IS NULL OR p.tags ?| $5::text[])
-    		)
+    		LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
+    		LEFT JOIN comments c ON p.id = c.post_id AND c.hidden_at IS NULL AND %s
+    		WHERE (p.user_id = $1 OR f.follower_id IS NOT NULL) AND
+    		(p.hidden_at IS NULL OR p.user_id = $1) AND
+    		%s AND
+    		(%s) AND
+    		%s AND
+    		%s
     		GROUP BY p.id, users.id
-    		ORDER BY %s %s
-    		LIMIT $2 OFFSET $3`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+    		HAVING %s
+    		ORDER BY %s
+    		LIMIT $2 OFFSET $3`, notSuspended("c.user_id"), notSuspended("p.user_id"), filterWhere, source, keyset,
+		filterHaving, paginateQuery.KeysetOrderBy("p.created_at", "p.id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+
+	defer cancel()
+	args := []any{userID, paginateQuery.Limit(), paginateQuery.Offset()}
+	args = append(args, filterArgs...)
+	args = append(args, sourceArgs...)
+	args = append(args, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
 
+	posts, totalRecords, err := scanPostsWithMetadata(rows)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if paginateQuery.Cursor != nil {
+		posts, metadata := CursorPage(paginateQuery, posts, postKey)
+		return posts, metadata, nil
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return posts, metadata, nil
+}
+
+// GetUserPosts reads a page of the author's posts as the viewer sees them:
+// hidden posts only for the author, posts by private accounts only for their
+// followers, and nothing between users who have blocked each other.
+func (s *PostStore) GetUserPosts(ctx context.Context, authorID int64, viewerID int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
 	filter := paginateQuery.Filters.(*GetUserFeedFilter)
+	filterWhere, filterHaving, filterArgs := filter.sqlConditions()
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	keyset, keysetArgs := paginateQuery.KeysetCondition("p.created_at", "p.id", 11)
+
+	query := fmt.Sprintf(`
+		SELECT count(p.id) OVER (), p.id, p.title, p.content,
+		p.user_id, p.created_at, p.version, p.tags, count(c.id) as comments_count,
+		users.first_name, users.last_name, users.username,
+		users.id as current_user_id
+		FROM posts p
+		INNER JOIN users ON p.user_id = users.id
+		LEFT JOIN comments c ON p.id = c.post_id AND c.hidden_at IS NULL AND %s
+		WHERE p.user_id = $10 AND
+		(p.user_id = $1 OR (
+			p.hidden_at IS NULL AND (
+				NOT users.is_private OR
+				EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1)
+			)
+		)) AND
+		%s AND
+		%s AND
+		(%s) AND
+		%s
+		GROUP BY p.id, users.id
+		HAVING %s
+		ORDER BY %s
+		LIMIT $2 OFFSET $3`, notSuspended("c.user_id"), notSuspended("p.user_id"), notBlockedBetween("$1", "p.user_id"),
+		filterWhere, keyset, filterHaving, paginateQuery.KeysetOrderBy("p.created_at", "p.id"))
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	rows, err := s.db.QueryContext(
-		ctx,
-		query,
-		userID,
-		paginateQuery.Limit(),
-		paginateQuery.Offset(),
-		filter.Search,
-		pq.Array(filter.Tags),
-	)
 
+	args := []any{viewerID, paginateQuery.Limit(), paginateQuery.Offset()}
+	args = append(args, filterArgs...)
+	args = append(args, authorID)
+	args = append(args, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	posts, totalRecords, err := scanPostsWithMetadata(rows)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 
+	if paginateQuery.Cursor != nil {
+		posts, metadata := CursorPage(paginateQuery, posts, postKey)
+		return posts, metadata, nil
+	}
+
+	return posts, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+type TimelineEntry struct {
+	PostID    int64
+	CreatedAt time.Time
+}
+
+// GetTimelineSeed returns the newest posts for the user's timeline, by the
+// user and the users they follow, to rebuild it from.
+func (s *PostStore) GetTimelineSeed(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.created_at FROM posts p
+		WHERE (p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)) AND
+		p.hidden_at IS NULL
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var entry TimelineEntry
+		if err := rows.Scan(&entry.PostID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// ViewerHistory sums up a user's recent activity for ranking their feed.
+type ViewerHistory struct {
+	// AuthorInteractions counts the user's comments on each author's posts.
+	AuthorInteractions map[int64]int
+	// TagCounts counts the tags on posts the user wrote or commented on.
+	TagCounts map[string]int
+}
+
+func (s *PostStore) GetViewerHistory(ctx context.Context, userID int64, since time.Time) (*ViewerHistory, error) {
+	authorsQuery := `
+		SELECT p.user_id, count(*) FROM comments c
+		INNER JOIN posts p ON p.id = c.post_id
+		WHERE c.user_id = $1 AND p.user_id <> $1 AND c.created_at > $2
+		GROUP BY p.user_id`
+
+	tagsQuery := `
+		SELECT tag, count(*) FROM posts p
+		CROSS JOIN LATERAL jsonb_array_elements_text(
+			CASE WHEN jsonb_typeof(p.tags) = 'array' THEN p.tags ELSE '[]'::jsonb END
+		) AS tag
+		WHERE (
+			(p.user_id = $1 AND p.created_at > $2) OR
+			EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id AND c.user_id = $1 AND c.created_at > $2)
+		)
+		GROUP BY tag`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	history := &ViewerHistory{
+		AuthorInteractions: map[int64]int{},
+		TagCounts:          map[string]int{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, authorsQuery, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			authorID int64
+			count    int
+		)
+		if err := rows.Scan(&authorID, &count); err != nil {
+			return nil, err
+		}
+		history.AuthorInteractions[authorID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, tagsQuery, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tag   string
+			count int
+		)
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, err
+		}
+		history.TagCounts[tag] = count
+	}
+
+	return history, rows.Err()
+}
+
+func postKey(post *PostWithMetadata) (time.Time, string) {
+	return post.CreatedAt, strconv.FormatInt(post.ID, 10)
+}
+
+// scanPostsWithMetadata scans rows selecting, in order, the total record
+// count, the post columns, the comment count and the author columns.
+func scanPostsWithMetadata(rows *sql.Rows) ([]*PostWithMetadata, int, error) {
 	var posts = []*PostWithMetadata{}
 	var totalRecords int
 
@@ -184,7 +478,7 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, paginateQuery
 
 		var tagsJSON []byte
 
-		rows.Scan(
+		err := rows.Scan(
 			&totalRecords,
 			&post.ID,
 			&post.Title,
@@ -199,17 +493,22 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, paginateQuery
 			&post.User.Username,
 			&post.User.ID,
 		)
+		if err != nil {
+			return nil, 0, err
+		}
 
 		if tagsJSON != nil {
 			if err := json.Unmarshal(tagsJSON, &post.Tags); err != nil {
-				return nil, Metadata{}, fmt.Errorf("failed to unmarshal tags: %v", err)
+				return nil, 0, fmt.Errorf("failed to unmarshal tags: %v", err)
 			}
 		}
 
 		posts = append(posts, &post)
 	}
 
-	metadata := calculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
 
-	return posts, metadata, nil
+	return posts, totalRecords, nil
 }
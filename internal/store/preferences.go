@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// NotificationChannelInApp only shows notifications in the app.
+	NotificationChannelInApp = "in_app"
+	// NotificationChannelEmail shows notifications in the app and includes
+	// them in digest emails.
+	NotificationChannelEmail = "email"
+	// NotificationChannelOff turns a notification type off altogether.
+	NotificationChannelOff = "off"
+
+	DefaultNotificationChannel = NotificationChannelEmail
+
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+
+	DefaultDigestFrequency = DigestWeekly
+)
+
+var NotificationTypes = []string{
+	NotificationFollow,
+	NotificationFollowAccepted,
+	NotificationComment,
+	NotificationReply,
+	NotificationMention,
+}
+
+type NotificationPreferences struct {
+	Channels        map[string]string `json:"channels"`
+	DigestFrequency string            `json:"digest_frequency"`
+}
+
+// Digest is a pending digest email: the user's unread notifications on the
+// email channel since their last digest.
+type Digest struct {
+	User          *User
+	Frequency     string
+	LastDigestAt  *time.Time
+	Since         time.Time
+	Notifications []*Notification
+}
+
+type NotificationPreferenceStore struct {
+	db *sql.DB
+}
+
+// Get returns a user's preferences with defaults filled in for anything they
+// have not set.
+func (s *NotificationPreferenceStore) Get(ctx context.Context, userID int64) (*NotificationPreferences, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	preferences := &NotificationPreferences{
+		Channels:        make(map[string]string, len(NotificationTypes)),
+		DigestFrequency: DefaultDigestFrequency,
+	}
+
+	for _, t := range NotificationTypes {
+		preferences.Channels[t] = DefaultNotificationChannel
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT type, channel FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t, channel string
+		if err := rows.Scan(&t, &channel); err != nil {
+			return nil, err
+		}
+		preferences.Channels[t] = channel
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT digest_frequency FROM notification_settings WHERE user_id = $1`, userID).
+		Scan(&preferences.DigestFrequency)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// Update sets the channel for each notification type in channels and, when
+// digestFrequency is not nil, the digest frequency.
+func (s *NotificationPreferenceStore) Update(ctx context.Context, userID int64, channels map[string]string, digestFrequency *string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		channelStmt := `INSERT INTO notification_preferences(user_id, type, channel)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, type) DO UPDATE SET channel = EXCLUDED.channel, updated_at = NOW()`
+
+		for t, channel := range channels {
+			if _, err := tx.ExecContext(ctx, channelStmt, userID, t, channel); err != nil {
+				return err
+			}
+		}
+
+		if digestFrequency == nil {
+			return nil
+		}
+
+		digestStmt := `INSERT INTO notification_settings(user_id, digest_frequency)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET digest_frequency = EXCLUDED.digest_frequency`
+		_, err := tx.ExecContext(ctx, digestStmt, userID, *digestFrequency)
+
+		return err
+	})
+}
+
+// digestPeriod is how far apart a user's digests are sent, for a query that
+// joins notification_settings as s.
+const digestPeriod = `CASE COALESCE(s.digest_frequency, '` + DefaultDigestFrequency + `')
+	WHEN 'daily' THEN interval '1 day'
+	ELSE interval '7 days'
+END`
+
+// emailChannel matches notifications in n whose type the user has left on,
+// or set to, the email channel.
+const emailChannel = `COALESCE(
+	(SELECT channel FROM notification_preferences p WHERE p.user_id = n.user_id AND p.type = n.type),
+	'` + DefaultNotificationChannel + `'
+) = 'email'`
+
+// GetDueDigests returns up to limit users whose digest is due at now and who
+// have something to put in it.
+func (s *NotificationPreferenceStore) GetDueDigests(ctx context.Context, now time.Time, limit int) ([]*Digest, error) {
+	query := fmt.Sprintf(`
+		SELECT u.id, u.email, u.username, u.first_name, u.last_name,
+		COALESCE(s.digest_frequency, '%[1]s'), s.last_digest_at,
+		COALESCE(s.last_digest_at, $1::timestamptz - %[2]s)
+		FROM users u
+		LEFT JOIN notification_settings s ON s.user_id = u.id
+		WHERE u.is_active
+		AND COALESCE(s.digest_frequency, '%[1]s') <> 'off'
+		AND (s.last_digest_at IS NULL OR s.last_digest_at <= $1::timestamptz - %[2]s)
+		AND %[3]s
+		AND EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.id AND n.read_at IS NULL
+			AND n.updated_at > COALESCE(s.last_digest_at, $1::timestamptz - %[2]s)
+			AND %[4]s
+		)
+		ORDER BY u.id
+		LIMIT $2`, DefaultDigestFrequency, digestPeriod, notSuspended("u.id"), emailChannel)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := []*Digest{}
+	for rows.Next() {
+		digest := &Digest{User: &User{}}
+		err := rows.Scan(
+			&digest.User.ID,
+			&digest.User.Email,
+			&digest.User.Username,
+			&digest.User.FirstName,
+			&digest.User.LastName,
+			&digest.Frequency,
+			&digest.LastDigestAt,
+			&digest.Since,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, digest)
+	}
+
+	return digests, rows.Err()
+}
+
+// ClaimDigest records that a digest is being sent at now. It returns false
+// when another instance has claimed the same digest since lastDigestAt was
+// read, so each digest is only sent once.
+func (s *NotificationPreferenceStore) ClaimDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) (bool, error) {
+	query := `INSERT INTO notification_settings(user_id, last_digest_at)
+		VALUES ($1, $3)
+		ON CONFLICT (user_id) DO UPDATE SET last_digest_at = EXCLUDED.last_digest_at
+		WHERE notification_settings.last_digest_at IS NOT DISTINCT FROM $2
+		RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query, userID, lastDigestAt, now).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseDigest undoes a ClaimDigest at now whose email could not be queued,
+// so the digest is due again.
+func (s *NotificationPreferenceStore) ReleaseDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) error {
+	// last_digest_at holds whole seconds, so now is rounded the same way.
+	query := `UPDATE notification_settings SET last_digest_at = $2
+		WHERE user_id = $1 AND last_digest_at = $3::timestamp(0) with time zone`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, lastDigestAt, now)
+	return err
+}
+
+// GetDigestNotifications returns a user's unread notifications on the email
+// channel updated after since, newest first.
+func (s *NotificationPreferenceStore) GetDigestNotifications(ctx context.Context, userID int64, since time.Time, limit int) ([]*Notification, error) {
+	query := fmt.Sprintf(`
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id,
+		n.count, n.created_at, n.updated_at,
+		u.id, u.first_name, u.last_name, u.username
+		FROM notifications n
+		INNER JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND n.read_at IS NULL AND n.updated_at > $2 AND %s
+		ORDER BY n.updated_at DESC, n.id DESC
+		LIMIT $3`, emailChannel)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	for rows.Next() {
+		notification := &Notification{Actor: &User{}}
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.ActorID,
+			&notification.Type,
+			&notification.PostID,
+			&notification.CommentID,
+			&notification.Count,
+			&notification.CreatedAt,
+			&notification.UpdatedAt,
+			&notification.Actor.ID,
+			&notification.Actor.FirstName,
+			&notification.Actor.LastName,
+			&notification.Actor.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
@@ -0,0 +1,414 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+
+	ReportStatusOpen     = "open"
+	ReportStatusClaimed  = "claimed"
+	ReportStatusResolved = "resolved"
+
+	ModerationActionDismiss     = "dismiss"
+	ModerationActionHideContent = "hide_content"
+	ModerationActionWarnUser    = "warn_user"
+	ModerationActionSuspendUser = "suspend_user"
+)
+
+type Report struct {
+	ID         int64               `json:"id"`
+	ReporterID int64               `json:"reporter_id"`
+	TargetType string              `json:"target_type"`
+	TargetID   int64               `json:"target_id"`
+	Reason     string              `json:"reason"`
+	Status     string              `json:"status"`
+	ClaimedBy  *int64              `json:"claimed_by,omitempty"`
+	ClaimedAt  *time.Time          `json:"claimed_at,omitempty"`
+	ResolvedAt *time.Time          `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	Actions    []*ModerationAction `json:"actions,omitempty"`
+}
+
+type ModerationAction struct {
+	ID            int64       `json:"id"`
+	ReportID      int64       `json:"report_id"`
+	ModeratorID   int64       `json:"moderator_id"`
+	SubjectUserID *int64      `json:"subject_user_id,omitempty"`
+	Action        string      `json:"action"`
+	Note          string      `json:"note"`
+	SuspensionID  *int64      `json:"suspension_id,omitempty"`
+	Suspension    *Suspension `json:"suspension,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type ReportStore struct {
+	db *sql.DB
+}
+
+// reportTargetOwnerQueries returns the owner of a reported target, which is
+// the user a warning or suspension applies to.
+var reportTargetOwnerQueries = map[string]string{
+	ReportTargetPost:    `SELECT user_id FROM posts WHERE id = $1`,
+	ReportTargetComment: `SELECT user_id FROM comments WHERE id = $1`,
+	ReportTargetUser:    `SELECT id FROM users WHERE id = $1`,
+}
+
+func (s *ReportStore) Create(ctx context.Context, report *Report) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if _, err := reportTargetOwner(ctx, tx, report.TargetType, report.TargetID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO reports(reporter_id, target_type, target_id, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, report.ReporterID, report.TargetType, report.TargetID, report.Reason).
+			Scan(&report.ID, &report.Status, &report.CreatedAt)
+
+		if err != nil {
+			var pgErr *pq.Error
+			if errors.As(err, &pgErr) {
+				// SQL State "23505" means unique_violation
+				if pgErr.Code == "23505" {
+					return ErrConflict
+				}
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *ReportStore) GetAll(ctx context.Context, paginateQuery PaginateQueryFilter) ([]*Report, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, reporter_id, target_type, target_id, reason,
+		status, claimed_by, claimed_at, resolved_at, created_at
+		FROM reports
+		WHERE ($1::text IS NULL OR status = $1) AND
+		($2::text IS NULL OR target_type = $2)
+		ORDER BY %s %s
+		LIMIT $3 OFFSET $4`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	filter := paginateQuery.Filters.(*GetReportsFilter)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		filter.Status,
+		filter.TargetType,
+		paginateQuery.Limit(),
+		paginateQuery.Offset(),
+	)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		reports      = []*Report{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var report Report
+		err := rows.Scan(
+			&totalRecords,
+			&report.ID,
+			&report.ReporterID,
+			&report.TargetType,
+			&report.TargetID,
+			&report.Reason,
+			&report.Status,
+			&report.ClaimedBy,
+			&report.ClaimedAt,
+			&report.ResolvedAt,
+			&report.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return reports, metadata, nil
+}
+
+// GetById fetches a report together with the moderation actions recorded
+// against it.
+func (s *ReportStore) GetById(ctx context.Context, id int64) (*Report, error) {
+	query := `SELECT id, reporter_id, target_type, target_id, reason,
+		status, claimed_by, claimed_at, resolved_at, created_at
+		FROM reports WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var report Report
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&report.ID,
+		&report.ReporterID,
+		&report.TargetType,
+		&report.TargetID,
+		&report.Reason,
+		&report.Status,
+		&report.ClaimedBy,
+		&report.ClaimedAt,
+		&report.ResolvedAt,
+		&report.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	actions, err := s.getActions(ctx, report.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Actions = actions
+
+	return &report, nil
+}
+
+func (s *ReportStore) getActions(ctx context.Context, reportID int64) ([]*ModerationAction, error) {
+	query := `SELECT id, report_id, moderator_id, subject_user_id, action, note, suspension_id, created_at
+		FROM moderation_actions WHERE report_id = $1
+		ORDER BY created_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*ModerationAction{}
+	for rows.Next() {
+		var action ModerationAction
+		err := rows.Scan(
+			&action.ID,
+			&action.ReportID,
+			&action.ModeratorID,
+			&action.SubjectUserID,
+			&action.Action,
+			&action.Note,
+			&action.SuspensionID,
+			&action.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, &action)
+	}
+
+	return actions, rows.Err()
+}
+
+// Claim assigns an open report to a moderator. Claiming a report the
+// moderator already holds is a no-op.
+func (s *ReportStore) Claim(ctx context.Context, report *Report, moderatorID int64) error {
+	query := `UPDATE reports SET status = 'claimed', claimed_by = $1, claimed_at = NOW()
+		WHERE id = $2 AND (status = 'open' OR (status = 'claimed' AND claimed_by = $1))
+		RETURNING status, claimed_by, claimed_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, moderatorID, report.ID).
+		Scan(&report.Status, &report.ClaimedBy, &report.ClaimedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if report.Status == ReportStatusResolved {
+				return ErrReportResolved
+			}
+			return ErrReportClaimed
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve closes a report, applies the moderation action and records it. The
+// report must be open or claimed by the resolving moderator.
+func (s *ReportStore) Resolve(ctx context.Context, report *Report, action *ModerationAction) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockReportForResolution(ctx, tx, report, action.ModeratorID); err != nil {
+			return err
+		}
+
+		ownerID, err := reportTargetOwner(ctx, tx, report.TargetType, report.TargetID)
+		if err != nil {
+			return err
+		}
+
+		switch action.Action {
+		case ModerationActionHideContent:
+			if err := hideReportTarget(ctx, tx, report.TargetType, report.TargetID); err != nil {
+				return err
+			}
+			action.SubjectUserID = &ownerID
+		case ModerationActionWarnUser:
+			action.SubjectUserID = &ownerID
+		case ModerationActionSuspendUser:
+			action.SubjectUserID = &ownerID
+
+			if action.Suspension == nil {
+				action.Suspension = &Suspension{Reason: report.Reason}
+			}
+			action.Suspension.UserID = ownerID
+			action.Suspension.SuspendedBy = &action.ModeratorID
+
+			if err := suspendUser(ctx, tx, action.Suspension); err != nil {
+				return err
+			}
+			action.SuspensionID = &action.Suspension.ID
+		}
+
+		action.ReportID = report.ID
+		if err := createModerationAction(ctx, tx, action); err != nil {
+			return err
+		}
+
+		query := `UPDATE reports SET status = 'resolved', resolved_at = NOW()
+			WHERE id = $1 RETURNING status, resolved_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, report.ID).Scan(&report.Status, &report.ResolvedAt); err != nil {
+			return err
+		}
+
+		report.Actions = append(report.Actions, action)
+
+		return nil
+	})
+}
+
+func lockReportForResolution(ctx context.Context, tx *sql.Tx, report *Report, moderatorID int64) error {
+	query := `SELECT status, claimed_by FROM reports WHERE id = $1 FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		status    string
+		claimedBy *int64
+	)
+
+	err := tx.QueryRowContext(ctx, query, report.ID).Scan(&status, &claimedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case status == ReportStatusResolved:
+		return ErrReportResolved
+	case status == ReportStatusClaimed && claimedBy != nil && *claimedBy != moderatorID:
+		return ErrReportClaimed
+	}
+
+	return nil
+}
+
+func reportTargetOwner(ctx context.Context, tx *sql.Tx, targetType string, targetID int64) (int64, error) {
+	query, ok := reportTargetOwnerQueries[targetType]
+	if !ok {
+		return 0, fmt.Errorf("unknown report target type: %q", targetType)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var ownerID int64
+	err := tx.QueryRowContext(ctx, query, targetID).Scan(&ownerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return ownerID, nil
+}
+
+func hideReportTarget(ctx context.Context, tx *sql.Tx, targetType string, targetID int64) error {
+	var stmt string
+
+	switch targetType {
+	case ReportTargetPost:
+		stmt = `UPDATE posts SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL`
+	case ReportTargetComment:
+		stmt = `UPDATE comments SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL`
+	default:
+		return ErrModerationActionNotApplicable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, stmt, targetID)
+	return err
+}
+
+func createModerationAction(ctx context.Context, tx *sql.Tx, action *ModerationAction) error {
+	query := `INSERT INTO moderation_actions(report_id, moderator_id, subject_user_id, action, note, suspension_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		action.ReportID,
+		action.ModeratorID,
+		action.SubjectUserID,
+		action.Action,
+		action.Note,
+		action.SuspensionID,
+	).Scan(&action.ID, &action.CreatedAt)
+}
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+	SearchUsers    = "users"
+	SearchTags     = "tags"
+)
+
+var SearchTypes = []string{SearchPosts, SearchComments, SearchUsers, SearchTags}
+
+// UserSummary is the public part of a user shown alongside search results.
+type UserSummary struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Highlighted fields are HTML-escaped with matches wrapped in <mark> tags.
+type PostSearchResult struct {
+	ID        int64       `json:"id"`
+	Title     string      `json:"title"`
+	Snippet   string      `json:"snippet"`
+	Tags      []string    `json:"tags"`
+	CreatedAt time.Time   `json:"created_at"`
+	Author    UserSummary `json:"author"`
+	Rank      float64     `json:"rank"`
+}
+
+type CommentSearchResult struct {
+	ID        int64       `json:"id"`
+	PostID    int64       `json:"post_id"`
+	Snippet   string      `json:"snippet"`
+	CreatedAt time.Time   `json:"created_at"`
+	Author    UserSummary `json:"author"`
+	Rank      float64     `json:"rank"`
+}
+
+type UserSearchResult struct {
+	UserSummary
+	Name string  `json:"name"`
+	Rank float64 `json:"rank"`
+}
+
+type TagSearchResult struct {
+	Tag       string  `json:"tag"`
+	PostCount int     `json:"post_count"`
+	Rank      float64 `json:"rank"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+const (
+	headlineOptions  = `StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+	highlightOptions = `StartSel=<mark>, StopSel=</mark>, HighlightAll=true`
+)
+
+// escapeHTML wraps a text SQL expression so its result is safe to embed in
+// HTML. Highlighting is applied afterwards, so only the <mark> tags are
+// markup.
+func escapeHTML(expr string) string {
+	return fmt.Sprintf(`replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`, expr)
+}
+
+// postVisibleTo matches posts in p by authors in u that the viewer, given as
+// a query parameter, may see. See PostStore.GetVisibleById.
+func postVisibleTo(viewer string) string {
+	return fmt.Sprintf(`(p.user_id = %[1]s OR (
+		p.hidden_at IS NULL AND (
+			NOT u.is_private OR
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = %[1]s)
+		)
+	)) AND %[2]s`, viewer, notSuspended("p.user_id"))
+}
+
+func (s *SearchStore) SearchPosts(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*PostSearchResult, Metadata, error) {
+	sqlQuery := fmt.Sprintf(`
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query)
+		SELECT count(*) OVER(), p.id, p.tags, p.created_at,
+		u.id, u.username, u.first_name, u.last_name,
+		ts_headline('english', %s, q.query, '%s'),
+		ts_headline('english', %s, q.query, '%s'),
+		ts_rank_cd(p.search_vector, q.query) + similarity(p.title, $2) AS rank
+		FROM posts p
+		INNER JOIN users u ON u.id = p.user_id
+		CROSS JOIN q
+		WHERE (p.search_vector @@ q.query OR p.title %% $2) AND
+		%s AND
+		%s
+		ORDER BY rank DESC, p.id DESC
+		LIMIT $3 OFFSET $4`,
+		escapeHTML("p.title"), highlightOptions,
+		escapeHTML("p.content"), headlineOptions,
+		postVisibleTo("$1"), notBlockedBetween("$1", "p.user_id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, viewerID, query, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		results      = []*PostSearchResult{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var (
+			result   PostSearchResult
+			tagsJSON []byte
+		)
+
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&tagsJSON,
+			&result.CreatedAt,
+			&result.Author.ID,
+			&result.Author.Username,
+			&result.Author.FirstName,
+			&result.Author.LastName,
+			&result.Title,
+			&result.Snippet,
+			&result.Rank,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if tagsJSON != nil {
+			if err := json.Unmarshal(tagsJSON, &result.Tags); err != nil {
+				return nil, Metadata{}, fmt.Errorf("failed to unmarshal tags: %v", err)
+			}
+		}
+
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+// SearchComments matches comments on posts the viewer may see. Typos are
+// caught by matching the query against the closest words in the comment.
+func (s *SearchStore) SearchComments(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*CommentSearchResult, Metadata, error) {
+	sqlQuery := fmt.Sprintf(`
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query)
+		SELECT count(*) OVER(), c.id, c.post_id, c.created_at,
+		cu.id, cu.username, cu.first_name, cu.last_name,
+		ts_headline('english', %s, q.query, '%s'),
+		ts_rank_cd(c.search_vector, q.query) + word_similarity($2, c.content) AS rank
+		FROM comments c
+		INNER JOIN users cu ON cu.id = c.user_id
+		INNER JOIN posts p ON p.id = c.post_id
+		INNER JOIN users u ON u.id = p.user_id
+		CROSS JOIN q
+		WHERE (c.search_vector @@ q.query OR $2 <%% c.content) AND
+		c.hidden_at IS NULL AND
+		%s AND
+		%s AND
+		%s AND
+		%s
+		ORDER BY rank DESC, c.id DESC
+		LIMIT $3 OFFSET $4`,
+		escapeHTML("c.content"), headlineOptions,
+		notSuspended("c.user_id"), postVisibleTo("$1"),
+		notBlockedBetween("$1", "c.user_id"), notBlockedBetween("$1", "p.user_id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, viewerID, query, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		results      = []*CommentSearchResult{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var result CommentSearchResult
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&result.PostID,
+			&result.CreatedAt,
+			&result.Author.ID,
+			&result.Author.Username,
+			&result.Author.FirstName,
+			&result.Author.LastName,
+			&result.Snippet,
+			&result.Rank,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+// SearchUsers matches active users by username or name. Names are not
+// stemmed, and near misses on either are caught by trigram similarity.
+func (s *SearchStore) SearchUsers(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*UserSearchResult, Metadata, error) {
+	sqlQuery := fmt.Sprintf(`
+		WITH q AS (SELECT plainto_tsquery('simple', $2) AS query)
+		SELECT count(*) OVER(), u.id, u.username, u.first_name, u.last_name,
+		ts_headline('simple', %s, q.query, '%s'),
+		ts_rank_cd(u.search_vector, q.query) +
+			greatest(similarity(u.username, $2), similarity(u.first_name || ' ' || u.last_name, $2)) AS rank
+		FROM users u
+		CROSS JOIN q
+		WHERE (u.search_vector @@ q.query OR u.username %% $2 OR (u.first_name || ' ' || u.last_name) %% $2) AND
+		u.is_active AND
+		%s AND
+		%s
+		ORDER BY rank DESC, u.id DESC
+		LIMIT $3 OFFSET $4`,
+		escapeHTML("u.first_name || ' ' || u.last_name"), highlightOptions,
+		notSuspended("u.id"), notBlockedBetween("$1", "u.id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, viewerID, query, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		results      = []*UserSearchResult{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var result UserSearchResult
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&result.Username,
+			&result.FirstName,
+			&result.LastName,
+			&result.Name,
+			&result.Rank,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchTags matches tags on public posts that start with the query or are
+// close to it, with prefix matches first.
+func (s *SearchStore) SearchTags(ctx context.Context, query string, paginateQuery PaginateQueryFilter) ([]*TagSearchResult, Metadata, error) {
+	sqlQuery := fmt.Sprintf(`
+		SELECT count(*) OVER(), tag, count(*) AS post_count,
+		(CASE WHEN tag ILIKE $2::text || '%%' THEN 1 ELSE 0 END) + similarity(tag, $1) AS rank
+		FROM posts p
+		INNER JOIN users u ON u.id = p.user_id
+		CROSS JOIN LATERAL jsonb_array_elements_text(
+			CASE WHEN jsonb_typeof(p.tags) = 'array' THEN p.tags ELSE '[]'::jsonb END
+		) AS tag
+		WHERE (tag ILIKE $2::text || '%%' OR tag %% $1::text) AND
+		NOT u.is_private AND
+		p.hidden_at IS NULL AND
+		%s
+		GROUP BY tag
+		ORDER BY rank DESC, post_count DESC, tag ASC
+		LIMIT $3 OFFSET $4`, notSuspended("p.user_id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, query, likeEscaper.Replace(query), paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		results      = []*TagSearchResult{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var result TagSearchResult
+		if err := rows.Scan(&totalRecords, &result.Tag, &result.PostCount, &result.Rank); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
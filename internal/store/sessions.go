@@ -136,6 +136,15 @@ func (s *SessionStore) InvalidateSession(ctx context.Context, sessionID string)
 	return err
 }
 
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
 func (s *SessionStore) UpdateLastUsed(ctx context.Context, sessionID string) error {
 	query := `UPDATE sessions SET last_used = NOW() WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -199,29 +208,25 @@ func (s *SessionStore) GetSessionByID(ctx context.Context, sessionID string) (*S
 }
 
 func (s *SessionStore) GetSessionsByUserID(ctx context.Context, userID string, isAdmin bool, paginateQuery PaginateQueryFilter) ([]Session, Metadata, error) {
-	var sessions []Session
+	sessions := []Session{}
 	var totalRecords int
 
-	query := `
+	keyset, keysetArgs := paginateQuery.KeysetCondition("created_at", "id", 5)
+
+	// Admins see every user's sessions.
+	query := fmt.Sprintf(`
 		SELECT count(*) OVER(), id, user_id, user_agent, ip, expires_at, last_used, created_at
 		FROM sessions
-	`
-	if !isAdmin {
-		query += ` WHERE user_id = $1`
-	}
-
-	query += fmt.Sprintf(` ORDER BY %s %s LIMIT $2 OFFSET $3`, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+		WHERE ($1 OR user_id = $2) AND %s
+		ORDER BY %s
+		LIMIT $3 OFFSET $4`, keyset, paginateQuery.KeysetOrderBy("created_at", "id"))
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var rows *sql.Rows
-	var err error
-	if !isAdmin {
-		rows, err = s.db.QueryContext(ctx, query, userID, paginateQuery.Limit(), paginateQuery.Offset())
-	} else {
-		rows, err = s.db.QueryContext(ctx, query, paginateQuery.Limit(), paginateQuery.Offset())
-	}
+	args := append([]any{isAdmin, userID, paginateQuery.Limit(), paginateQuery.Offset()}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -243,7 +248,14 @@ func (s *SessionStore) GetSessionsByUserID(ctx context.Context, userID string, i
 		return nil, Metadata{}, err
 	}
 
-	metadata := calculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+	if paginateQuery.Cursor != nil {
+		sessions, metadata := CursorPage(paginateQuery, sessions, func(session Session) (time.Time, string) {
+			return session.CreatedAt, session.ID
+		})
+		return sessions, metadata, nil
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
 
 	return sessions, metadata, nil
 }
@@ -8,32 +8,73 @@ import (
 )
 
 var (
-	ErrNotFound               = errors.New("resource not found")
-	ErrConflict               = errors.New("resource already exist")
-	ErrUserAlreadyActivated   = errors.New("user already activated")
-	ErrSessionCannotBeExtends = errors.New("session cannot be extended: RememberMe is not enabled")
-	ErrDuplicateEmail         = UserFriendlyError{UserMessage: "email already taken", InternalErr: ErrConflict}
-	ErrDuplicateUsername      = UserFriendlyError{UserMessage: "username already taken", InternalErr: ErrConflict}
-	QueryTimeoutDuration      = time.Second * 5
+	ErrNotFound                      = errors.New("resource not found")
+	ErrConflict                      = errors.New("resource already exist")
+	ErrUserAlreadyActivated          = errors.New("user already activated")
+	ErrSessionCannotBeExtends        = errors.New("session cannot be extended: RememberMe is not enabled")
+	ErrReportClaimed                 = errors.New("report is claimed by another moderator")
+	ErrReportResolved                = errors.New("report has already been resolved")
+	ErrModerationActionNotApplicable = errors.New("moderation action does not apply to this report target")
+	ErrBlocked                       = errors.New("users have blocked each other")
+	ErrNotificationSkipped           = errors.New("notification skipped")
+	ErrDuplicateEmail                = UserFriendlyError{UserMessage: "email already taken", InternalErr: ErrConflict}
+	ErrDuplicateUsername             = UserFriendlyError{UserMessage: "username already taken", InternalErr: ErrConflict}
+	QueryTimeoutDuration             = time.Second * 5
 )
 
+// The stores with cached reads are named so that their write paths can be
+// wrapped; see cache.Invalidating.
+
+type PostStorage interface {
+	GetById(context.Context, int64) (*Post, error)
+	GetVisibleById(ctx context.Context, postID int64, viewerID int64) (*Post, error)
+	DeleteByUser(ctx context.Context, postId int64, userId int64) error
+	UpdateByUser(context.Context, *Post) error
+	Create(context.Context, *Post) error
+	GetUserFeed(context.Context, int64, PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error)
+	GetUserPosts(ctx context.Context, authorID int64, viewerID int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error)
+	GetTimeline(ctx context.Context, userID int64, postIDs []int64, authorIDs []int64, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error)
+	GetTimelineSeed(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+	GetViewerHistory(ctx context.Context, userID int64, since time.Time) (*ViewerHistory, error)
+}
+
+type UserStorage interface {
+	Create(context.Context, *User, *sql.Tx) error
+	GetById(context.Context, int64) (*User, error)
+	GetByEmail(context.Context, string) (*User, error)
+	Delete(context.Context, int64) error
+	Activate(context.Context, string) (int64, error)
+	SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error
+	GetIDsByUsernames(ctx context.Context, usernames []string) ([]int64, error)
+	CreateAndInvite(ctx context.Context, user *User, invitationExp time.Duration, token string) error
+	createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Time, userId int64) error
+}
+
+type CommentStorage interface {
+	GetByPostID(context.Context, int64) ([]*Comment, error)
+	GetPostComments(ctx context.Context, postID int64, paginateQuery PaginateQueryFilter) ([]*Comment, Metadata, error)
+	GetById(context.Context, int64) (*Comment, error)
+	Create(context.Context, *Comment) error
+	Update(context.Context, *Comment) error
+}
+
+type ReportStorage interface {
+	Create(context.Context, *Report) error
+	GetAll(context.Context, PaginateQueryFilter) ([]*Report, Metadata, error)
+	GetById(context.Context, int64) (*Report, error)
+	Claim(ctx context.Context, report *Report, moderatorID int64) error
+	Resolve(ctx context.Context, report *Report, action *ModerationAction) error
+}
+
+type SuspensionStorage interface {
+	Suspend(context.Context, *Suspension) error
+	GetActive(ctx context.Context, userID int64) (*Suspension, error)
+	Lift(ctx context.Context, userID int64, liftedBy int64) error
+}
+
 type Storage struct {
-	Posts interface {
-		GetById(context.Context, int64) (*Post, error)
-		DeleteByUser(ctx context.Context, postId int64, userId int64) error
-		UpdateByUser(context.Context, *Post) error
-		Create(context.Context, *Post) error
-		GetUserFeed(context.Context, int64, PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error)
-	}
-	Users interface {
-		Create(context.Context, *User, *sql.Tx) error
-		GetById(context.Context, int64) (*User, error)
-		GetByEmail(context.Context, string) (*User, error)
-		Delete(context.Context, int64) error
-		Activate(context.Context, string) error
-		CreateAndInvite(ctx context.Context, user *User, invitationExp time.Duration, token string) error
-		createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Time, userId int64) error
-	}
+	Posts PostStorage
+	Users UserStorage
 
 	Sessions interface {
 		CreateSession(ctx context.Context, userID int64, userAgent, ip string, expiry time.Duration, rememberMe bool) (*Session, error)
@@ -45,28 +86,133 @@ type Storage struct {
 		ExtendSessionAndGenerateRefreshToken(ctx context.Context, session *Session, tokenMaker TokenMaker, rememberPeriod time.Duration) (string, error)
 	}
 
-	Comments interface {
-		GetByPostID(context.Context, int64) ([]*Comment, error)
+	Comments CommentStorage
+
+	Tags interface {
+		GetTrending(ctx context.Context, window, halfLife time.Duration, limit int) ([]*TrendingTag, error)
+		GetPosts(ctx context.Context, tag string, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error)
+	}
+
+	Mentions interface {
+		SetPostMentions(ctx context.Context, postID int64, userIDs []int64) ([]int64, error)
+		SetCommentMentions(ctx context.Context, commentID int64, userIDs []int64) ([]int64, error)
+	}
+
+	Notifications interface {
+		Create(context.Context, *Notification) error
+		GetByUserID(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*Notification, Metadata, error)
+		CountUnread(ctx context.Context, userID int64) (int, error)
+		MarkRead(ctx context.Context, userID int64, notificationID int64) (*Notification, error)
+		MarkAllRead(ctx context.Context, userID int64) (int64, error)
+		Delete(ctx context.Context, userID int64, notificationID int64) error
+	}
+
+	NotificationPreferences interface {
+		Get(ctx context.Context, userID int64) (*NotificationPreferences, error)
+		Update(ctx context.Context, userID int64, channels map[string]string, digestFrequency *string) error
+		GetDueDigests(ctx context.Context, now time.Time, limit int) ([]*Digest, error)
+		ClaimDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) (bool, error)
+		ReleaseDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) error
+		GetDigestNotifications(ctx context.Context, userID int64, since time.Time, limit int) ([]*Notification, error)
 	}
 
 	Followers interface {
 		FollowUser(ctx context.Context, follower *Follower) error
 		UnFollowUser(ctx context.Context, followedUserID int64, userID int64) error
+		IsFollowing(ctx context.Context, userID int64, followerID int64) (bool, error)
+		GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
+		CountFollowers(ctx context.Context, userID int64) (int, error)
+		RequestFollow(ctx context.Context, request *FollowRequest) error
+		GetFollowRequests(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*FollowRequest, Metadata, error)
+		AcceptFollowRequest(ctx context.Context, userID int64, requesterID int64) (*Follower, error)
+		RejectFollowRequest(ctx context.Context, userID int64, requesterID int64) error
+	}
+
+	Blocks interface {
+		Block(context.Context, *Block) error
+		Unblock(ctx context.Context, userID int64, blockedID int64) error
+		IsBlocked(ctx context.Context, userID int64, otherID int64) (bool, error)
+		BlockedAmong(ctx context.Context, userID int64, otherIDs []int64) ([]int64, error)
+	}
+
+	Conversations interface {
+		Create(ctx context.Context, conversation *Conversation, memberIDs []int64) (bool, error)
+		GetForMember(ctx context.Context, conversationID int64, userID int64) (*Conversation, error)
+		GetAllForUser(ctx context.Context, userID int64, paginateQuery PaginateQueryFilter) ([]*Conversation, Metadata, error)
+		GetMemberIDs(ctx context.Context, conversationID int64) ([]int64, error)
+		CountUnread(ctx context.Context, userID int64) (int, error)
+		CreateMessage(context.Context, *Message) error
+		GetMessages(ctx context.Context, conversationID int64, paginateQuery PaginateQueryFilter) ([]*Message, Metadata, error)
+		MarkRead(ctx context.Context, conversationID int64, userID int64, messageID int64) (int64, error)
 	}
 
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+
+	Reports ReportStorage
+
+	Jobs interface {
+		Enqueue(context.Context, *Job) error
+		Claim(ctx context.Context, limit int) ([]*Job, error)
+		Complete(ctx context.Context, jobID int64) error
+		Retry(ctx context.Context, jobID int64, runAt time.Time, lastError string) error
+		Bury(ctx context.Context, jobID int64, lastError string) error
+		RequeueStale(ctx context.Context, timeout time.Duration) (int64, error)
+	}
+
+	Outbox interface {
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error)
+		MarkPublished(ctx context.Context, ids []int64) error
+		Release(ctx context.Context, ids []int64) error
+		Retry(ctx context.Context, id int64, lastError string) error
+		Bury(ctx context.Context, id int64, lastError string) error
+		DeletePublished(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	Webhooks interface {
+		Create(context.Context, *Webhook) error
+		GetAll(context.Context) ([]*Webhook, error)
+		GetById(context.Context, int64) (*Webhook, error)
+		Update(context.Context, *Webhook) error
+		Delete(context.Context, int64) error
+		CreateDeliveries(ctx context.Context, event *OutboxEvent, jobType string, maxAttempts int) (int64, error)
+		GetDeliveries(ctx context.Context, webhookID int64, paginateQuery PaginateQueryFilter) ([]*WebhookDelivery, Metadata, error)
+		GetDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, *Webhook, error)
+		RecordAttempt(ctx context.Context, deliveryID int64, attempt *WebhookAttempt) error
+		Redeliver(ctx context.Context, webhookID int64, deliveryID int64, jobType string, maxAttempts int) (*WebhookDelivery, error)
+	}
+
+	Search interface {
+		SearchPosts(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*PostSearchResult, Metadata, error)
+		SearchComments(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*CommentSearchResult, Metadata, error)
+		SearchUsers(ctx context.Context, viewerID int64, query string, paginateQuery PaginateQueryFilter) ([]*UserSearchResult, Metadata, error)
+		SearchTags(ctx context.Context, query string, paginateQuery PaginateQueryFilter) ([]*TagSearchResult, Metadata, error)
+	}
+
+	Suspensions SuspensionStorage
 }
 
 func NewPostgressStorage(db *sql.DB) Storage {
 	return Storage{
-		Users:     &UserStore{db},
-		Posts:     &PostStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Sessions:  &SessionStore{db},
-		Roles:     &RoleStore{db},
+		Users:                   &UserStore{db},
+		Posts:                   &PostStore{db},
+		Comments:                &CommentStore{db},
+		Followers:               &FollowerStore{db},
+		Sessions:                &SessionStore{db},
+		Roles:                   &RoleStore{db},
+		Reports:                 &ReportStore{db},
+		Suspensions:             &SuspensionStore{db},
+		Mentions:                &MentionStore{db},
+		Tags:                    &TagStore{db},
+		Notifications:           &NotificationStore{db},
+		Blocks:                  &BlockStore{db},
+		Conversations:           &ConversationStore{db},
+		NotificationPreferences: &NotificationPreferenceStore{db},
+		Jobs:                    &JobStore{db},
+		Outbox:                  &OutboxStore{db},
+		Webhooks:                &WebhookStore{db},
+		Search:                  &SearchStore{db},
 	}
 }
 
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Suspension struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	SuspendedBy *int64     `json:"suspended_by,omitempty"`
+	Reason      string     `json:"reason"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	LiftedAt    *time.Time `json:"lifted_at,omitempty"`
+}
+
+// Active reports whether the suspension is in force at the given time.
+// Suspensions with an end time lift on their own once it has passed.
+func (s *Suspension) Active(now time.Time) bool {
+	if s == nil || s.LiftedAt != nil {
+		return false
+	}
+
+	return s.EndsAt == nil || now.Before(*s.EndsAt)
+}
+
+type SuspensionStore struct {
+	db *sql.DB
+}
+
+// notSuspended is a SQL predicate that matches when the user in userColumn
+// has no suspension in force. Content queries use it to hide a suspended
+// user's posts and comments until the suspension ends.
+func notSuspended(userColumn string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_suspensions us
+		WHERE us.user_id = %s AND us.lifted_at IS NULL AND (us.ends_at IS NULL OR us.ends_at > NOW())
+	)`, userColumn)
+}
+
+// Suspend suspends a user, replacing any suspension already in force, and
+// revokes all of their sessions.
+func (s *SuspensionStore) Suspend(ctx context.Context, suspension *Suspension) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return suspendUser(ctx, tx, suspension)
+	})
+}
+
+func (s *SuspensionStore) GetActive(ctx context.Context, userID int64) (*Suspension, error) {
+	query := `SELECT id, user_id, suspended_by, reason, starts_at, ends_at, lifted_at
+		FROM user_suspensions
+		WHERE user_id = $1 AND lifted_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())
+		ORDER BY starts_at DESC
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var suspension Suspension
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&suspension.ID,
+		&suspension.UserID,
+		&suspension.SuspendedBy,
+		&suspension.Reason,
+		&suspension.StartsAt,
+		&suspension.EndsAt,
+		&suspension.LiftedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &suspension, nil
+}
+
+// Lift ends a user's suspension early.
+func (s *SuspensionStore) Lift(ctx context.Context, userID int64, liftedBy int64) error {
+	stmt := `UPDATE user_suspensions SET lifted_at = NOW(), lifted_by = $2
+		WHERE user_id = $1 AND lifted_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, userID, liftedBy)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func suspendUser(ctx context.Context, tx *sql.Tx, suspension *Suspension) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	liftStmt := `UPDATE user_suspensions SET lifted_at = NOW(), lifted_by = $2
+		WHERE user_id = $1 AND lifted_at IS NULL`
+
+	if _, err := tx.ExecContext(ctx, liftStmt, suspension.UserID, suspension.SuspendedBy); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_suspensions(user_id, suspended_by, reason, ends_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, starts_at`
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		suspension.UserID,
+		suspension.SuspendedBy,
+		suspension.Reason,
+		suspension.EndsAt,
+	).Scan(&suspension.ID, &suspension.StartsAt)
+
+	if err != nil {
+		return err
+	}
+
+	return revokeUserSessions(ctx, tx, suspension.UserID)
+}
+
+// activeSuspensionJoin attaches the suspension in force, if any, to each row
+// of the users table.
+const activeSuspensionJoin = `LEFT JOIN LATERAL (
+		SELECT id, suspended_by, reason, starts_at, ends_at FROM user_suspensions
+		WHERE user_suspensions.user_id = users.id AND lifted_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())
+		ORDER BY starts_at DESC
+		LIMIT 1
+	) active_suspension ON TRUE`
+
+const activeSuspensionColumns = `active_suspension.id, active_suspension.suspended_by, active_suspension.reason,
+	active_suspension.starts_at, active_suspension.ends_at`
+
+type nullSuspension struct {
+	id          sql.NullInt64
+	suspendedBy sql.NullInt64
+	reason      sql.NullString
+	startsAt    sql.NullTime
+	endsAt      sql.NullTime
+}
+
+func (n *nullSuspension) dest() []any {
+	return []any{&n.id, &n.suspendedBy, &n.reason, &n.startsAt, &n.endsAt}
+}
+
+func (n *nullSuspension) suspension(userID int64) *Suspension {
+	if !n.id.Valid {
+		return nil
+	}
+
+	suspension := &Suspension{
+		ID:       n.id.Int64,
+		UserID:   userID,
+		Reason:   n.reason.String,
+		StartsAt: n.startsAt.Time,
+	}
+
+	if n.suspendedBy.Valid {
+		suspension.SuspendedBy = &n.suspendedBy.Int64
+	}
+
+	if n.endsAt.Valid {
+		suspension.EndsAt = &n.endsAt.Time
+	}
+
+	return suspension
+}
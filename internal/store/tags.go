@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type TrendingTag struct {
+	Tag       string  `json:"tag"`
+	PostCount int     `json:"post_count"`
+	Score     float64 `json:"score"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// GetTrending ranks the tags used on public posts created within window. Each
+// post contributes a score that halves every halfLife, so recent activity
+// outweighs older posts with the same tag.
+func (s *TagStore) GetTrending(ctx context.Context, window, halfLife time.Duration, limit int) ([]*TrendingTag, error) {
+	query := fmt.Sprintf(`
+		SELECT tag, count(*) AS post_count,
+		sum(power(0.5, extract(epoch FROM (NOW() - p.created_at)) / $2)) AS score
+		FROM posts p
+		INNER JOIN users u ON u.id = p.user_id
+		CROSS JOIN LATERAL jsonb_array_elements_text(
+			CASE WHEN jsonb_typeof(p.tags) = 'array' THEN p.tags ELSE '[]'::jsonb END
+		) AS tag
+		WHERE p.created_at > NOW() - $1 * interval '1 second' AND
+		NOT u.is_private AND
+		p.hidden_at IS NULL AND
+		%s
+		GROUP BY tag
+		ORDER BY score DESC, post_count DESC, tag ASC
+		LIMIT $3`, notSuspended("p.user_id"))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*TrendingTag{}
+	for rows.Next() {
+		var tag TrendingTag
+		if err := rows.Scan(&tag.Tag, &tag.PostCount, &tag.Score); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetPosts returns the public timeline for a tag. Posts by private or
+// suspended accounts and hidden posts are left out. It accepts the same
+// filters as the user feed.
+func (s *TagStore) GetPosts(ctx context.Context, tag string, paginateQuery PaginateQueryFilter) ([]*PostWithMetadata, Metadata, error) {
+	filter := paginateQuery.Filters.(*GetUserFeedFilter)
+	filterWhere, filterHaving, filterArgs := filter.sqlConditions()
+
+	query := fmt.Sprintf(`
+    		SELECT count(p.id) OVER (), p.id, p.title, p.content,
+    		p.user_id, p.created_at, p.version, p.tags, count(c.id) as comments_count,
+    		users.first_name, users.last_name, users.username,
+    		users.id as current_user_id
+    		FROM posts p
+    		INNER JOIN users ON p.user_id = users.id
+    		LEFT JOIN comments c ON p.id = c.post_id AND c.hidden_at IS NULL AND %s
+    		WHERE p.tags ? $1 AND
+    		NOT users.is_private AND
+    		p.hidden_at IS NULL AND
+    		%s AND
+    		(%s)
+    		GROUP BY p.id, users.id
+    		HAVING %s
+    		ORDER BY %s %s
+    		LIMIT $2 OFFSET $3`, notSuspended("c.user_id"), notSuspended("p.user_id"), filterWhere, filterHaving,
+		paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{tag, paginateQuery.Limit(), paginateQuery.Offset()}, filterArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	posts, totalRecords, err := scanPostsWithMetadata(rows)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize)
+
+	return posts, metadata, nil
+}
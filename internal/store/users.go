@@ -14,17 +14,24 @@ import (
 )
 
 type User struct {
-	ID              int64      `json:"id"`
-	FirstName       string     `json:"first_name"`
-	LastName        string     `json:"last_name"`
-	Username        string     `json:"username"`
-	Email           string     `json:"email,omitempty"`
-	IsActive        bool       `json:"is_active"`
-	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
-	Password        password   `json:"-"`
-	CreatedAt       time.Time  `json:"created_at"`
-	RoleID          int64      `json:"role_id"`
-	Role            Role       `json:"role"`
+	ID              int64       `json:"id"`
+	FirstName       string      `json:"first_name"`
+	LastName        string      `json:"last_name"`
+	Username        string      `json:"username"`
+	Email           string      `json:"email,omitempty"`
+	IsActive        bool        `json:"is_active"`
+	IsPrivate       bool        `json:"is_private"`
+	EmailVerifiedAt *time.Time  `json:"email_verified_at,omitempty"`
+	Password        password    `json:"-"`
+	CreatedAt       time.Time   `json:"created_at"`
+	RoleID          int64       `json:"role_id"`
+	Role            Role        `json:"role"`
+	Suspension      *Suspension `json:"-"` // admin-only, via GET /users/{id}/suspension
+}
+
+// IsSuspended reports whether the user has a suspension in force.
+func (u *User) IsSuspended() bool {
+	return u.Suspension.Active(time.Now())
 }
 
 type password struct {
@@ -98,18 +105,22 @@ func (s *UserStore) Create(ctx context.Context, user *User, tx *sql.Tx) error {
 }
 
 func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
-	query := `SELECT users.id, first_name, last_name, username,
-			 email, created_at,is_active,email_verified_at, roles.* FROM users
+	query := fmt.Sprintf(`SELECT users.id, first_name, last_name, username,
+			 email, created_at,is_active,email_verified_at, is_private, roles.*, %s FROM users
 			 JOIN roles ON users.role_id = roles.id
+			 %s
 			 where users.id = $1
-	`
+	`, activeSuspensionColumns, activeSuspensionJoin)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
-	var user User
-	err := s.db.QueryRowContext(ctx, query, userId).Scan(
+	var (
+		user       User
+		suspension nullSuspension
+	)
+	dest := []any{
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -118,11 +129,13 @@ func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
 		&user.CreatedAt,
 		&user.IsActive,
 		&user.EmailVerifiedAt,
+		&user.IsPrivate,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
 		&user.Role.Description,
-	)
+	}
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(append(dest, suspension.dest()...)...)
 
 	if err != nil {
 		switch {
@@ -133,28 +146,37 @@ func (s *UserStore) GetById(ctx context.Context, userId int64) (*User, error) {
 		}
 	}
 
+	user.Suspension = suspension.suspension(user.ID)
+
 	return &user, nil
 }
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, first_name, last_name, username, email,is_active,email_verified_at,password_hash, created_at FROM users
+	query := fmt.Sprintf(`SELECT users.id, first_name, last_name, username, email,is_active,email_verified_at,password_hash, created_at, %s FROM users
+				 %s
 				 where email ilike $1
-		`
+		`, activeSuspensionColumns, activeSuspensionJoin)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
-	var user User
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
+	var (
+		user       User
+		suspension nullSuspension
+	)
+	dest := []any{
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
 		&user.Username,
 		&user.Email,
+		&user.IsActive,
+		&user.EmailVerifiedAt,
 		&user.Password.hash,
 		&user.CreatedAt,
-	)
+	}
+	err := s.db.QueryRowContext(ctx, query, email).Scan(append(dest, suspension.dest()...)...)
 
 	if err != nil {
 		switch {
@@ -165,9 +187,41 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		}
 	}
 
+	user.Suspension = suspension.suspension(user.ID)
+
 	return &user, nil
 }
 
+// GetIDsByUsernames resolves usernames to user IDs. Usernames that do not
+// belong to any user are skipped.
+func (s *UserStore) GetIDsByUsernames(ctx context.Context, usernames []string) ([]int64, error) {
+	if len(usernames) == 0 {
+		return []int64{}, nil
+	}
+
+	query := `SELECT id FROM users WHERE username = ANY($1::text[])`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
 func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Time, userId int64) error {
 	query := `INSERT INTO user_invitations(token, user_id, expiry)
 			 VALUES ($1, $2, $3)
@@ -195,13 +249,16 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, invitationE
 			return err
 		}
 
-		return nil
+		return recordEvent(ctx, tx, "user", user.ID, EventUserCreated, newUserEvent(user))
 	})
 
 }
 
-func (s *UserStore) Activate(ctx context.Context, token string) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+// Activate activates the user invited with token and returns their ID.
+func (s *UserStore) Activate(ctx context.Context, token string) (int64, error) {
+	var userID int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
 		user, err := s.getUserFromInvitation(ctx, tx, token)
 
 		fmt.Println(user)
@@ -221,13 +278,21 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 			return err
 		}
 
-		return s.deleteUserInvitation(ctx, tx, token)
+		if err := s.deleteUserInvitation(ctx, tx, token); err != nil {
+			return err
+		}
+
+		userID = user.ID
+		return recordEvent(ctx, tx, "user", user.ID, EventUserActivated, newUserEvent(user))
 	})
+
+	return userID, err
 }
 
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 
-	query := `SELECT u.id, u.first_name, u.last_name, u.email, u.is_active, u.email_verified_at FROM users u
+	query := `SELECT u.id, u.first_name, u.last_name, u.username, u.email, u.is_active, u.is_private,
+			  u.email_verified_at, u.created_at FROM users u
 			  INNER JOIN user_invitations ui ON ui.user_id = u.id
 			  where ui.token = $1 and ui.expiry > $2
 	`
@@ -245,9 +310,12 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
+		&user.Username,
 		&user.Email,
 		&user.IsActive,
+		&user.IsPrivate,
 		&user.EmailVerifiedAt,
+		&user.CreatedAt,
 	)
 
 	if err != nil {
@@ -306,6 +374,38 @@ func (s *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return err
 }
 
+// SetPrivacy switches a user's account between public and private. When an
+// account goes public, pending follow requests are accepted since they no
+// longer need approval.
+func (s *UserStore) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE users SET is_private = $1 WHERE id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, isPrivate, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsCount, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsCount == 0 {
+			return ErrNotFound
+		}
+
+		if isPrivate {
+			return nil
+		}
+
+		return acceptPendingFollowRequests(ctx, tx, userID)
+	})
+}
+
 func (s *UserStore) Delete(ctx context.Context, userId int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.delete(ctx, tx, userId); err != nil {
@@ -0,0 +1,397 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookEvents are the outbox event types webhooks can subscribe to.
+var WebhookEvents = []string{
+	EventUserCreated,
+	EventUserActivated,
+	EventUserFollowed,
+	EventPostCreated,
+	EventPostUpdated,
+	EventPostDeleted,
+	EventCommentCreated,
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	IsActive  bool      `json:"is_active"`
+	CreatedBy *int64    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent to one webhook. The response fields
+// describe the latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   *string         `json:"response_body,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	DurationMS     *int            `json:"duration_ms,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookAttempt is the outcome of sending a delivery once.
+type WebhookAttempt struct {
+	Succeeded      bool
+	ResponseStatus *int
+	ResponseBody   *string
+	Error          *string
+	Duration       time.Duration
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	query := `INSERT INTO webhooks(url, events, secret, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		webhook.URL,
+		pq.Array(webhook.Events),
+		webhook.Secret,
+		webhook.IsActive,
+		webhook.CreatedBy,
+	).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+func (s *WebhookStore) GetAll(ctx context.Context) ([]*Webhook, error) {
+	query := `SELECT id, url, events, secret, is_active, created_by, created_at, updated_at
+		FROM webhooks ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s *WebhookStore) GetById(ctx context.Context, id int64) (*Webhook, error) {
+	query := `SELECT id, url, events, secret, is_active, created_by, created_at, updated_at
+		FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var webhook Webhook
+	if err := scanWebhook(s.db.QueryRowContext(ctx, query, id), &webhook); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func scanWebhook(row interface{ Scan(...any) error }, webhook *Webhook) error {
+	return row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Secret,
+		&webhook.IsActive,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+}
+
+func (s *WebhookStore) Update(ctx context.Context, webhook *Webhook) error {
+	query := `UPDATE webhooks SET url = $1, events = $2, secret = $3, is_active = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		webhook.URL,
+		pq.Array(webhook.Events),
+		webhook.Secret,
+		webhook.IsActive,
+		webhook.ID,
+	).Scan(&webhook.UpdatedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, id int64) error {
+	stmt := `DELETE FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries records a delivery of event for every active webhook
+// subscribed to its type and queues a job of jobType to send each one.
+// Deliveries that already exist for the event are left alone, so relaying the
+// same event twice does not send it twice. It returns how many deliveries were
+// created.
+func (s *WebhookStore) CreateDeliveries(ctx context.Context, event *OutboxEvent, jobType string, maxAttempts int) (int64, error) {
+	stmt := `WITH deliveries AS (
+			INSERT INTO webhook_deliveries(webhook_id, event_id, event_type, payload)
+			SELECT id, $1, $2, $3 FROM webhooks
+			WHERE is_active AND $2 = ANY(events)
+			ON CONFLICT (webhook_id, event_id) DO NOTHING
+			RETURNING id
+		)
+		INSERT INTO jobs(type, payload, max_attempts)
+		SELECT $4, json_build_object('delivery_id', id), $5 FROM deliveries`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, stmt, event.ID, event.Type, string(event.Payload), jobType, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status,
+	d.attempts, d.response_status, d.response_body, d.error, d.duration_ms,
+	d.last_attempt_at, d.created_at`
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }, delivery *WebhookDelivery, extra ...any) error {
+	dest := []any{
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.ResponseBody,
+		&delivery.Error,
+		&delivery.DurationMS,
+		&delivery.LastAttemptAt,
+		&delivery.CreatedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+func (s *WebhookStore) GetDeliveries(ctx context.Context, webhookID int64, paginateQuery PaginateQueryFilter) ([]*WebhookDelivery, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, count(*) OVER()
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1 AND ($2::text IS NULL OR d.status = $2)
+		ORDER BY d.%s %s, d.id %[3]s
+		LIMIT $3 OFFSET $4`, webhookDeliveryColumns, paginateQuery.SortColumn(), paginateQuery.SortDirection())
+
+	var status *string
+	if filter, ok := paginateQuery.Filters.(*GetWebhookDeliveriesFilter); ok {
+		status = filter.Status
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, status, paginateQuery.Limit(), paginateQuery.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		deliveries   = []*WebhookDelivery{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery, &totalRecords); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return deliveries, CalculateMetadata(totalRecords, paginateQuery.Page, paginateQuery.PageSize), nil
+}
+
+// GetDelivery returns a delivery along with the webhook it is for.
+func (s *WebhookStore) GetDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, *Webhook, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, w.id, w.url, w.events, w.secret, w.is_active, w.created_by, w.created_at, w.updated_at
+		FROM webhook_deliveries d
+		INNER JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1`, webhookDeliveryColumns)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		delivery WebhookDelivery
+		webhook  Webhook
+	)
+
+	err := scanWebhookDelivery(
+		s.db.QueryRowContext(ctx, query, deliveryID),
+		&delivery,
+		&webhook.ID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Secret,
+		&webhook.IsActive,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return &delivery, &webhook, nil
+}
+
+// RecordAttempt stores the outcome of an attempt to send a delivery.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, deliveryID int64, attempt *WebhookAttempt) error {
+	stmt := `UPDATE webhook_deliveries SET
+			status = $2, attempts = attempts + 1, response_status = $3, response_body = $4,
+			error = $5, duration_ms = $6, last_attempt_at = NOW()
+		WHERE id = $1`
+
+	status := WebhookDeliveryFailed
+	if attempt.Succeeded {
+		status = WebhookDeliverySucceeded
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		stmt,
+		deliveryID,
+		status,
+		attempt.ResponseStatus,
+		attempt.ResponseBody,
+		attempt.Error,
+		attempt.Duration.Milliseconds(),
+	)
+
+	return err
+}
+
+// Redeliver marks one of a webhook's deliveries pending again and queues a
+// job of jobType to send it.
+func (s *WebhookStore) Redeliver(ctx context.Context, webhookID int64, deliveryID int64, jobType string, maxAttempts int) (*WebhookDelivery, error) {
+	query := fmt.Sprintf(`
+		WITH d AS (
+			UPDATE webhook_deliveries SET status = 'pending'
+			WHERE id = $1 AND webhook_id = $2
+			RETURNING *
+		), job AS (
+			INSERT INTO jobs(type, payload, max_attempts)
+			SELECT $3, json_build_object('delivery_id', id), $4 FROM d
+		)
+		SELECT %s FROM d`, webhookDeliveryColumns)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var delivery WebhookDelivery
+	err := scanWebhookDelivery(s.db.QueryRowContext(ctx, query, deliveryID, webhookID, jobType, maxAttempts), &delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &delivery, nil
+}
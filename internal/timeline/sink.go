@@ -0,0 +1,57 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devphaseX/mingle.git/internal/outbox"
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+// Sink is an outbox sink that fans new posts out to timelines and drops the
+// timeline of a user who follows someone, so it is rebuilt with their posts.
+// Deleted and hidden posts are left in place; reads filter them out.
+type Sink struct {
+	service *Service
+}
+
+func NewSink(service *Service) *Sink {
+	return &Sink{service: service}
+}
+
+func (s *Sink) Name() string { return "timeline" }
+
+// Publish applies events in order. Payloads that cannot be decoded fail with
+// a permanent error, so the relay dead-letters them instead of retrying.
+func (s *Sink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	for _, event := range events {
+		if err := s.apply(ctx, event); err != nil {
+			return fmt.Errorf("applying %s event %d: %w", event.Type, event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sink) apply(ctx context.Context, event *store.OutboxEvent) error {
+	switch event.Type {
+	case store.EventPostCreated:
+		var post store.Post
+		if err := json.Unmarshal(event.Payload, &post); err != nil {
+			return outbox.Permanent(err)
+		}
+
+		return s.service.Fanout(ctx, post.UserID, post.ID, post.CreatedAt)
+
+	case store.EventUserFollowed:
+		var follower store.Follower
+		if err := json.Unmarshal(event.Payload, &follower); err != nil {
+			return outbox.Permanent(err)
+		}
+
+		return s.service.Invalidate(ctx, follower.FollowerID)
+	}
+
+	return nil
+}
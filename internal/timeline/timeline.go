@@ -0,0 +1,257 @@
+// Package timeline materialises home timelines in Redis. New posts are pushed
+// onto the timeline of every follower when they are written, so reading a
+// timeline is a lookup rather than a join across the follower graph. Authors
+// with more followers than the fan-out limit are skipped at write time and
+// read from the database instead.
+package timeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCold is returned by Read when a timeline has not been built, or has
+// expired, and must be rebuilt before it can be read.
+var ErrCold = errors.New("timeline: not built")
+
+const (
+	largeAccountsKey = "timeline:large-accounts"
+	rebuildLockTTL   = 30 * time.Second
+	// fanoutBatchSize is how many timelines one script call pushes to.
+	fanoutBatchSize = 500
+)
+
+// pushScript adds a post to each built timeline and trims it to size.
+// Timelines that are not built are left alone; they pick the post up when
+// they are rebuilt. KEYS are pairs of timeline and ready keys; ARGV is the
+// score, the post ID and the size.
+var pushScript = redis.NewScript(`
+local pushed = 0
+for i = 1, #KEYS, 2 do
+	if redis.call('EXISTS', KEYS[i + 1]) == 1 then
+		redis.call('ZADD', KEYS[i], ARGV[1], ARGV[2])
+		redis.call('ZREMRANGEBYRANK', KEYS[i], 0, -tonumber(ARGV[3]) - 1)
+		pushed = pushed + 1
+	end
+end
+return pushed
+`)
+
+type Followers interface {
+	CountFollowers(ctx context.Context, userID int64) (int, error)
+	GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
+}
+
+type Posts interface {
+	GetTimelineSeed(ctx context.Context, userID int64, limit int) ([]store.TimelineEntry, error)
+}
+
+type Config struct {
+	// Size caps how many posts a timeline holds.
+	Size int
+	// TTL expires timelines that have not been read for this long.
+	TTL time.Duration
+	// FanoutLimit is the follower count above which an author's posts are
+	// read at request time rather than pushed to every follower.
+	FanoutLimit int
+}
+
+type Service struct {
+	rdb       *redis.Client
+	followers Followers
+	posts     Posts
+	cfg       Config
+}
+
+func NewService(rdb *redis.Client, followers Followers, posts Posts, cfg Config) *Service {
+	return &Service{
+		rdb:       rdb,
+		followers: followers,
+		posts:     posts,
+		cfg:       cfg,
+	}
+}
+
+// View is what a timeline read returns: the materialised post IDs, newest
+// first, and the large accounts whose posts have to be read alongside them.
+type View struct {
+	PostIDs       []int64
+	LargeAccounts []int64
+	// Complete is set when the timeline is under its size cap, so nothing
+	// has been trimmed and it holds every post in the feed. A full
+	// timeline only holds the newest posts and cannot answer queries that
+	// reach past them.
+	Complete bool
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline:%d", userID)
+}
+
+func readyKey(userID int64) string {
+	return fmt.Sprintf("timeline:%d:ready", userID)
+}
+
+func rebuildKey(userID int64) string {
+	return fmt.Sprintf("timeline:%d:rebuild", userID)
+}
+
+// Read returns the user's timeline and keeps it from expiring.
+func (s *Service) Read(ctx context.Context, userID int64) (*View, error) {
+	pipe := s.rdb.Pipeline()
+	ready := pipe.Exists(ctx, readyKey(userID))
+	members := pipe.ZRevRange(ctx, timelineKey(userID), 0, -1)
+	large := pipe.SMembers(ctx, largeAccountsKey)
+	pipe.Expire(ctx, timelineKey(userID), s.cfg.TTL)
+	pipe.Expire(ctx, readyKey(userID), s.cfg.TTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if ready.Val() == 0 {
+		return nil, ErrCold
+	}
+
+	postIDs, err := parseIDs(members.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	largeAccounts, err := parseIDs(large.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	return &View{
+		PostIDs:       postIDs,
+		LargeAccounts: largeAccounts,
+		Complete:      len(postIDs) < s.cfg.Size,
+	}, nil
+}
+
+// Rebuild fills the user's timeline from the database. Concurrent rebuilds
+// of the same timeline are skipped.
+func (s *Service) Rebuild(ctx context.Context, userID int64) error {
+	locked, err := s.rdb.SetNX(ctx, rebuildKey(userID), 1, rebuildLockTTL).Result()
+	if err != nil || !locked {
+		return err
+	}
+	defer s.rdb.Del(ctx, rebuildKey(userID))
+
+	entries, err := s.posts.GetTimelineSeed(ctx, userID, s.cfg.Size)
+	if err != nil {
+		return err
+	}
+
+	members := make([]redis.Z, len(entries))
+	for i, entry := range entries {
+		members[i] = redis.Z{Score: float64(entry.CreatedAt.Unix()), Member: entry.PostID}
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, timelineKey(userID))
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, timelineKey(userID), members...)
+		pipe.Expire(ctx, timelineKey(userID), s.cfg.TTL)
+	}
+	pipe.Set(ctx, readyKey(userID), 1, s.cfg.TTL)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Invalidate drops the user's timeline so the next read rebuilds it, such as
+// after they follow someone whose older posts it is missing.
+func (s *Service) Invalidate(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, readyKey(userID), timelineKey(userID)).Err()
+}
+
+// Fanout pushes a new post onto its author's timeline and those of their
+// followers. Large accounts only push to their own timeline and are marked
+// to be read at request time. An author who drops back under the limit has
+// their followers' timelines rebuilt instead, to pick up the posts that were
+// never pushed.
+func (s *Service) Fanout(ctx context.Context, authorID, postID int64, createdAt time.Time) error {
+	count, err := s.followers.CountFollowers(ctx, authorID)
+	if err != nil {
+		return err
+	}
+
+	userIDs := []int64{authorID}
+
+	if count > s.cfg.FanoutLimit {
+		if err := s.rdb.SAdd(ctx, largeAccountsKey, authorID).Err(); err != nil {
+			return err
+		}
+	} else {
+		removed, err := s.rdb.SRem(ctx, largeAccountsKey, authorID).Result()
+		if err != nil {
+			return err
+		}
+
+		followerIDs, err := s.followers.GetFollowerIDs(ctx, authorID)
+		if err != nil {
+			return err
+		}
+
+		// The author's posts from while they were a large account were read
+		// at request time and never pushed, so the followers' timelines are
+		// missing them. Drop those timelines to be rebuilt on their next read.
+		if removed > 0 {
+			return s.invalidateAll(ctx, append(followerIDs, authorID))
+		}
+
+		userIDs = append(userIDs, followerIDs...)
+	}
+
+	for start := 0; start < len(userIDs); start += fanoutBatchSize {
+		batch := userIDs[start:min(start+fanoutBatchSize, len(userIDs))]
+
+		keys := make([]string, 0, len(batch)*2)
+		for _, userID := range batch {
+			keys = append(keys, timelineKey(userID), readyKey(userID))
+		}
+
+		if err := pushScript.Run(ctx, s.rdb, keys, createdAt.Unix(), postID, s.cfg.Size).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) invalidateAll(ctx context.Context, userIDs []int64) error {
+	for start := 0; start < len(userIDs); start += fanoutBatchSize {
+		batch := userIDs[start:min(start+fanoutBatchSize, len(userIDs))]
+
+		keys := make([]string, 0, len(batch)*2)
+		for _, userID := range batch {
+			keys = append(keys, readyKey(userID), timelineKey(userID))
+		}
+
+		if err := s.rdb.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseIDs(members []string) ([]int64, error) {
+	ids := make([]int64, len(members))
+	for i, member := range members {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("timeline: invalid member %q: %w", member, err)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,189 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devphaseX/mingle.git/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeFollowers map[int64][]int64
+
+func (f fakeFollowers) CountFollowers(_ context.Context, userID int64) (int, error) {
+	return len(f[userID]), nil
+}
+
+func (f fakeFollowers) GetFollowerIDs(_ context.Context, userID int64) ([]int64, error) {
+	return f[userID], nil
+}
+
+// fakePosts seeds every timeline with the same posts, newest first.
+type fakePosts []store.TimelineEntry
+
+func (f fakePosts) GetTimelineSeed(_ context.Context, _ int64, limit int) ([]store.TimelineEntry, error) {
+	return f[:min(limit, len(f))], nil
+}
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func seed(n int) fakePosts {
+	entries := make(fakePosts, n)
+	for i := range entries {
+		id := int64(n - i)
+		entries[i] = store.TimelineEntry{PostID: id, CreatedAt: epoch.Add(time.Duration(id) * time.Minute)}
+	}
+	return entries
+}
+
+func newTestService(t *testing.T, followers fakeFollowers, posts fakePosts, cfg Config) *Service {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return NewService(rdb, followers, posts, cfg)
+}
+
+func read(t *testing.T, s *Service, userID int64) *View {
+	t.Helper()
+
+	view, err := s.Read(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Read(%d): %v", userID, err)
+	}
+	return view
+}
+
+func TestReadRebuild(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, fakeFollowers{}, seed(3), Config{Size: 5, TTL: time.Hour, FanoutLimit: 10})
+
+	if _, err := s.Read(ctx, 1); !errors.Is(err, ErrCold) {
+		t.Fatalf("Read before Rebuild = %v, want ErrCold", err)
+	}
+
+	if err := s.Rebuild(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	view := read(t, s, 1)
+	if !slices.Equal(view.PostIDs, []int64{3, 2, 1}) {
+		t.Errorf("PostIDs = %v, want newest first", view.PostIDs)
+	}
+	if !view.Complete {
+		t.Error("a timeline under its size cap should be complete")
+	}
+
+	if err := s.Invalidate(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(ctx, 1); !errors.Is(err, ErrCold) {
+		t.Errorf("Read after Invalidate = %v, want ErrCold", err)
+	}
+}
+
+func TestReadReportsFullTimelines(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, fakeFollowers{}, seed(10), Config{Size: 5, TTL: time.Hour, FanoutLimit: 10})
+
+	if err := s.Rebuild(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	view := read(t, s, 1)
+	if !slices.Equal(view.PostIDs, []int64{10, 9, 8, 7, 6}) {
+		t.Errorf("PostIDs = %v, want the newest 5", view.PostIDs)
+	}
+	if view.Complete {
+		t.Error("a full timeline may have lost older posts and should not be complete")
+	}
+}
+
+func TestFanout(t *testing.T) {
+	ctx := context.Background()
+	followers := fakeFollowers{1: {2, 3}}
+	s := newTestService(t, followers, seed(2), Config{Size: 3, TTL: time.Hour, FanoutLimit: 10})
+
+	// Only built timelines take the push; 3's picks the post up on rebuild.
+	for _, userID := range []int64{1, 2} {
+		if err := s.Rebuild(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Fanout(ctx, 1, 100, epoch.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fanout(ctx, 1, 101, epoch.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, userID := range []int64{1, 2} {
+		view := read(t, s, userID)
+		if !slices.Equal(view.PostIDs, []int64{101, 100, 2}) {
+			t.Errorf("timeline %d = %v, want the pushes trimmed to size", userID, view.PostIDs)
+		}
+	}
+
+	if _, err := s.Read(ctx, 3); !errors.Is(err, ErrCold) {
+		t.Errorf("Read(3) = %v, want ErrCold", err)
+	}
+}
+
+func TestFanoutAcrossTheLimit(t *testing.T) {
+	ctx := context.Background()
+	followers := fakeFollowers{1: {2, 3}}
+	s := newTestService(t, followers, seed(1), Config{Size: 10, TTL: time.Hour, FanoutLimit: 1})
+
+	for _, userID := range []int64{1, 2, 3} {
+		if err := s.Rebuild(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Over the limit, the post stays on the author's timeline and the
+	// author is read at request time.
+	if err := s.Fanout(ctx, 1, 100, epoch.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	view := read(t, s, 2)
+	if slices.Contains(view.PostIDs, 100) {
+		t.Error("a large account's post was pushed to a follower")
+	}
+	if !slices.Equal(view.LargeAccounts, []int64{1}) {
+		t.Errorf("LargeAccounts = %v, want [1]", view.LargeAccounts)
+	}
+
+	// Back under the limit, the followers' timelines are missing post 100,
+	// so they are dropped to be rebuilt rather than pushed to.
+	followers[1] = []int64{2}
+	if err := s.Fanout(ctx, 1, 101, epoch.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, userID := range []int64{1, 2} {
+		if _, err := s.Read(ctx, userID); !errors.Is(err, ErrCold) {
+			t.Errorf("Read(%d) = %v, want ErrCold", userID, err)
+		}
+	}
+
+	// From then on posts are pushed as usual.
+	if err := s.Rebuild(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fanout(ctx, 1, 102, epoch.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	view = read(t, s, 2)
+	if view.PostIDs[0] != 102 || len(view.LargeAccounts) != 0 {
+		t.Errorf("timeline 2 = %v, large accounts %v", view.PostIDs, view.LargeAccounts)
+	}
+}
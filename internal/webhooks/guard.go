@@ -0,0 +1,109 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs that point, or resolve,
+// into our own network: loopback, private, link-local (which includes cloud
+// metadata endpoints) and other non-public ranges.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// nonPublic lists the special-purpose ranges not covered by the netip.Addr
+// predicates used in publicAddr.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach IPv4 internals
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckURL rejects webhook URLs that are not http(s) or whose host is, or
+// resolves to, a non-public address. The client checks again at dial time,
+// since DNS can change after a webhook is saved.
+func (c *Client) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook URL must use http or https")
+	}
+
+	if c.allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if host == "" || strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrForbiddenAddress
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving webhook host: %w", err)
+	}
+
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// dialControl refuses connections to non-public addresses. It runs after
+// name resolution, for every address dialled, so it also covers redirects
+// and hosts that resolved to a public address when the webhook was saved.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("dialing %s: %w", address, ErrForbiddenAddress)
+	}
+
+	return nil
+}
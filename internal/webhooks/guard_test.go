@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+func TestCheckURL(t *testing.T) {
+	client := NewClient(time.Second, false)
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://93.184.215.14/hook", true},
+		{"https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]/hook", true},
+		{"ftp://93.184.215.14/hook", false},
+		{"http://localhost:8080/hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://100.64.0.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[fd00:ec2::254]/hook", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := client.CheckURL(context.Background(), tt.url)
+			if tt.allowed && err != nil {
+				t.Errorf("CheckURL = %v, want nil", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Error("CheckURL = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// The server listens on loopback, as an internal service would.
+	webhook := &store.Webhook{URL: srv.URL, Secret: "0123456789abcdef"}
+	attempt, err := NewClient(time.Second, false).Deliver(context.Background(), webhook, newDelivery())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempt.Succeeded || called {
+		t.Fatal("delivered to a loopback address")
+	}
+	if attempt.Error == nil {
+		t.Fatal("expected the attempt to record an error")
+	}
+
+	if err := dialControl("tcp", "127.0.0.1:80", nil); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("dialControl = %v, want ErrForbiddenAddress", err)
+	}
+}
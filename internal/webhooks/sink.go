@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+type DeliveryStore interface {
+	CreateDeliveries(ctx context.Context, event *store.OutboxEvent, jobType string, maxAttempts int) (int64, error)
+}
+
+// Sink is an outbox sink that fans each event out to the webhooks subscribed
+// to it. It only records the deliveries and queues a job for each; the jobs
+// do the sending, so a slow receiver never holds up the outbox.
+type Sink struct {
+	store       DeliveryStore
+	jobType     string
+	maxAttempts int
+	// wake is called after deliveries are queued so a job worker picks them
+	// up straight away.
+	wake func()
+}
+
+func NewSink(store DeliveryStore, jobType string, maxAttempts int, wake func()) *Sink {
+	return &Sink{
+		store:       store,
+		jobType:     jobType,
+		maxAttempts: maxAttempts,
+		wake:        wake,
+	}
+}
+
+func (s *Sink) Name() string { return "webhooks" }
+
+func (s *Sink) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	var queued int64
+
+	for _, event := range events {
+		n, err := s.store.CreateDeliveries(ctx, event, s.jobType, s.maxAttempts)
+		if err != nil {
+			return err
+		}
+
+		queued += n
+	}
+
+	if queued > 0 {
+		s.wake()
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package webhooks sends outbox events to third-party endpoints. Each request
+// is signed with the webhook's secret so receivers can check it came from us
+// and has not been replayed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+const (
+	EventHeader     = "X-Mingle-Event"
+	DeliveryHeader  = "X-Mingle-Delivery"
+	SignatureHeader = "X-Mingle-Signature"
+
+	// maxResponseBody is how much of a receiver's response is kept in the
+	// delivery log.
+	maxResponseBody = 4 << 10
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Payload is the body of every webhook request.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature header value for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, hex.EncodeToString(mac(secret, t, body)))
+}
+
+// Verify checks a signature header produced by Sign and rejects it if its
+// timestamp is more than tolerance away from now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	signature, err := hex.DecodeString(v1)
+	if err != nil || !hmac.Equal(signature, mac(secret, t, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+type Client struct {
+	http         *http.Client
+	allowPrivate bool
+}
+
+// NewClient returns a client that refuses to deliver to non-public
+// addresses unless allowPrivate is set, which is only meant for local
+// development.
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+
+	// No proxy from the environment: it would do the dialling and bypass
+	// the address check.
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &Client{
+		http:         &http.Client{Timeout: timeout, Transport: transport},
+		allowPrivate: allowPrivate,
+	}
+}
+
+// Deliver sends delivery to webhook and returns the outcome. Transport
+// failures and non-2xx responses are unsuccessful attempts rather than
+// errors; an error means the request could not be built at all.
+func (c *Client) Deliver(ctx context.Context, webhook *store.Webhook, delivery *store.WebhookDelivery) (*store.WebhookAttempt, error) {
+	body, err := json.Marshal(Payload{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mingle-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), body))
+
+	attempt := &store.WebhookAttempt{}
+	start := time.Now()
+
+	res, err := c.http.Do(req)
+	attempt.Duration = time.Since(start)
+
+	if err != nil {
+		message := err.Error()
+		attempt.Error = &message
+		return attempt, nil
+	}
+	defer res.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	response := string(responseBody)
+
+	attempt.ResponseStatus = &res.StatusCode
+	attempt.ResponseBody = &response
+	attempt.Succeeded = res.StatusCode >= 200 && res.StatusCode <= 299
+
+	if !attempt.Succeeded {
+		message := fmt.Sprintf("unexpected status %s", res.Status)
+		attempt.Error = &message
+	}
+
+	return attempt, nil
+}
@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devphaseX/mingle.git/internal/store"
+)
+
+func newDelivery() *store.WebhookDelivery {
+	return &store.WebhookDelivery{
+		ID:        7,
+		EventID:   42,
+		EventType: store.EventPostCreated,
+		Payload:   json.RawMessage(`{"id":1,"title":"hello"}`),
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	const secret = "0123456789abcdef"
+
+	t.Run("should send a signed payload", func(t *testing.T) {
+		var (
+			header http.Header
+			body   []byte
+		)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		webhook := &store.Webhook{URL: srv.URL, Secret: secret}
+		attempt, err := NewClient(time.Second, true).Deliver(context.Background(), webhook, newDelivery())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !attempt.Succeeded {
+			t.Fatalf("expected the attempt to succeed, got error %v", attempt.Error)
+		}
+
+		if got := *attempt.ResponseStatus; got != http.StatusNoContent {
+			t.Errorf("expected response status %d, got %d", http.StatusNoContent, got)
+		}
+
+		if got := header.Get(EventHeader); got != store.EventPostCreated {
+			t.Errorf("expected %s header %q, got %q", EventHeader, store.EventPostCreated, got)
+		}
+
+		if got := header.Get(DeliveryHeader); got != "7" {
+			t.Errorf("expected %s header %q, got %q", DeliveryHeader, "7", got)
+		}
+
+		if err := Verify(secret, header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("expected a valid signature, got %v", err)
+		}
+
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+
+		if payload.ID != 42 || payload.Type != store.EventPostCreated {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	})
+
+	t.Run("should record a failed attempt on a non-2xx response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		webhook := &store.Webhook{URL: srv.URL, Secret: secret}
+		attempt, err := NewClient(time.Second, true).Deliver(context.Background(), webhook, newDelivery())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempt.Succeeded {
+			t.Fatal("expected the attempt to fail")
+		}
+
+		if got := *attempt.ResponseStatus; got != http.StatusServiceUnavailable {
+			t.Errorf("expected response status %d, got %d", http.StatusServiceUnavailable, got)
+		}
+
+		if attempt.Error == nil || attempt.ResponseBody == nil || *attempt.ResponseBody != "try later\n" {
+			t.Errorf("expected the error and response body to be recorded, got %+v", attempt)
+		}
+	})
+
+	t.Run("should record a failed attempt when the receiver is unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.Close()
+
+		webhook := &store.Webhook{URL: srv.URL, Secret: secret}
+		attempt, err := NewClient(time.Second, true).Deliver(context.Background(), webhook, newDelivery())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempt.Succeeded || attempt.Error == nil || attempt.ResponseStatus != nil {
+			t.Errorf("expected a transport failure, got %+v", attempt)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "0123456789abcdef"
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+
+	signature := Sign(secret, now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", secret, signature, body, now, false},
+		{"wrong secret", "fedcba9876543210", signature, body, now, true},
+		{"tampered body", secret, signature, []byte(`{"id":2}`), now, true},
+		{"expired", secret, signature, body, now.Add(10 * time.Minute), true},
+		{"malformed", secret, "v1=abc", body, now, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}